@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pality/npm-security-scanner/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// baselinePath makes scans report and gate only on findings missing from it (--baseline)
+	baselinePath string
+	// baselineOutput is where the baseline command writes the baseline (--output)
+	baselineOutput string
+	// baselineFromReport creates the baseline from a JSON report instead of scanning (--from)
+	baselineFromReport string
+)
+
+// newBaselineCommand creates the subcommand that records the current findings as the baseline
+func newBaselineCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline [target-directory]",
+		Short: "Record the current findings so later scans with --baseline report only new ones",
+		Long: `対象ディレクトリを読み取り専用モードでスキャンし、現在の検出結果をベースラインファイルに書き出します。
+以降のスキャンで--baselineを指定すると、ベースラインにない新しい検出結果のみを報告・判定します。
+--fromを指定すると、スキャンせずに既存のJSONレポートからベースラインを作成します。`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runBaseline,
+	}
+
+	cmd.Flags().StringVarP(&baselineOutput, "output", "o", "",
+		"baseline file to write (default: "+scanner.DefaultBaselineFile+" in the target directory)")
+	cmd.Flags().StringVar(&baselineFromReport, "from", "",
+		"create the baseline from this JSON report instead of scanning")
+	return cmd
+}
+
+func runBaseline(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	targetDir := "."
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+	output := firstNonEmpty(baselineOutput, filepath.Join(targetDir, scanner.DefaultBaselineFile))
+
+	report, err := baselineReport(ctx, targetDir)
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if report == nil {
+		return
+	}
+	if report.Interrupted {
+		warningColor.Println("⚠️  Scan interrupted, baseline not written")
+		os.Exit(ExitInterrupted)
+	}
+
+	baseline := scanner.NewBaseline(report)
+	if err := scanner.WriteBaseline(output, baseline); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	successColor.Printf("📌 Baseline with %d finding(s) written to %s\n", len(baseline.Findings), output)
+	if report.ErrorCount > 0 {
+		warningColor.Printf("⚠️  %d project(s) failed to scan; their findings are missing from the baseline\n",
+			report.ErrorCount)
+		os.Exit(ExitScanErrors)
+	}
+}
+
+// baselineReport loads the --from report, or scans the target read-only
+func baselineReport(ctx context.Context, targetDir string) (*scanner.Report, error) {
+	if baselineFromReport != "" {
+		return loadReport(baselineFromReport)
+	}
+
+	routeProgressOutput()
+	infoColor.Printf("🔍 NPM Security Scanner v%s (baseline)\n", appVersion)
+	infoColor.Printf("Target directory: %s\n\n", targetDir)
+
+	if err := validateOptions(); err != nil {
+		return nil, err
+	}
+	detectNonInteractive()
+
+	var err error
+	if options.IOCs, err = loadIOCs(); err != nil {
+		return nil, err
+	}
+	if options.Suppressions, err = loadSuppressionFile(); err != nil {
+		return nil, err
+	}
+	if options.Baseline, err = loadBaselineFile(); err != nil {
+		return nil, err
+	}
+	if err := setupCommandRunner(targetDir); err != nil {
+		return nil, err
+	}
+	if err := setupEventStream(); err != nil {
+		return nil, err
+	}
+
+	projects, err := discoverProjects(ctx, targetDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find NPM projects: %w", err)
+	}
+	if len(projects) == 0 {
+		warningColor.Println("⚠️  No NPM projects found in the specified directory")
+		return nil, nil
+	}
+	showProjects(projects)
+
+	// ベースラインの作成ではプロジェクトを変更しない
+	options.ReadOnly = true
+	options.TargetDir = targetDir
+	scanWithInterrupt(ctx, projects)
+	return currentReport, nil
+}
+
+// loadBaselineFile loads the --baseline file, or returns nil when none is configured
+func loadBaselineFile() (*scanner.Baseline, error) {
+	if baselinePath == "" {
+		return nil, nil
+	}
+	baseline, err := scanner.LoadBaseline(baselinePath)
+	if err != nil {
+		return nil, err
+	}
+	infoColor.Printf("📌 Loaded baseline with %d finding(s) from %s\n", len(baseline.Findings), baselinePath)
+	return baseline, nil
+}
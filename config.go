@@ -0,0 +1,436 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pality/npm-security-scanner/scanner"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file locations and environment variables
+const (
+	// ConfigFileName is searched upward from the target directory and in the user config dir
+	ConfigFileName = ".npm-security-scanner.yaml"
+	// configEnvPrefix prefixes the environment variable of every setting,
+	// e.g. NPM_SECURITY_SCANNER_INSTALL_STRATEGY for install.strategy
+	configEnvPrefix = "NPM_SECURITY_SCANNER_"
+	// configIgnoreAnnotation marks subcommand flags that share a name with a
+	// setting's flag but must not receive its value
+	configIgnoreAnnotation = "npm-security-scanner/config-ignore"
+)
+
+// Sources of an effective setting, from lowest to highest precedence
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceUser    = "user config"
+	ConfigSourceProject = "project config"
+	ConfigSourceEnv     = "env"
+	ConfigSourceFlag    = "flag"
+)
+
+// configSetting maps a config file key to the flag holding its value. Settings
+// without a command-line flag are registered in configOnlyFlags.
+type configSetting struct {
+	// Key is the dotted path in the config file, e.g. "install.strategy"
+	Key  string
+	Flag string
+}
+
+// configSettings lists every configurable setting in config print order
+var configSettings = []configSetting{
+	{"discovery.include", "include"},
+	{"discovery.exclude", "exclude"},
+	{"discovery.max_depth", "max-depth"},
+	{"discovery.no_default_excludes", "no-default-excludes"},
+	{"discovery.gitignore", "gitignore"},
+	{"install.strategy", "install-strategy"},
+	{"install.timeout", "install-timeout"},
+	{"audit.level", "audit-level"},
+	{"audit.timeout", "audit-timeout"},
+	{"fix.timeout", "fix-timeout"},
+	{"fail_on", "fail-on"},
+	{"jobs", "jobs"},
+	{"ioc", "ioc"},
+	{"suppressions", "suppressions"},
+	{"baseline", "baseline"},
+	{"reports.dir", "output-dir"},
+	{"reports.formats", "format"},
+	{"reports.history", "report-history"},
+	{"safe_chain.package", "safe-chain-package"},
+	{"safe_chain.command", "safe-chain-command"},
+}
+
+// restrictedProjectSettings can weaken the scan, hide projects from it,
+// redirect its reports or run other executables, so a project config found in
+// the scanned tree cannot set them. They are taken from the user config, env,
+// flags or a config file passed with --config.
+var restrictedProjectSettings = map[string]bool{
+	"discovery.include":             true,
+	"discovery.exclude":             true,
+	"discovery.max_depth":           true,
+	"discovery.no_default_excludes": true,
+	"install.strategy":              true,
+	"audit.level":                   true,
+	"fail_on":                       true,
+	"ioc":                           true,
+	"suppressions":                  true,
+	"baseline":                      true,
+	"reports.dir":                   true,
+	"safe_chain.package":            true,
+	"safe_chain.command":            true,
+}
+
+var (
+	// configFile overrides the upward search for the project config file (--config)
+	configFile string
+	// configOnlyFlags holds the settings that are only set by config files and env
+	configOnlyFlags *pflag.FlagSet
+	// effectiveConfig records the value and source of every setting after loadConfig
+	effectiveConfig []configValue
+	// loadedConfigFiles are the config files merged by loadConfig, lowest precedence first
+	loadedConfigFiles []configFileLayer
+	// ignoredProjectSettings are the restricted settings a discovered project config tried to set
+	ignoredProjectSettings []string
+)
+
+// configValue is the effective value of a setting and where it came from
+type configValue struct {
+	Setting configSetting
+	Flag    *pflag.Flag
+	// Source is one of the ConfigSource constants; Origin names the env
+	// variable or config file for those sources
+	Source string
+	Origin string
+}
+
+// configFileLayer is a parsed config file with its settings flattened to dotted keys
+type configFileLayer struct {
+	Path   string
+	Source string
+	Values map[string]string
+	// Restricted is set for a project config found by searching the scanned
+	// tree; it cannot set restrictedProjectSettings
+	Restricted bool
+}
+
+// envName returns the environment variable of the setting
+func (s configSetting) envName() string {
+	return configEnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(s.Key))
+}
+
+// newConfigOnlyFlags registers the settings that have no command-line flag
+func newConfigOnlyFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	flags.BoolVar(&recordHistory, "report-history", true,
+		"append each scan to "+HistoryFileName+" in the reports directory")
+	flags.StringVar(&options.SafeChainPackage, "safe-chain-package", scanner.DefaultSafeChainPackage,
+		"npm package installed to provide Safe Chain")
+	flags.StringVar(&options.SafeChainCommand, "safe-chain-command", scanner.DefaultSafeChainCommand,
+		"Safe Chain executable")
+	return flags
+}
+
+// loadConfig merges the user and project config files and the environment into
+// every setting not given on the command line: flags > env > project > user.
+// Restricted settings in a discovered project config are ignored with a warning.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	targetDir := "."
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+
+	layers, err := readConfigLayers(targetDir)
+	if err != nil {
+		return err
+	}
+	loadedConfigFiles = layers
+
+	effectiveConfig = effectiveConfig[:0]
+	ignoredProjectSettings = ignoredProjectSettings[:0]
+	for _, setting := range configSettings {
+		flag := lookupSettingFlag(cmd, setting.Flag)
+		if flag == nil {
+			return fmt.Errorf("setting %s has no flag %q", setting.Key, setting.Flag)
+		}
+
+		value := configValue{Setting: setting, Flag: flag, Source: ConfigSourceDefault}
+		raw, found := "", false
+		// 空の環境変数も設定ファイルの値を上書きする
+		env, envSet := os.LookupEnv(setting.envName())
+		switch {
+		case flag.Changed:
+			value.Source = ConfigSourceFlag
+			value.Origin = "--" + flag.Name
+		case envSet:
+			raw, found = env, true
+			value.Source, value.Origin = ConfigSourceEnv, setting.envName()
+		default:
+			// 後に読み込んだファイル（プロジェクト）が優先される
+			for i := len(layers) - 1; i >= 0 && !found; i-- {
+				if _, ok := layers[i].Values[setting.Key]; ok && layers[i].Restricted &&
+					restrictedProjectSettings[setting.Key] {
+					ignoredProjectSettings = append(ignoredProjectSettings, setting.Key)
+					continue
+				}
+				if raw, found = layers[i].Values[setting.Key]; found {
+					value.Source, value.Origin = layers[i].Source, layers[i].Path
+				}
+			}
+		}
+
+		if found {
+			if err := flag.Value.Set(raw); err != nil {
+				return fmt.Errorf("invalid %s %q from %s: %w", setting.Key, raw, value.Origin, err)
+			}
+		}
+		effectiveConfig = append(effectiveConfig, value)
+	}
+
+	if len(ignoredProjectSettings) > 0 {
+		warningColor.Fprintf(color.Error, "⚠️  Ignoring %s from the project config %s: these settings can weaken the scan or redirect its reports\n",
+			strings.Join(ignoredProjectSettings, ", "), layers[len(layers)-1].Path)
+		warningColor.Fprintln(color.Error,
+			"   Set them in the user config, the environment or flags, or pass the file with --config")
+	}
+	return nil
+}
+
+// lookupSettingFlag finds the flag of a setting on the running command, the
+// root command or the config-only flags
+func lookupSettingFlag(cmd *cobra.Command, name string) *pflag.Flag {
+	for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.Root().Flags(), cmd.Root().PersistentFlags(),
+		configOnlyFlags} {
+		if flags == nil {
+			continue
+		}
+		// diffやhistoryの--formatのように設定と無関係な同名のフラグは飛ばす
+		if flag := flags.Lookup(name); flag != nil && flag.Annotations[configIgnoreAnnotation] == nil {
+			return flag
+		}
+	}
+	return nil
+}
+
+// readConfigLayers reads the user config and the project config, lowest precedence first
+func readConfigLayers(targetDir string) ([]configFileLayer, error) {
+	var layers []configFileLayer
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		path := filepath.Join(dir, ConfigFileName)
+		layer, err := readConfigFile(path, ConfigSourceUser)
+		switch {
+		case err == nil:
+			layers = append(layers, layer)
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	// --configで明示されたファイルは信頼し、走査対象から見つけたファイルは制限する
+	path, restricted := configFile, false
+	if path == "" {
+		path, restricted = findProjectConfig(targetDir), true
+	}
+	if path != "" {
+		layer, err := readConfigFile(path, ConfigSourceProject)
+		if err != nil {
+			return nil, err
+		}
+		layer.Restricted = restricted
+		// ユーザー設定と同じファイルを二重に読み込まない
+		if len(layers) == 0 || !sameFile(layers[0].Path, layer.Path) {
+			layers = append(layers, layer)
+		}
+	}
+	return layers, nil
+}
+
+// findProjectConfig searches the target directory and its parents for the config file
+func findProjectConfig(targetDir string) string {
+	dir, err := filepath.Abs(targetDir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// readConfigFile parses a config file, rejecting keys that are not settings
+func readConfigFile(path, source string) (configFileLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configFileLayer{}, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return configFileLayer{}, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	layer := configFileLayer{Path: path, Source: source, Values: make(map[string]string)}
+	if err := flattenConfig("", raw, layer.Values); err != nil {
+		return configFileLayer{}, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return layer, nil
+}
+
+// flattenConfig converts nested config sections into dotted keys with the
+// values in flag syntax (lists become comma-separated)
+func flattenConfig(prefix string, raw map[string]interface{}, values map[string]string) error {
+	for key, value := range raw {
+		fullKey := prefix + key
+		if section, ok := value.(map[string]interface{}); ok && isConfigSection(fullKey) {
+			if err := flattenConfig(fullKey+".", section, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isConfigSetting(fullKey) {
+			return fmt.Errorf("unknown setting %q", fullKey)
+		}
+
+		switch v := value.(type) {
+		case nil:
+			values[fullKey] = ""
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[fullKey] = strings.Join(items, ",")
+		case map[string]interface{}:
+			return fmt.Errorf("setting %q must be a value, not a section", fullKey)
+		default:
+			values[fullKey] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+// isConfigSetting reports whether the dotted key is a setting
+func isConfigSetting(key string) bool {
+	for _, setting := range configSettings {
+		if setting.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfigSection reports whether the dotted key groups other settings
+func isConfigSection(key string) bool {
+	for _, setting := range configSettings {
+		if strings.HasPrefix(setting.Key, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// newConfigCommand creates the config subcommand
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the scanner configuration",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "print [target-directory]",
+		Short: "Print the effective configuration merged from flags, environment and config files",
+		Long: `フラグ > 環境変数 > プロジェクト設定 > ユーザー設定 の優先順位でマージした、
+実際に使用される設定をYAMLで表示します。各値のコメントは設定元を示します。`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printEffectiveConfig(cmd.OutOrStdout())
+		},
+	})
+	return cmd
+}
+
+// printEffectiveConfig writes the effective configuration as a config file,
+// annotating each value with its source
+func printEffectiveConfig(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	if len(loadedConfigFiles) == 0 {
+		root.HeadComment = "No config files found"
+	} else {
+		var files []string
+		for _, layer := range loadedConfigFiles {
+			files = append(files, fmt.Sprintf("%s: %s", layer.Source, layer.Path))
+		}
+		root.HeadComment = "Merged config files (flags > env > project > user):\n" + strings.Join(files, "\n")
+	}
+	if len(ignoredProjectSettings) > 0 {
+		root.HeadComment += "\nIgnored from the project config: " + strings.Join(ignoredProjectSettings, ", ")
+	}
+
+	sections := make(map[string]*yaml.Node)
+	for _, value := range effectiveConfig {
+		parent := root
+		key := value.Setting.Key
+		if idx := strings.LastIndex(key, "."); idx >= 0 {
+			name := key[:idx]
+			if sections[name] == nil {
+				sections[name] = &yaml.Node{Kind: yaml.MappingNode}
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, sections[name])
+			}
+			parent, key = sections[name], key[idx+1:]
+		}
+
+		node := configValueNode(value.Flag)
+		node.LineComment = value.Source
+		if value.Origin != "" {
+			node.LineComment += " (" + value.Origin + ")"
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// configValueNode converts a flag value to a YAML node of the matching type
+func configValueNode(flag *pflag.Flag) *yaml.Node {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range slice.GetSlice() {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return node
+	}
+
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: flag.Value.String()}
+	switch flag.Value.Type() {
+	case "int", "bool":
+	default:
+		node.Tag = "!!str"
+	}
+	return node
+}
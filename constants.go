@@ -2,11 +2,14 @@
 // Git push issue resolved: removed conflicting main tag
 package main
 
-// Status constants
+// Exit codes
 const (
-	StatusSuccess    = "success"
-	StatusFailed     = "failed"
-	StatusInProgress = "in_progress"
+	ExitClean         = 0   // No findings at or above --fail-on and no scan errors
+	ExitFindings      = 1   // Unfixed findings at or above --fail-on
+	ExitScanErrors    = 2   // One or more projects failed to scan
+	ExitMisconfigured = 3   // Invalid flags, missing target directory, Safe Chain setup failure or nothing scanned
+	ExitMalware       = 4   // Known-malicious packages or files found (regardless of --fail-on)
+	ExitInterrupted   = 130 // 128 + SIGINT
 )
 
 // HTML template constants
@@ -16,28 +19,16 @@ const (
 	HTMLVulnClass  = "vulnerability"
 )
 
-// File permissions (secure defaults)
-const (
-	FilePermSecure   = 0o600 // Owner read/write only
-	DirPermSecure    = 0o755 // Owner full, group/other read/execute
-	FilePermReadable = 0o644 // Owner read/write, others read
-)
-
-// Severity levels
-const (
-	SeverityLow      = "low"
-	SeverityModerate = "moderate"
-	SeverityHigh     = "high"
-	SeverityCritical = "critical"
-)
-
 // Report constants
 const (
-	ReportsDirName    = "reports"
-	HTMLExtension     = ".html"
-	JSONExtension     = ".json"
-	ReportSeparator   = 80
-	VulnerabilityText = " vulnerabilit"
+	ReportsDirName  = "reports"
+	HTMLExtension   = ".html"
+	JSONExtension   = ".json"
+	SARIFExtension  = ".sarif"
+	ReportSeparator = 80
+	// CycloneDX SBOMs are written once per project
+	CycloneDXJSONExtension = ".cdx.json"
+	CycloneDXXMLExtension  = ".cdx.xml"
 )
 
 // Bulma CSS class constants
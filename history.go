@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pality/npm-security-scanner/scanner"
+	"github.com/spf13/cobra"
+)
+
+// HistoryFileName is the append-only scan history kept in the reports directory
+const HistoryFileName = "history.jsonl"
+
+// historySeverities are the severities counted per project, most severe first
+var historySeverities = []string{scanner.SeverityMalware, scanner.SeverityCritical, scanner.SeverityHigh, scanner.SeverityModerate, scanner.SeverityLow}
+
+var (
+	// recordHistory appends every finished scan to the history (reports.history)
+	recordHistory = true
+	// historyFormat is the output format of the history command (--format)
+	historyFormat string
+	// historyOutput is the file the history is written to instead of stdout (--output)
+	historyOutput string
+	// historyLimit is the number of most recent runs shown (--limit)
+	historyLimit int
+)
+
+// HistoryEntry is one line of the history: the per-project results of a scan
+type HistoryEntry struct {
+	Time      time.Time        `json:"time"`
+	ScanID    string           `json:"scan_id"`
+	TargetDir string           `json:"target_dir,omitempty"`
+	Projects  []HistoryProject `json:"projects"`
+}
+
+// HistoryProject records the open findings of a project in a scan
+type HistoryProject struct {
+	Counts   map[string]int   `json:"counts"`
+	Project  string           `json:"project"`
+	Status   string           `json:"status"`
+	Findings []HistoryFinding `json:"findings,omitempty"`
+	Duration time.Duration    `json:"duration"`
+	// Scanned is set when the security scan succeeded, so missing findings were fixed
+	Scanned bool `json:"scanned"`
+}
+
+// HistoryFinding identifies an open finding for time-to-fix tracking
+type HistoryFinding struct {
+	Fingerprint string `json:"fingerprint"`
+	Advisory    string `json:"advisory"`
+	Package     string `json:"package"`
+	Severity    string `json:"severity"`
+}
+
+// lifetimeKey identifies a finding across scans of a project by its package and
+// advisory. Unlike the fingerprint it ignores the installed version, so an
+// upgrade that is still vulnerable keeps the finding open.
+func (f HistoryFinding) lifetimeKey() string {
+	return findingLifetimeKey(f.Package, f.Advisory)
+}
+
+// HistorySummary is the trend analysis of the history
+type HistorySummary struct {
+	Runs       []HistoryEntry `json:"runs"`
+	Advisories []FixStat      `json:"advisories"`
+	Projects   []FixStat      `json:"projects"`
+}
+
+// FixStat aggregates how long the findings of an advisory or a project stayed open
+type FixStat struct {
+	Name          string        `json:"name"`
+	Package       string        `json:"package,omitempty"`
+	Severity      string        `json:"severity,omitempty"`
+	Fixed         int           `json:"fixed"`
+	Open          int           `json:"open"`
+	MeanTimeToFix time.Duration `json:"mean_time_to_fix"`
+	// OldestOpen is the age of the longest-open finding at the last run
+	OldestOpen time.Duration `json:"oldest_open"`
+	totalToFix time.Duration
+}
+
+// historyPath returns the history file in the reports directory
+func historyPath() string {
+	return filepath.Join(reportsDir, HistoryFileName)
+}
+
+// newHistoryEntry summarizes a finished scan for the history
+func newHistoryEntry(report *scanner.Report) HistoryEntry {
+	entry := HistoryEntry{
+		Time:     report.EndTime,
+		ScanID:   report.ScanID,
+		Projects: make([]HistoryProject, 0, len(report.Results)),
+	}
+	if abs, err := filepath.Abs(firstNonEmpty(report.TargetDir, ".")); err == nil {
+		entry.TargetDir = abs
+	}
+
+	for i := range report.Results {
+		result := &report.Results[i]
+		project := HistoryProject{
+			Project:  scanner.FindingProject(report.TargetDir, result.ProjectPath),
+			Status:   result.Status,
+			Scanned:  result.SecurityScan.Success,
+			Duration: result.Duration,
+			Counts:   make(map[string]int),
+		}
+		for _, vuln := range openFindings(project.Project, result) {
+			project.Counts[vuln.Severity]++
+			project.Findings = append(project.Findings, HistoryFinding{
+				Fingerprint: vuln.Fingerprint,
+				Advisory:    scanner.FindingAdvisory(vuln),
+				Package:     vuln.Package,
+				Severity:    vuln.Severity,
+			})
+		}
+		sort.Slice(project.Findings, func(a, b int) bool {
+			return project.Findings[a].Fingerprint < project.Findings[b].Fingerprint
+		})
+		entry.Projects = append(entry.Projects, project)
+	}
+	return entry
+}
+
+// appendHistory appends the report to the history as a single JSON line
+func appendHistory(path string, report *scanner.Report) error {
+	data, err := json.Marshal(newHistoryEntry(report))
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), scanner.DirPermSecure); err != nil {
+		return fmt.Errorf("failed to create reports directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, scanner.FilePermSecure)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append history: %w", err)
+	}
+	return nil
+}
+
+// readHistory reads the history in chronological order
+func readHistory(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	// プロジェクト数が多いと1行が大きくなるためバッファを広げる
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid history %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// summarizeHistory computes the mean time-to-fix per advisory and per project.
+// A finding counts as fixed at the first run that scanned its project
+// successfully without it; the last limit runs are kept for the trend.
+func summarizeHistory(entries []HistoryEntry, limit int) *HistorySummary {
+	type openFinding struct {
+		HistoryFinding
+		project string
+		since   time.Time
+	}
+
+	advisories := make(map[string]*FixStat)
+	projects := make(map[string]*FixStat)
+	statFor := func(stats map[string]*FixStat, name string) *FixStat {
+		if stats[name] == nil {
+			stats[name] = &FixStat{Name: name}
+		}
+		return stats[name]
+	}
+
+	open := make(map[string]openFinding)
+	for _, entry := range entries {
+		for _, project := range entry.Projects {
+			if !project.Scanned {
+				continue
+			}
+			key := entry.TargetDir + "\x00" + project.Project
+
+			current := make(map[string]bool, len(project.Findings))
+			for _, finding := range project.Findings {
+				id := key + "\x00" + finding.lifetimeKey()
+				current[id] = true
+				if _, known := open[id]; !known {
+					open[id] = openFinding{HistoryFinding: finding, project: project.Project, since: entry.Time}
+				}
+			}
+
+			for id, finding := range open {
+				if !strings.HasPrefix(id, key+"\x00") || current[id] {
+					continue
+				}
+				elapsed := entry.Time.Sub(finding.since)
+				for _, stat := range []*FixStat{
+					statFor(advisories, finding.Advisory), statFor(projects, finding.project),
+				} {
+					stat.Fixed++
+					stat.totalToFix += elapsed
+				}
+				advisory := advisories[finding.Advisory]
+				advisory.Package, advisory.Severity = finding.Package, finding.Severity
+				delete(open, id)
+			}
+		}
+	}
+
+	var last time.Time
+	if len(entries) > 0 {
+		last = entries[len(entries)-1].Time
+	}
+	for _, finding := range open {
+		age := last.Sub(finding.since)
+		advisory := statFor(advisories, finding.Advisory)
+		advisory.Package, advisory.Severity = finding.Package, finding.Severity
+		for _, stat := range []*FixStat{advisory, statFor(projects, finding.project)} {
+			stat.Open++
+			if age > stat.OldestOpen {
+				stat.OldestOpen = age
+			}
+		}
+	}
+
+	runs := entries
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return &HistorySummary{
+		Runs:       runs,
+		Advisories: sortFixStats(advisories),
+		Projects:   sortFixStats(projects),
+	}
+}
+
+// sortFixStats orders the stats slowest first: by mean time-to-fix, then by the
+// age of the oldest open finding
+func sortFixStats(stats map[string]*FixStat) []FixStat {
+	sorted := make([]FixStat, 0, len(stats))
+	for _, stat := range stats {
+		if stat.Fixed > 0 {
+			stat.MeanTimeToFix = stat.totalToFix / time.Duration(stat.Fixed)
+		}
+		sorted = append(sorted, *stat)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.MeanTimeToFix != b.MeanTimeToFix {
+			return a.MeanTimeToFix > b.MeanTimeToFix
+		}
+		if a.OldestOpen != b.OldestOpen {
+			return a.OldestOpen > b.OldestOpen
+		}
+		return a.Name < b.Name
+	})
+	return sorted
+}
+
+// totalCounts sums the project counts of a run by severity
+func (e HistoryEntry) totalCounts() map[string]int {
+	totals := make(map[string]int)
+	for _, project := range e.Projects {
+		for severity, count := range project.Counts {
+			totals[severity] += count
+		}
+	}
+	return totals
+}
+
+// formatAge formats a duration in days and hours for the history
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", d/(24*time.Hour), (d%(24*time.Hour))/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", d/time.Hour, (d%time.Hour)/time.Minute)
+	default:
+		return d.Round(time.Minute).String()
+	}
+}
+
+// newHistoryCommand creates the subcommand that shows the scan history trends
+func newHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show vulnerability trends and time-to-fix from the scan history",
+		Long: `レポートディレクトリのスキャン履歴（` + HistoryFileName + `）から、プロジェクト・重大度ごとの脆弱性数の推移、
+アドバイザリごとの平均修正時間、修正に時間のかかっているプロジェクトを表示します。`,
+		Args: cobra.NoArgs,
+		Run:  runHistory,
+	}
+
+	cmd.Flags().StringVar(&historyFormat, "format", ReportFormatTerminal,
+		"output format (terminal|json|html)")
+	cmd.Flags().StringVarP(&historyOutput, "output", "o", "",
+		"file to write the history to (default: stdout)")
+	cmd.Flags().SetAnnotation("format", configIgnoreAnnotation, []string{"true"})
+	cmd.Flags().IntVar(&historyLimit, "limit", 20,
+		"number of most recent runs shown in the trend (0 for all)")
+	return cmd
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	if err := validateOutputFormat(historyFormat); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	path := historyPath()
+	entries, err := readHistory(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		warningColor.Printf("⚠️  No scan history in %s yet\n", path)
+		return
+	}
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	summary := summarizeHistory(entries, historyLimit)
+
+	out, err := createOutput(historyOutput)
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	defer out.Close()
+
+	switch historyFormat {
+	case ReportFormatJSON:
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err == nil {
+			_, err = fmt.Fprintf(out, "%s\n", data)
+		}
+		if err != nil {
+			errorColor.Printf("❌ Failed to write history: %v\n", err)
+			os.Exit(ExitMisconfigured)
+		}
+	case ReportFormatHTML:
+		if _, err := io.WriteString(out, generateHistoryHTML(summary)); err != nil {
+			errorColor.Printf("❌ Failed to write history: %v\n", err)
+			os.Exit(ExitMisconfigured)
+		}
+	default:
+		printHistory(out, path, len(entries), summary)
+	}
+
+	if historyOutput != "" {
+		successColor.Printf("📄 History written to %s\n", historyOutput)
+	}
+}
+
+// printHistory prints the runs and the slowest advisories and projects
+func printHistory(out io.Writer, path string, total int, summary *HistorySummary) {
+	fmt.Fprintln(out, strings.Repeat("=", ReportSeparator))
+	infoColor.Fprintf(out, "📈 SCAN HISTORY - %s (%d run(s), showing %d)\n", path, total, len(summary.Runs))
+	fmt.Fprintln(out, strings.Repeat("=", ReportSeparator))
+
+	for _, run := range summary.Runs {
+		totals := run.totalCounts()
+		var counts []string
+		for _, severity := range historySeverities {
+			if totals[severity] > 0 || severity != scanner.SeverityMalware {
+				counts = append(counts, fmt.Sprintf("%s %d", severity, totals[severity]))
+			}
+		}
+		fmt.Fprintf(out, "  %s  %s  %d project(s)  %s\n", run.Time.Local().Format("2006-01-02 15:04"),
+			run.ScanID, len(run.Projects), strings.Join(counts, " · "))
+	}
+
+	if len(summary.Advisories) > 0 {
+		fmt.Fprintln(out)
+		infoColor.Fprintln(out, "⏱️  Mean time to fix per advisory:")
+		for _, stat := range summary.Advisories {
+			getSeverityColor(stat.Severity).Fprintf(out, "      %s", stat.Name)
+			fmt.Fprintf(out, " (%s) %s\n", stat.Package, formatFixStat(stat))
+		}
+	}
+	if len(summary.Projects) > 0 {
+		fmt.Fprintln(out)
+		infoColor.Fprintln(out, "🐢 Slowest projects:")
+		for _, stat := range summary.Projects {
+			fmt.Fprintf(out, "      %s %s\n", stat.Name, formatFixStat(stat))
+		}
+	}
+}
+
+// formatFixStat describes the time-to-fix and open findings of a stat
+func formatFixStat(stat FixStat) string {
+	var parts []string
+	if stat.Fixed > 0 {
+		parts = append(parts, fmt.Sprintf("mean %s to fix (%d fixed)", formatAge(stat.MeanTimeToFix), stat.Fixed))
+	}
+	if stat.Open > 0 {
+		parts = append(parts, fmt.Sprintf("%d open, oldest %s", stat.Open, formatAge(stat.OldestOpen)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// historySeverityColors are the chart colors matching the report's severity styles
+var historySeverityColors = map[string]string{
+	scanner.SeverityMalware:  "#0a0a0a",
+	scanner.SeverityCritical: "#ff3860",
+	scanner.SeverityHigh:     "#ff6348",
+	scanner.SeverityModerate: "#ffdd57",
+	scanner.SeverityLow:      "#209cee",
+}
+
+// generateHistoryHTML renders the trend page with the Bulma styling of the scan report
+func generateHistoryHTML(summary *HistorySummary) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>NPM Security Scanner History</title>
+    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/bulma@0.9.4/css/bulma.min.css">
+    <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/font-awesome/6.4.0/css/all.min.css">
+    <style>%s
+        .trend-chart { width: 100%%; height: 260px; }
+        .trend-table td, .trend-table th { white-space: nowrap; }</style>
+</head>
+<body>
+<section class="hero is-medium hero-gradient">
+    <div class="hero-body">
+        <div class="container">
+            <h1 class="title is-1 has-text-white">
+                <i class="fas fa-chart-line"></i> Scan History
+            </h1>
+            <h2 class="subtitle is-4 has-text-white-ter">%d run(s)</h2>
+        </div>
+    </div>
+</section>
+<section class="section">
+    <div class="container">
+        %s
+        %s
+        %s
+        %s
+    </div>
+</section>
+</body>
+</html>`,
+		generateBulmaCSS(), len(summary.Runs),
+		generateHistoryChartHTML(summary.Runs),
+		generateHistoryProjectsHTML(summary.Runs),
+		generateFixStatsHTML("fas fa-stopwatch", "Mean Time to Fix per Advisory", summary.Advisories, true),
+		generateFixStatsHTML("fas fa-hourglass-half", "Slowest Projects", summary.Projects, false))
+}
+
+// generateHistoryChartHTML draws the total findings per severity across runs as an SVG line chart
+func generateHistoryChartHTML(runs []HistoryEntry) string {
+	if len(runs) == 0 {
+		return `<div class="notification is-light">No scans recorded yet</div>`
+	}
+
+	const width, height, pad = 800.0, 240.0, 20.0
+	peak := 1
+	totals := make([]map[string]int, len(runs))
+	for i, run := range runs {
+		totals[i] = run.totalCounts()
+		for _, count := range totals[i] {
+			if count > peak {
+				peak = count
+			}
+		}
+	}
+
+	lines, legend := "", ""
+	for _, severity := range historySeverities {
+		var points []string
+		for i := range runs {
+			x := pad
+			if len(runs) > 1 {
+				x += float64(i) * (width - 2*pad) / float64(len(runs)-1)
+			}
+			y := height - pad - float64(totals[i][severity])*(height-2*pad)/float64(peak)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		lines += fmt.Sprintf(`
+                <polyline fill="none" stroke="%s" stroke-width="3" points="%s"><title>%s</title></polyline>`,
+			historySeverityColors[severity], strings.Join(points, " "), severity)
+		legend += fmt.Sprintf(`<span class="tag" style="background:%s;color:#fff">%s</span> `,
+			historySeverityColors[severity], severity)
+	}
+
+	return fmt.Sprintf(`
+        <div class="box">
+            <h3 class="title is-4"><i class="fas fa-chart-line"></i>&nbsp; Findings by Severity</h3>
+            <svg class="trend-chart" viewBox="0 0 %.0f %.0f" preserveAspectRatio="none">
+                <line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" stroke="#dbdbdb"/>%s
+            </svg>
+            <p>%s<span class="has-text-grey">peak %d · %s → %s</span></p>
+        </div>`,
+		width, height, pad, height-pad, width-pad, height-pad, lines, legend, peak,
+		runs[0].Time.Local().Format("2006-01-02"), runs[len(runs)-1].Time.Local().Format("2006-01-02"))
+}
+
+// generateHistoryProjectsHTML tabulates each project's counts by severity per run
+func generateHistoryProjectsHTML(runs []HistoryEntry) string {
+	if len(runs) == 0 {
+		return ""
+	}
+
+	var projects []string
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		for _, project := range run.Projects {
+			if !seen[project.Project] {
+				seen[project.Project] = true
+				projects = append(projects, project.Project)
+			}
+		}
+	}
+	sort.Strings(projects)
+
+	header := ""
+	for _, run := range runs {
+		header += fmt.Sprintf(`<th title="%s">%s</th>`,
+			html.EscapeString(run.ScanID), run.Time.Local().Format("01-02 15:04"))
+	}
+
+	rows := ""
+	for _, name := range projects {
+		cells := ""
+		for _, run := range runs {
+			cells += "<td>" + generateHistoryCellHTML(run, name) + "</td>"
+		}
+		rows += fmt.Sprintf(`
+                    <tr><th>%s</th>%s</tr>`, html.EscapeString(name), cells)
+	}
+
+	return fmt.Sprintf(`
+        <div class="box">
+            <h3 class="title is-4"><i class="fas fa-table"></i>&nbsp; Findings per Project</h3>
+            <div class="table-container">
+                <table class="table is-striped is-narrow trend-table">
+                    <thead><tr><th>Project</th>%s</tr></thead>
+                    <tbody>%s
+                    </tbody>
+                </table>
+            </div>
+        </div>`, header, rows)
+}
+
+// generateHistoryCellHTML shows a project's nonzero counts in a run as severity tags
+func generateHistoryCellHTML(run HistoryEntry, name string) string {
+	for _, project := range run.Projects {
+		if project.Project != name {
+			continue
+		}
+		cell := ""
+		for _, severity := range historySeverities {
+			if count := project.Counts[severity]; count > 0 {
+				cell += fmt.Sprintf(`<span class="tag" style="background:%s;color:#fff" title="%s">%d</span> `,
+					historySeverityColors[severity], severity, count)
+			}
+		}
+		if cell == "" && project.Scanned {
+			return `<span class="tag is-success is-light">0</span>`
+		}
+		if !project.Scanned {
+			cell += fmt.Sprintf(`<span class="tag is-light">%s</span>`, html.EscapeString(project.Status))
+		}
+		return cell
+	}
+	return `<span class="has-text-grey-light">-</span>`
+}
+
+// generateFixStatsHTML tabulates the time-to-fix stats, slowest first
+func generateFixStatsHTML(icon, title string, stats []FixStat, advisories bool) string {
+	if len(stats) == 0 {
+		return ""
+	}
+
+	rows := ""
+	for _, stat := range stats {
+		name := html.EscapeString(stat.Name)
+		if advisories {
+			name += fmt.Sprintf(` <span class="has-text-grey">%s</span> <span class="tag is-light">%s</span>`,
+				html.EscapeString(stat.Package), html.EscapeString(stat.Severity))
+		}
+		mean := "-"
+		if stat.Fixed > 0 {
+			mean = formatAge(stat.MeanTimeToFix)
+		}
+		oldest := "-"
+		if stat.Open > 0 {
+			oldest = formatAge(stat.OldestOpen)
+		}
+		rows += fmt.Sprintf(`
+                    <tr><td>%s</td><td>%s</td><td>%d</td><td>%d</td><td>%s</td></tr>`,
+			name, mean, stat.Fixed, stat.Open, oldest)
+	}
+
+	return fmt.Sprintf(`
+        <div class="box">
+            <h3 class="title is-4"><i class="%s"></i>&nbsp; %s</h3>
+            <table class="table is-fullwidth is-striped">
+                <thead><tr><th>Name</th><th>Mean time to fix</th><th>Fixed</th><th>Open</th><th>Oldest open</th></tr></thead>
+                <tbody>%s
+                </tbody>
+            </table>
+        </div>`, icon, title, rows)
+}
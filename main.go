@@ -2,17 +2,57 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/pality/npm-security-scanner/scanner"
 	"github.com/spf13/cobra"
 )
 
 const (
-	appName    = "npm-security-scanner"
-	appVersion = "1.3.0"
+	appName    = scanner.ToolName
+	appVersion = scanner.Version
+)
+
+// options holds the scan options bound to command-line flags
+var options scanner.Options
+
+// discovery holds the project discovery options bound to command-line flags
+var discovery scanner.DiscoveryOptions
+
+var (
+	// assumeYes answers every confirmation prompt with yes (--yes)
+	assumeYes bool
+	// nonInteractive never reads stdin and uses each prompt's default answer
+	// (--non-interactive, or automatically when stdin is not a terminal)
+	nonInteractive bool
+	// noTUI disables the terminal UI for project selection and progress (--no-tui)
+	noTUI bool
+	// tuiActive is set when the projects were selected in the terminal UI and
+	// the scan progress is shown there too
+	tuiActive bool
+	// iocFiles are additional IOC lists merged into the bundled list (--ioc)
+	iocFiles []string
+	// suppressionsPath is the accepted-risk suppression file (--suppressions)
+	suppressionsPath string
+	// failOn is the lowest severity that makes the scanner exit with ExitFindings
+	failOn string
+	// recordCommandsPath is the fixture file every external command and its
+	// output is recorded to for replay in tests (--record-commands)
+	recordCommandsPath string
+	// eventsPath is the file the typed scan events are written to as NDJSON (--events)
+	eventsPath string
+	// stdinReader is shared by all prompts so buffered input is not lost between questions
+	stdinReader = bufio.NewReader(os.Stdin)
 )
 
 var (
@@ -21,9 +61,19 @@ var (
 	errorColor   = color.New(color.FgRed, color.Bold)
 	warningColor = color.New(color.FgYellow, color.Bold)
 	infoColor    = color.New(color.FgCyan, color.Bold)
+	malwareColor = color.New(color.FgWhite, color.BgRed, color.Bold)
 )
 
 func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		errorColor.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+}
+
+// newRootCommand creates the scanner command with its flags bound to the
+// package-level options and the config file loaded before every subcommand
+func newRootCommand() *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   appName + " [target-directory]",
 		Short: "NPM Security Scanner using Safe Chain",
@@ -33,65 +83,413 @@ Safe Chainを使用して一括でセキュリティスキャンを実行しま
 		Version: appVersion,
 		Args:    cobra.MaximumNArgs(1),
 		Run:     runScanner,
+		// エラーはmainで表示する
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return loadConfig(cmd, args)
+		},
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		errorColor.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
+	rootCmd.Flags().BoolVar(&options.ReadOnly, "read-only", false,
+		"audit existing lockfiles without deleting node_modules, installing or running npm audit fix")
+	rootCmd.Flags().BoolVar(&options.Fix, "fix", false,
+		"show the npm audit fix plan per project and apply it after confirmation (rolled back on failure)")
+	rootCmd.Flags().StringVar(&options.InstallStrategy, "install-strategy", scanner.DefaultInstallStrategy,
+		"how dependencies are reinstalled before auditing: ci-ignore-scripts (no lifecycle scripts), ci, install "+
+			"or package-lock-only (node_modules is left untouched)")
+	rootCmd.Flags().StringVar(&options.AuditLevel, "audit-level", scanner.DefaultAuditLevel,
+		"lowest severity reported by the package manager's audit (low|moderate|high|critical)")
+	rootCmd.Flags().IntVarP(&options.Jobs, "jobs", "j", 1,
+		"number of projects to scan concurrently")
+	rootCmd.Flags().DurationVar(&options.InstallTimeout, "install-timeout", scanner.DefaultInstallTimeout,
+		"timeout for each npm install step (0 disables)")
+	rootCmd.Flags().DurationVar(&options.AuditTimeout, "audit-timeout", scanner.DefaultAuditTimeout,
+		"timeout for each npm audit step (0 disables)")
+	rootCmd.Flags().DurationVar(&options.FixTimeout, "fix-timeout", scanner.DefaultFixTimeout,
+		"timeout for each npm audit fix step (0 disables)")
+	addReportFlags(rootCmd.Flags())
+	rootCmd.Flags().BoolVar(&noTUI, "no-tui", false,
+		"list the projects and ask y/N instead of opening the interactive project selection and progress screen")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false,
+		"answer yes to all prompts, including applying fixes")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false,
+		"never prompt; proceed with the scan and decline optional actions (default when stdin is not a TTY)")
+	rootCmd.PersistentFlags().StringVar(&failOn, "fail-on", "none",
+		"exit with code 1 when unfixed findings at or above this severity exist (low|moderate|high|critical|none)")
+
+	rootCmd.PersistentFlags().StringSliceVar(&iocFiles, "ioc", nil,
+		"additional IOC list (JSON) merged into the bundled known-malicious package list (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&suppressionsPath, "suppressions", "",
+		"suppression file (YAML/JSON) of accepted-risk findings with reason, owner and expiry")
+	rootCmd.PersistentFlags().StringVar(&baselinePath, "baseline", "",
+		"baseline file written by the baseline command; only findings missing from it are reported and gate the exit code")
+
+	rootCmd.PersistentFlags().StringSliceVar(&discovery.Include, "include", nil,
+		"only scan projects whose path relative to the target matches this glob (repeatable, ** matches any depth)")
+	rootCmd.PersistentFlags().StringSliceVar(&discovery.Exclude, "exclude", nil,
+		"skip directories matching this glob; a pattern without / matches the directory name at any depth (repeatable)")
+	rootCmd.PersistentFlags().IntVar(&discovery.MaxDepth, "max-depth", 0,
+		"maximum directory depth below the target to search for projects (0 for unlimited)")
+	rootCmd.PersistentFlags().BoolVar(&discovery.NoDefaultExcludes, "no-default-excludes", false,
+		"also search "+strings.Join(scanner.DefaultExcludes, ", ")+" directories")
+	rootCmd.PersistentFlags().BoolVar(&discovery.Gitignore, "gitignore", false,
+		"skip directories ignored by .gitignore files (.npmscanignore files are always honoured)")
+
+	rootCmd.PersistentFlags().StringVar(&recordCommandsPath, "record-commands", "",
+		"record every external command and its output to this JSONL fixture file for replay in tests")
+	_ = rootCmd.PersistentFlags().MarkHidden("record-commands")
+	rootCmd.PersistentFlags().StringVar(&eventsPath, "events", "",
+		"write typed scan progress events as NDJSON to this file; - writes them to stdout and all other output to stderr")
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "",
+		"project config file (default: "+ConfigFileName+" searched upward from the target directory)")
+	configOnlyFlags = newConfigOnlyFlags()
+
+	rootCmd.AddCommand(newOfflineCommand())
+	rootCmd.AddCommand(newConfigCommand())
+	rootCmd.AddCommand(newBaselineCommand())
+	rootCmd.AddCommand(newDiffCommand())
+	rootCmd.AddCommand(newHistoryCommand())
+	return rootCmd
 }
 
-func runScanner(_ *cobra.Command, args []string) {
+func runScanner(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	// ターゲットディレクトリの決定
 	targetDir := "."
 	if len(args) > 0 {
 		targetDir = args[0]
 	}
 
+	routeProgressOutput()
 	infoColor.Printf("🔍 NPM Security Scanner v%s\n", appVersion)
 	infoColor.Printf("Target directory: %s\n\n", targetDir)
 
+	if err := validateOptions(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	detectNonInteractive()
+
+	if options.ReadOnly {
+		infoColor.Println("📖 Read-only mode: projects will not be modified")
+	}
+
+	var err error
+	if options.IOCs, err = loadIOCs(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if options.Suppressions, err = loadSuppressionFile(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if options.Baseline, err = loadBaselineFile(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	options.TargetDir = targetDir
+	if err := setupCommandRunner(targetDir); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if err := setupEventStream(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
 	// Step 1: Safe Chainのインストール確認
-	if err := checkSafeChainInstallation(); err != nil {
-		if err.Error() == "terminal restart required" {
+	if err := checkSafeChainInstallation(ctx); err != nil {
+		if errors.Is(err, errTerminalRestartRequired) {
+			// 何もスキャンしていないのでCIで成功扱いにならないようにする
 			infoColor.Println("👋 Exiting for terminal restart...")
-			os.Exit(0)
+			os.Exit(ExitMisconfigured)
 		}
 		errorColor.Printf("❌ Safe Chain setup failed: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitMisconfigured)
 	}
 
 	// Step 2: NPMプロジェクトの検索
-	projects, err := findNpmProjects(targetDir)
+	projects, err := discoverProjects(ctx, targetDir)
 	if err != nil {
 		errorColor.Printf("❌ Failed to find NPM projects: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitMisconfigured)
 	}
 
 	if len(projects) == 0 {
 		warningColor.Println("⚠️  No NPM projects found in the specified directory")
-		return
+		os.Exit(ExitMisconfigured)
 	}
 
-	// Step 3: プロジェクトリストの表示と確認
-	if !showProjectsAndConfirm(projects) {
+	// Step 3: プロジェクトの選択と確認（端末ではTUIで選択し、それ以外は一覧を表示してy/Nで確認）
+	tuiActive = useTUI()
+	var confirmed bool
+	if tuiActive {
+		projects, confirmed = selectProjectsTUI(projects)
+	} else {
+		confirmed = showProjectsAndConfirm(projects)
+	}
+	if !confirmed {
 		infoColor.Println("🚫 Scan canceled by user")
-		return
+		os.Exit(ExitMisconfigured)
 	}
 
 	// Step 4: スキャン実行
-	scanProjects(projects)
+	if interrupted := scanWithInterrupt(ctx, projects); interrupted {
+		warningColor.Println("⚠️  Scan interrupted")
+		os.Exit(ExitInterrupted)
+	}
+
+	os.Exit(reportExitCode(currentReport))
+}
+
+// reportExitCode maps the finished report to the documented exit codes.
+// Scan errors take precedence over findings because the findings are incomplete.
+func reportExitCode(report *scanner.Report) int {
+	if report == nil {
+		return ExitClean
+	}
+
+	// 既知のマルウェアはスキャンエラーや--fail-onより優先する
+	if count := report.CountFindingsAtOrAbove(scanner.SeverityMalware); count > 0 {
+		malwareColor.Printf("🦠 %d known-malicious package(s) or file(s) detected", count)
+		fmt.Fprintln(color.Output)
+		return ExitMalware
+	}
+
+	if report.ErrorCount > 0 {
+		errorColor.Printf("❌ %d project(s) failed to scan\n", report.ErrorCount)
+		return ExitScanErrors
+	}
+
+	if failOn != "none" {
+		if count := report.CountFindingsAtOrAbove(failOn); count > 0 {
+			errorColor.Printf("🚨 %d unfixed finding(s) at or above %s severity\n", count, failOn)
+			return ExitFindings
+		}
+	}
 
 	successColor.Println("✅ All projects scanned successfully!")
+	return ExitClean
 }
 
-// askForConfirmation prompts the user for yes/no confirmation
-func askForConfirmation(question string) bool {
-	reader := bufio.NewReader(os.Stdin)
+// scanWithInterrupt scans the projects and cancels the scan on Ctrl-C/SIGTERM.
+// Running child processes are killed and a partial report is still written.
+// It reports whether the scan was interrupted.
+func scanWithInterrupt(ctx context.Context, projects []scanner.Project) bool {
+	scanCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// 2回目のCtrl-Cでは即座に終了できるようにシグナル処理を戻す
+		<-scanCtx.Done()
+		stop()
+	}()
+
+	var report *scanner.Report
+	var err error
+	if tuiActive {
+		report, err = scanWithTUI(scanCtx, stop, projects, scanOptions())
+	} else {
+		report, err = scanner.ScanAll(scanCtx, projects, scanOptions())
+	}
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if report.Interrupted {
+		warningColor.Println("⚠️  Scan interrupted, writing partial report...")
+	}
+
+	currentReport = report
+	showScanResults()
+	return ctx.Err() == nil && scanCtx.Err() != nil
+}
+
+// scanOptions returns the flag-bound options with the progress log and fix
+// confirmations wired to the terminal
+func scanOptions() scanner.Options {
+	opts := options
+	opts.Log = color.Output
+	opts.Confirm = func(question string) bool {
+		return askForConfirmation(question, false)
+	}
+	// CIなど非対話モードではデモスキャンをエラーとして終了コードに反映する
+	opts.DemoScan = !nonInteractive
+	return opts
+}
+
+// discoverProjects searches the target directory for projects and lists them
+// together with the workspace members grouped under their roots
+func discoverProjects(ctx context.Context, targetDir string) ([]scanner.Project, error) {
+	infoColor.Printf("🔍 Searching for NPM projects in %s...\n", targetDir)
+
+	projects, err := scanner.Discover(ctx, targetDir, discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	memberCount := 0
+	for _, project := range projects {
+		infoColor.Printf("  📁 Found: %s (%s)\n", project.Dir, project.PackageManager)
+		if len(project.Members) > 0 {
+			memberCount += len(project.Members)
+			infoColor.Printf("  🗂️  Workspace %s groups %d member(s):\n", project.Dir, len(project.Members))
+			for _, member := range project.Members {
+				fmt.Fprintf(color.Output, "      ↳ %s (%s)\n", member.Name, member.Dir)
+			}
+		}
+	}
+
+	if memberCount > 0 {
+		infoColor.Printf("🎯 Found %d NPM project(s) (%d workspace member(s) grouped under their roots)\n\n",
+			len(projects), memberCount)
+	} else {
+		infoColor.Printf("🎯 Found %d NPM project(s)\n\n", len(projects))
+	}
+	return projects, nil
+}
+
+// showProjectsAndConfirm displays the list of projects and asks for user confirmation
+func showProjectsAndConfirm(projects []scanner.Project) bool {
+	showProjects(projects)
+	return askForConfirmation("Do you want to proceed with the security scan?", true)
+}
+
+// showProjects displays the list of projects to be scanned
+func showProjects(projects []scanner.Project) {
+	infoColor.Println("📋 NPM Projects to be scanned:")
+	for i, project := range projects {
+		fmt.Fprintf(color.Output, "  %d. %s\n", i+1, project.Dir)
+	}
+	fmt.Fprintln(color.Output)
+}
+
+// setupCommandRunner runs external commands directly, or through a recorder
+// writing them to the --record-commands fixture file
+func setupCommandRunner(targetDir string) error {
+	options.Runner = nil
+	if recordCommandsPath == "" {
+		return nil
+	}
+	runner, err := scanner.NewRecordingRunner(scanner.ExecRunner{}, targetDir, recordCommandsPath)
+	if err != nil {
+		return err
+	}
+	options.Runner = runner
+	infoColor.Printf("🎙️  Recording external commands to %s\n", recordCommandsPath)
+	return nil
+}
+
+// setupEventStream writes the typed scan events as NDJSON to the --events file,
+// or to stdout for "-"
+func setupEventStream() error {
+	options.Events = nil
+	switch eventsPath {
+	case "":
+		return nil
+	case "-":
+		options.Events = scanner.NDJSONEvents(os.Stdout)
+		return nil
+	}
+	// イベントは1件ずつバッファせずに書き込むため、os.Exitで終了してもファイルに残る
+	file, err := os.OpenFile(eventsPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, scanner.FilePermReadable)
+	if err != nil {
+		return fmt.Errorf("failed to create event stream: %w", err)
+	}
+	options.Events = scanner.NDJSONEvents(file)
+	infoColor.Printf("📡 Writing scan events to %s\n", eventsPath)
+	return nil
+}
+
+// loadIOCs loads the bundled IOC list merged with any --ioc files
+func loadIOCs() (*scanner.IOCDatabase, error) {
+	db, err := scanner.LoadIOCDatabase(iocFiles)
+	if err != nil {
+		return nil, err
+	}
+	infoColor.Printf("🦠 Loaded IOC lists: %s\n", strings.Join(db.Sources, ", "))
+	return db, nil
+}
+
+// loadSuppressionFile loads the --suppressions file and warns about expired entries.
+// It returns nil when no file is configured.
+func loadSuppressionFile() (*scanner.SuppressionList, error) {
+	if suppressionsPath == "" {
+		return nil, nil
+	}
+	list, err := scanner.LoadSuppressions(suppressionsPath)
+	if err != nil {
+		return nil, err
+	}
+	infoColor.Printf("🔕 Loaded %d suppression(s) from %s\n", list.Len(), suppressionsPath)
+	for _, s := range list.Expired(time.Now()) {
+		warningColor.Printf("⚠️  Suppression of %s expired on %s (owner %s); matching findings are reported again\n",
+			firstNonEmpty(s.ID, s.Package), s.Expires, s.Owner)
+	}
+	return list, nil
+}
+
+// validateOptions rejects flag combinations that cannot be honoured
+func validateOptions() error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	if assumeYes && nonInteractive {
+		return errors.New("--yes cannot be combined with --non-interactive")
+	}
+	if err := validateReportFormats(reportFormats); err != nil {
+		return err
+	}
+	if eventsPath == "-" && reportOutputPath == "-" {
+		return errors.New("--events - cannot be combined with --output -; only one of them can write to stdout")
+	}
+
+	failOn = strings.ToLower(failOn)
+	if failOn != "none" && scanner.SeverityRank(failOn) == 0 {
+		return fmt.Errorf("invalid --fail-on severity %q (expected low, moderate, high, critical or none)", failOn)
+	}
+	return nil
+}
+
+// detectNonInteractive switches to non-interactive mode when stdin is not a
+// terminal, e.g. in CI pipelines, unless --yes was given
+func detectNonInteractive() {
+	if assumeYes || nonInteractive {
+		return
+	}
+	if fd := os.Stdin.Fd(); isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd) {
+		return
+	}
+	nonInteractive = true
+	infoColor.Println("🤖 stdin is not a terminal, running non-interactively")
+}
+
+// askForConfirmation prompts the user for yes/no confirmation. With --yes it
+// always answers yes; in non-interactive mode it returns defaultAnswer without
+// reading stdin.
+func askForConfirmation(question string, defaultAnswer bool) bool {
+	if assumeYes {
+		infoColor.Printf("%s [y/N]: y (--yes)\n", question)
+		return true
+	}
+	if nonInteractive {
+		answer := "n"
+		if defaultAnswer {
+			answer = "y"
+		}
+		infoColor.Printf("%s [y/N]: %s (non-interactive)\n", question, answer)
+		return defaultAnswer
+	}
 
 	for {
 		infoColor.Printf("%s [y/N]: ", question)
-		response, err := reader.ReadString('\n')
+		response, err := stdinReader.ReadString('\n')
 		if err != nil {
 			log.Printf("Error reading input: %v", err)
 			return false
@@ -1,85 +1,65 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
-)
+	"time"
 
-func TestFindNpmProjects(t *testing.T) {
-	// テスト用の一時ディレクトリを作成
-	tempDir := t.TempDir()
-
-	// テストケース1: package.jsonがあるプロジェクト
-	project1 := filepath.Join(tempDir, "project1")
-	if err := os.MkdirAll(project1, 0755); err != nil {
-		t.Fatalf("Failed to create test directory: %v", err)
-	}
-
-	packageJson1 := filepath.Join(project1, "package.json")
-	if err := os.WriteFile(packageJson1, []byte(`{"name": "test-project1"}`), 0644); err != nil {
-		t.Fatalf("Failed to create package.json: %v", err)
-	}
+	"github.com/fatih/color"
+	"github.com/pality/npm-security-scanner/scanner"
+)
 
-	// テストケース2: ネストされたプロジェクト
-	project2 := filepath.Join(tempDir, "nested", "project2")
-	if err := os.MkdirAll(project2, 0755); err != nil {
-		t.Fatalf("Failed to create nested test directory: %v", err)
+func TestCheckCommand(t *testing.T) {
+	// 存在するコマンドのテスト（lsはほぼすべてのUnix系システムに存在）
+	if err := checkCommand(scanner.ExecRunner{}, "ls"); err != nil {
+		t.Errorf("Expected 'ls' command to be available, got error: %v", err)
 	}
 
-	packageJson2 := filepath.Join(project2, "package.json")
-	if err := os.WriteFile(packageJson2, []byte(`{"name": "test-project2"}`), 0644); err != nil {
-		t.Fatalf("Failed to create nested package.json: %v", err)
+	// 存在しないコマンドのテスト
+	if err := checkCommand(scanner.ExecRunner{}, "non-existent-command-12345"); err == nil {
+		t.Errorf("Expected error for non-existent command, but got nil")
 	}
+}
 
-	// テストケース3: package.jsonがないディレクトリ
-	emptyDir := filepath.Join(tempDir, "empty")
-	if err := os.MkdirAll(emptyDir, 0755); err != nil {
-		t.Fatalf("Failed to create empty directory: %v", err)
+func TestCheckSafeChainInstallation(t *testing.T) {
+	// インストールが成功する記録を用意し、自動インストールされた場合に検出できるようにする
+	root := t.TempDir()
+	fixture := filepath.Join(root, "commands.jsonl")
+	if err := os.WriteFile(fixture, []byte(
+		`{"name":"npm","args":["install","-g","`+scanner.DefaultSafeChainPackage+`"],"output":"added 1 package"}`+"\n"+
+			`{"name":"`+scanner.DefaultSafeChainCommand+`","args":["setup"]}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
 	}
-
-	// テスト実行
-	projects, err := findNpmProjects(tempDir)
+	runner, err := scanner.LoadReplayRunner(root, fixture)
 	if err != nil {
-		t.Fatalf("findNpmProjects failed: %v", err)
-	}
-
-	// 結果の検証
-	expectedCount := 2
-	if len(projects) != expectedCount {
-		t.Errorf("Expected %d projects, got %d", expectedCount, len(projects))
+		t.Fatalf("LoadReplayRunner failed: %v", err)
 	}
 
-	// プロジェクトパスの検証
-	foundProject1 := false
-	foundProject2 := false
+	defer func(original scanner.Options) { options = original }(options)
+	defer func(yes, non bool) { assumeYes, nonInteractive = yes, non }(assumeYes, nonInteractive)
+	options.Runner = runner
+	options.SafeChainPackage, options.SafeChainCommand = scanner.DefaultSafeChainPackage, scanner.DefaultSafeChainCommand
 
-	for _, project := range projects {
-		switch project {
-		case project1:
-			foundProject1 = true
-		case project2:
-			foundProject2 = true
-		}
-	}
-
-	if !foundProject1 {
-		t.Errorf("project1 not found in results")
-	}
-	if !foundProject2 {
-		t.Errorf("project2 not found in results")
+	tests := []struct {
+		yes, nonInteractive bool
+	}{
+		{yes: true},
+		{nonInteractive: true},
 	}
-}
-
-func TestCheckCommand(t *testing.T) {
-	// 存在するコマンドのテスト（lsはほぼすべてのUnix系システムに存在）
-	if err := checkCommand("ls"); err != nil {
-		t.Errorf("Expected 'ls' command to be available, got error: %v", err)
-	}
-
-	// 存在しないコマンドのテスト
-	if err := checkCommand("non-existent-command-12345"); err == nil {
-		t.Errorf("Expected error for non-existent command, but got nil")
+	for _, tt := range tests {
+		assumeYes, nonInteractive = tt.yes, tt.nonInteractive
+		err := checkSafeChainInstallation(context.Background())
+		if !errors.Is(err, errSafeChainNotInstalled) {
+			t.Errorf("--yes=%v non-interactive=%v: expected errSafeChainNotInstalled without installing, got %v",
+				tt.yes, tt.nonInteractive, err)
+		}
 	}
 }
 
@@ -139,70 +119,518 @@ func TestGetAbsolutePath(t *testing.T) {
 	}
 }
 
-func TestRemoveNodeModules(t *testing.T) {
-	// テスト用の一時ディレクトリを作成
+func TestReportExitCode(t *testing.T) {
+	report := &scanner.Report{
+		Results: []scanner.ScanResult{{
+			Status: scanner.StatusSuccess,
+			Vulnerabilities: []scanner.Vulnerability{
+				{Package: "lodash", Severity: scanner.SeverityHigh},
+				{Package: "minimist", Severity: scanner.SeverityCritical, Fixed: true},
+			},
+		}},
+		SuccessCount: 1,
+	}
+
+	tests := []struct {
+		failOn   string
+		expected int
+	}{
+		{"none", ExitClean},
+		{scanner.SeverityCritical, ExitClean}, // 修正済みの脆弱性は対象外
+		{scanner.SeverityHigh, ExitFindings},
+		{scanner.SeverityLow, ExitFindings},
+	}
+
+	defer func(original string) { failOn = original }(failOn)
+	for _, tt := range tests {
+		failOn = tt.failOn
+		if code := reportExitCode(report); code != tt.expected {
+			t.Errorf("--fail-on %s: expected exit code %d, got %d", tt.failOn, tt.expected, code)
+		}
+	}
+
+	// スキャンエラーは検出結果より優先される
+	failOn = scanner.SeverityLow
+	report.ErrorCount = 1
+	if code := reportExitCode(report); code != ExitScanErrors {
+		t.Errorf("Expected exit code %d for scan errors, got %d", ExitScanErrors, code)
+	}
+
+	// マルウェアは--fail-on noneやスキャンエラーより優先される
+	failOn = "none"
+	report.Results[0].Vulnerabilities = append(report.Results[0].Vulnerabilities,
+		scanner.Vulnerability{Type: scanner.FindingMalware, Package: "chalk", Severity: scanner.SeverityMalware})
+	if code := reportExitCode(report); code != ExitMalware {
+		t.Errorf("Expected exit code %d for malware, got %d", ExitMalware, code)
+	}
+
+	// 非対話モードでSafe Chainがない場合、デモスキャンは成功扱いにならない
+	root := t.TempDir()
+	project := filepath.Join(root, "app")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"package.json":      `{"name": "app"}`,
+		"package-lock.json": `{"name": "app", "lockfileVersion": 3, "packages": {"": {"name": "app"}}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(project, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fixture := filepath.Join(root, "commands.jsonl")
+	if err := os.WriteFile(fixture, []byte(`{"dir":"app","name":"npm","args":["ci","--ignore-scripts"]}`+"\n"),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	runner, err := scanner.LoadReplayRunner(root, fixture)
+	if err != nil {
+		t.Fatalf("LoadReplayRunner failed: %v", err)
+	}
+
+	defer func(original bool) { nonInteractive = original }(nonInteractive)
+	nonInteractive = true
+	opts := scanOptions()
+	opts.Log, opts.Runner, opts.TargetDir = io.Discard, runner, root
+	demo, err := scanner.ScanAll(context.Background(), []scanner.Project{{Dir: project}}, opts)
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+	if result := demo.Results[0]; !result.NpmInstall.Success ||
+		!strings.Contains(result.SecurityScan.Error, "not audited") {
+		t.Errorf("Expected the demo scan to fail after install, got %+v", result)
+	}
+	if code := reportExitCode(demo); code != ExitScanErrors {
+		t.Errorf("Expected exit code %d for a non-interactive demo scan, got %d", ExitScanErrors, code)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
 	tempDir := t.TempDir()
+	userDir := filepath.Join(tempDir, "user")
+	projectDir := filepath.Join(tempDir, "repo")
+	files := map[string]string{
+		"user/" + ConfigFileName: "jobs: 4\nfail_on: low\naudit:\n  level: low\n",
+		"repo/" + ConfigFileName: `discovery:
+  include: ["apps/*"]
+  exclude: [legacy, "**/tmp"]
+  max_depth: 3
+  no_default_excludes: true
+install:
+  strategy: ci
+audit:
+  level: high
+fail_on: high
+reports:
+  dir: /tmp/elsewhere
+  formats: [json]
+safe_chain:
+  package: "@aikidosec/safe-chain"
+`,
+		"repo/apps/web/package.json": `{}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	t.Setenv("HOME", userDir)
+	t.Setenv("NPM_SECURITY_SCANNER_JOBS", "8")
+	// 空の環境変数もプロジェクト設定を上書きする
+	t.Setenv("NPM_SECURITY_SCANNER_DISCOVERY_EXCLUDE", "")
+
+	// フラグに束縛されたパッケージ変数をテスト後にデフォルトへ戻す
+	t.Cleanup(func() { newRootCommand() })
+
+	var output strings.Builder
+	cmd := newRootCommand()
+	cmd.SetOut(&output)
+	cmd.SetArgs([]string{"config", "print", filepath.Join(projectDir, "apps", "web"), "--fail-on", "critical"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config print failed: %v", err)
+	}
+
+	// 環境変数は設定ファイルより、フラグは環境変数より優先される
+	if options.Jobs != 8 || failOn != scanner.SeverityCritical {
+		t.Errorf("Expected jobs 8 from env and fail-on critical from flag, got %d and %s", options.Jobs, failOn)
+	}
+	if strings.Join(reportFormats, ",") != ReportFormatJSON {
+		t.Errorf("Unexpected report formats from the project config: %v", reportFormats)
+	}
+	// 走査対象で見つけたプロジェクト設定はスキャンを弱める設定、プロジェクトを隠す設定、出力先を変更できない
+	if len(discovery.Include) != 0 || len(discovery.Exclude) != 0 || discovery.MaxDepth != 0 ||
+		discovery.NoDefaultExcludes || reportsDir != ReportsDirName {
+		t.Errorf("Expected discovery and report settings from the defaults, got %+v and reports dir %s",
+			discovery, reportsDir)
+	}
+	if options.InstallStrategy != scanner.DefaultInstallStrategy || options.AuditLevel != scanner.SeverityLow ||
+		options.SafeChainPackage != scanner.DefaultSafeChainPackage {
+		t.Errorf("Expected restricted settings from the user config or defaults, got strategy %s, audit level %s, package %s",
+			options.InstallStrategy, options.AuditLevel, options.SafeChainPackage)
+	}
 
-	// node_modulesディレクトリを作成
-	nodeModulesDir := filepath.Join(tempDir, "node_modules")
-	if err := os.MkdirAll(nodeModulesDir, 0755); err != nil {
-		t.Fatalf("Failed to create node_modules directory: %v", err)
+	printed := output.String()
+	for _, expected := range []string{
+		"fail_on: critical # flag (--fail-on)",
+		"jobs: 8 # env (NPM_SECURITY_SCANNER_JOBS)",
+		"exclude: [] # env (NPM_SECURITY_SCANNER_DISCOVERY_EXCLUDE)",
+		"  strategy: ci-ignore-scripts # default",
+		"  level: low # user config",
+		"  timeout: 2m0s # default",
+		"formats: [json]",
+		"Ignored from the project config: discovery.include, discovery.max_depth, discovery.no_default_excludes, " +
+			"install.strategy, audit.level, reports.dir, safe_chain.package",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("Expected config print output to contain %q:\n%s", expected, printed)
+		}
 	}
 
-	// テスト用ファイルを作成
-	testFile := filepath.Join(nodeModulesDir, "test-package", "index.js")
-	if err := os.MkdirAll(filepath.Dir(testFile), 0755); err != nil {
-		t.Fatalf("Failed to create test package directory: %v", err)
+	// 環境変数がなければプロジェクト設定のexcludeも無視される
+	os.Unsetenv("NPM_SECURITY_SCANNER_DISCOVERY_EXCLUDE")
+	cmd = newRootCommand()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"config", "print", projectDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config print failed: %v", err)
 	}
-	if err := os.WriteFile(testFile, []byte("console.log('test');"), 0644); err != nil {
-		t.Fatalf("Failed to create test file: %v", err)
+	if len(discovery.Exclude) != 0 || !strings.Contains(strings.Join(ignoredProjectSettings, ","), "discovery.exclude") {
+		t.Errorf("Expected discovery.exclude to be ignored, got %v (ignored %v)", discovery.Exclude, ignoredProjectSettings)
 	}
 
-	// node_modulesが存在することを確認
-	if _, err := os.Stat(nodeModulesDir); os.IsNotExist(err) {
-		t.Fatalf("node_modules directory should exist before test")
+	// --configで明示したファイルは制限されない
+	cmd = newRootCommand()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"config", "print", projectDir, "--config", filepath.Join(projectDir, ConfigFileName)})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config print with --config failed: %v", err)
+	}
+	if options.InstallStrategy != scanner.InstallStrategyCI || options.AuditLevel != scanner.SeverityHigh ||
+		options.SafeChainPackage != "@aikidosec/safe-chain" {
+		t.Errorf("Expected settings from --config, got strategy %s, audit level %s, package %s",
+			options.InstallStrategy, options.AuditLevel, options.SafeChainPackage)
+	}
+	if discovery.MaxDepth != 3 || len(discovery.Exclude) != 2 || !discovery.NoDefaultExcludes ||
+		reportsDir != "/tmp/elsewhere" {
+		t.Errorf("Expected discovery and report settings from --config, got %+v and reports dir %s", discovery, reportsDir)
 	}
 
-	// removeNodeModules実行
-	if err := removeNodeModules(tempDir); err != nil {
-		t.Errorf("removeNodeModules failed: %v", err)
+	// 未知のキーは設定ミスとしてエラーにする
+	if err := os.WriteFile(filepath.Join(userDir, ConfigFileName), []byte("bogus: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
 	}
+	cmd = newRootCommand()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"config", "print", projectDir})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `unknown setting "bogus"`) {
+		t.Errorf("Expected an unknown setting error, got %v", err)
+	}
+}
 
-	// node_modulesが削除されていることを確認
-	if _, err := os.Stat(nodeModulesDir); !os.IsNotExist(err) {
-		t.Errorf("node_modules directory should be removed")
+func TestDiffReports(t *testing.T) {
+	lodash := scanner.Vulnerability{Severity: scanner.SeverityModerate, Package: "lodash", Version: "4.17.20", GHSA: "GHSA-aaaa"}
+	minimist := scanner.Vulnerability{Severity: scanner.SeverityHigh, Package: "minimist", Version: "1.2.0", GHSA: "GHSA-bbbb"}
+	axios := scanner.Vulnerability{Severity: scanner.SeverityCritical, Package: "axios", Version: "0.21.0", GHSA: "GHSA-cccc"}
+	debug := scanner.Vulnerability{Severity: scanner.SeverityModerate, Package: "debug", Version: "2.6.8", GHSA: "GHSA-dddd"}
+	success := scanner.ActionResult{Success: true}
+
+	oldReport := &scanner.Report{ScanID: "scan_1", TargetDir: "/old/checkout", Results: []scanner.ScanResult{
+		{ProjectPath: "/old/checkout/web", Status: scanner.StatusSuccess, SecurityScan: success,
+			Vulnerabilities: []scanner.Vulnerability{lodash, minimist, debug}},
+		{ProjectPath: "/old/checkout/api", Status: scanner.StatusSuccess, SecurityScan: success,
+			Vulnerabilities: []scanner.Vulnerability{minimist}},
+		{ProjectPath: "/old/checkout/legacy", Status: scanner.StatusSuccess, SecurityScan: success,
+			Vulnerabilities: []scanner.Vulnerability{lodash}},
+	}}
+
+	raised := lodash
+	raised.Severity = scanner.SeverityHigh
+	fixed := minimist
+	fixed.Fixed = true
+	// 脆弱な範囲内でのバージョンアップ
+	bumped := debug
+	bumped.Version = "2.6.9"
+	newReport := &scanner.Report{ScanID: "scan_2", TargetDir: "/new/checkout", Results: []scanner.ScanResult{
+		{ProjectPath: "/new/checkout/web", Status: scanner.StatusSuccess, SecurityScan: success,
+			Vulnerabilities: []scanner.Vulnerability{raised, fixed, axios, bumped}},
+		{ProjectPath: "/new/checkout/api", Status: scanner.StatusFailed},
+		{ProjectPath: "/new/checkout/docs", Status: scanner.StatusSuccess, SecurityScan: success},
+	}}
+
+	diff := diffReports(oldReport, newReport)
+
+	if len(diff.ProjectsAdded) != 1 || diff.ProjectsAdded[0].Project != "docs" {
+		t.Errorf("Expected docs to be added, got %+v", diff.ProjectsAdded)
+	}
+	if len(diff.ProjectsRemoved) != 1 || diff.ProjectsRemoved[0].Project != "legacy" ||
+		diff.ProjectsRemoved[0].Findings != 1 {
+		t.Errorf("Expected legacy to be removed with 1 finding, got %+v", diff.ProjectsRemoved)
+	}
+	if len(diff.StatusChanges) != 1 || diff.StatusChanges[0].Project != "api" ||
+		diff.StatusChanges[0].New != scanner.StatusFailed {
+		t.Errorf("Expected api to change to failed, got %+v", diff.StatusChanges)
+	}
+	if len(diff.Introduced) != 1 || diff.Introduced[0].Package != "axios" {
+		t.Errorf("Expected axios to be introduced, got %+v", diff.Introduced)
+	}
+	// 失敗したapiのminimistは解消とみなさず、修正済みのwebのminimistのみ解消とする
+	if len(diff.Resolved) != 1 || diff.Resolved[0].Package != "minimist" || diff.Resolved[0].Project != "web" {
+		t.Errorf("Expected only minimist in web to be resolved, got %+v", diff.Resolved)
+	}
+	// バージョンが変わっても同じアドバイザリの影響を受けていれば新規・解消ではなく変更とする
+	if len(diff.Changed) != 1 || diff.Changed[0].Package != "debug" || diff.Changed[0].OldVersion != "2.6.8" ||
+		diff.Changed[0].Version != "2.6.9" || diff.Changed[0].Project != "web" {
+		t.Errorf("Expected debug to change from 2.6.8 to 2.6.9, got %+v", diff.Changed)
+	}
+	if len(diff.SeverityChanges) != 1 || diff.SeverityChanges[0].OldSeverity != scanner.SeverityModerate ||
+		diff.SeverityChanges[0].Severity != scanner.SeverityHigh {
+		t.Errorf("Expected lodash to change from moderate to high, got %+v", diff.SeverityChanges)
+	}
+	if got := diff.countIntroducedAtOrAbove(scanner.SeverityHigh); got != 2 {
+		t.Errorf("Expected 2 new findings at or above high, got %d", got)
+	}
+
+	if same := diffReports(oldReport, oldReport); same.hasChanges() {
+		t.Errorf("Expected no changes when comparing a report with itself, got %+v", same)
 	}
 
-	// node_modulesが存在しない場合のテスト
-	if err := removeNodeModules(tempDir); err != nil {
-		t.Errorf("removeNodeModules should not fail when node_modules doesn't exist: %v", err)
+	content := generateDiffHTML(diff)
+	for _, want := range []string{"bulma.min.css", "Introduced Vulnerabilities (1)", "moderate → high", "legacy",
+		"version 2.6.8 → 2.6.9"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected diff HTML to contain %q", want)
+		}
 	}
 }
 
-// ベンチマークテスト
-func BenchmarkFindNpmProjects(b *testing.B) {
-	// テスト用の一時ディレクトリを作成
-	tempDir := b.TempDir()
+func TestScanHistory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, HistoryFileName)
+	lodash := scanner.Vulnerability{Severity: scanner.SeverityHigh, Package: "lodash", Version: "4.17.20", GHSA: "GHSA-aaaa"}
+	minimist := scanner.Vulnerability{Severity: scanner.SeverityCritical, Package: "minimist", Version: "1.2.0", GHSA: "GHSA-bbbb"}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	run := func(day int, web scanner.ActionResult, webVulns, apiVulns []scanner.Vulnerability) {
+		report := &scanner.Report{
+			ScanID:    fmt.Sprintf("scan_%d", day),
+			EndTime:   start.AddDate(0, 0, day),
+			TargetDir: dir,
+			Results: []scanner.ScanResult{
+				{ProjectPath: filepath.Join(dir, "web"), Status: scanner.StatusSuccess, SecurityScan: web,
+					Vulnerabilities: webVulns},
+				{ProjectPath: filepath.Join(dir, "api"), Status: scanner.StatusSuccess,
+					SecurityScan: scanner.ActionResult{Success: true}, Vulnerabilities: apiVulns},
+			},
+		}
+		if err := appendHistory(path, report); err != nil {
+			t.Fatalf("appendHistory failed: %v", err)
+		}
+	}
 
-	// 複数のプロジェクトを作成
-	for i := 0; i < 10; i++ {
-		projectDir := filepath.Join(tempDir, "project"+string(rune(i+'0')))
-		if err := os.MkdirAll(projectDir, 0755); err != nil {
-			b.Fatalf("Failed to create test directory: %v", err)
+	ok := scanner.ActionResult{Success: true}
+	run(0, ok, []scanner.Vulnerability{lodash, minimist}, []scanner.Vulnerability{lodash})
+	// webのスキャンが失敗した回では解消とみなさない
+	run(2, scanner.ActionResult{Error: "audit failed"}, nil, []scanner.Vulnerability{lodash})
+	// 脆弱な範囲内でのバージョンアップは解消とみなさない
+	bumped := minimist
+	bumped.Version = "1.2.5"
+	run(4, ok, []scanner.Vulnerability{bumped}, nil)
+	run(10, ok, nil, nil)
+
+	entries, err := readHistory(path)
+	if err != nil {
+		t.Fatalf("readHistory failed: %v", err)
+	}
+	if len(entries) != 4 || entries[0].Projects[0].Project != "web" ||
+		entries[0].Projects[0].Counts[scanner.SeverityHigh] != 1 || entries[0].Projects[0].Counts[scanner.SeverityCritical] != 1 {
+		t.Fatalf("Unexpected history entries: %+v", entries)
+	}
+
+	summary := summarizeHistory(entries, 3)
+	if len(summary.Runs) != 3 || summary.Runs[0].ScanID != "scan_2" {
+		t.Errorf("Expected the last 3 runs, got %d starting at %s", len(summary.Runs), summary.Runs[0].ScanID)
+	}
+
+	// lodashはwebで4日・apiで4日、minimistはwebで10日かかった
+	stats := make(map[string]FixStat)
+	for _, stat := range summary.Advisories {
+		stats[stat.Name] = stat
+	}
+	if got := stats["GHSA-aaaa"]; got.Fixed != 2 || got.MeanTimeToFix != 4*24*time.Hour {
+		t.Errorf("Unexpected lodash time to fix: %+v", got)
+	}
+	if got := stats["GHSA-bbbb"]; got.Fixed != 1 || got.MeanTimeToFix != 10*24*time.Hour {
+		t.Errorf("Unexpected minimist time to fix: %+v", got)
+	}
+	if summary.Advisories[0].Name != "GHSA-bbbb" || summary.Projects[0].Name != "web" ||
+		summary.Projects[0].MeanTimeToFix != 7*24*time.Hour {
+		t.Errorf("Expected minimist and web to be the slowest, got %+v and %+v", summary.Advisories, summary.Projects)
+	}
+
+	content := generateHistoryHTML(summary)
+	for _, want := range []string{"bulma.min.css", "<polyline", "Slowest Projects", "10d 0h"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected history HTML to contain %q", want)
 		}
+	}
+}
 
-		packageJson := filepath.Join(projectDir, "package.json")
-		if err := os.WriteFile(packageJson, []byte(`{"name": "test"}`), 0644); err != nil {
-			b.Fatalf("Failed to create package.json: %v", err)
+func TestSBOMFileName(t *testing.T) {
+	dir := t.TempDir()
+	report := &scanner.Report{ScanID: "scan_1", TargetDir: dir}
+	result := &scanner.ScanResult{ProjectPath: filepath.Join(dir, "apps", "web")}
+	if name := sbomFileName(report, result, CycloneDXJSONExtension); name != "scan_1-apps_web.cdx.json" {
+		t.Errorf("Unexpected SBOM file name: %s", name)
+	}
+}
+
+func TestReporters(t *testing.T) {
+	// フラグに束縛されたパッケージ変数をテスト後にデフォルトへ戻す
+	t.Cleanup(func() {
+		newRootCommand()
+		reportStdout = os.Stdout
+	})
+	root := newRootCommand()
+
+	// --outputに書き出せるのは1つの形式だけ
+	reportOutputPath = "-"
+	if err := validateReportFormats([]string{ReportFormatJSON}); err != nil {
+		t.Errorf("Expected a single format to be valid, got %v", err)
+	}
+	if err := validateReportFormats([]string{ReportFormatHTML, ReportFormatJSON}); err == nil {
+		t.Error("Expected --output with two formats to be rejected")
+	}
+	if err := validateReportFormats([]string{"pdf"}); err == nil || !strings.Contains(err.Error(), ReportFormatSARIF) {
+		t.Errorf("Expected an unknown format error listing the reporters, got %v", err)
+	}
+
+	// --output -ではJSONレポートだけが標準出力に書き込まれる
+	dir := t.TempDir()
+	currentReport = &scanner.Report{ScanID: "scan_1", TargetDir: dir}
+	t.Cleanup(func() { currentReport = nil })
+	reportFormats = []string{ReportFormatJSON}
+	reportsDir = filepath.Join(dir, ReportsDirName)
+	recordHistory = false
+	var stdout strings.Builder
+	reportStdout = &stdout
+	showScanResults()
+
+	var report scanner.Report
+	if err := json.Unmarshal([]byte(stdout.String()), &report); err != nil || report.ScanID != "scan_1" {
+		t.Errorf("Expected the JSON report on stdout, got %q (%v)", stdout.String(), err)
+	}
+	if _, err := os.Stat(reportsDir); !os.IsNotExist(err) {
+		t.Errorf("Expected no report files with --output -, got %v", err)
+	}
+
+	// 進捗はos.Stdoutを差し替えずにcolor.Output経由で標準エラー出力へ移す
+	stdoutFile, colorOutput := os.Stdout, color.Output
+	t.Cleanup(func() { color.Output = colorOutput })
+	routeProgressOutput()
+	if os.Stdout != stdoutFile || reportStdout != io.Writer(os.Stdout) || color.Output != color.Error {
+		t.Errorf("Expected only the progress output to move to stderr")
+	}
+	var progress strings.Builder
+	color.Output = &progress
+	showProjects([]scanner.Project{{Dir: "apps/web"}})
+	if !strings.Contains(progress.String(), "1. apps/web") {
+		t.Errorf("Expected the project list on the progress output, got %q", progress.String())
+	}
+
+	// diffやhistoryの--formatはreports.formatsの値を受け取らない
+	history, _, err := root.Find([]string{"history"})
+	if err != nil {
+		t.Fatalf("history command not found: %v", err)
+	}
+	if flag := lookupSettingFlag(history, "format"); flag == nil || flag != root.Flags().Lookup("format") {
+		t.Errorf("Expected reports.formats to bind the root --format flag, got %v", flag)
+	}
+}
+
+func TestProjectSelector(t *testing.T) {
+	projects := []scanner.Project{
+		{Dir: "apps/web", PackageManager: "npm"},
+		{Dir: "apps/api", PackageManager: "pnpm"},
+		{Dir: "libs/ui", PackageManager: "npm"},
+	}
+	selector := newProjectSelector(projects)
+	press := func(data string) (done, start bool) {
+		for _, k := range parseKeys([]byte(data)) {
+			if done, start = selector.handle(k); done {
+				return done, start
+			}
 		}
+		return false, false
 	}
 
-	// ベンチマーク実行
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := findNpmProjects(tempDir)
-		if err != nil {
-			b.Fatalf("findNpmProjects failed: %v", err)
+	// フィルターで絞り込んだプロジェクトだけを一括で選択解除する
+	press("/apps\r")
+	if visible := selector.visible(); len(visible) != 2 {
+		t.Fatalf("Expected 2 projects matching the filter, got %v", visible)
+	}
+	press("a")
+	if chosen := selector.chosen(); len(chosen) != 1 || chosen[0].Dir != "libs/ui" {
+		t.Errorf("Expected only libs/ui to stay selected, got %v", chosen)
+	}
+
+	// フィルターを解除して2番目のプロジェクトを選択し直す
+	press("\x1b\x1b[B ")
+	if done, start := press("\r"); !done || !start {
+		t.Fatalf("Expected enter to start the scan, got done %v start %v", done, start)
+	}
+	if chosen := selector.chosen(); len(chosen) != 2 || chosen[0].Dir != "apps/api" || chosen[1].Dir != "libs/ui" {
+		t.Errorf("Expected apps/api and libs/ui in discovery order, got %v", chosen)
+	}
+
+	// 何も選択されていなければスキャンを開始しない
+	press("aa")
+	if done, _ := press("\r"); done || selector.message == "" {
+		t.Errorf("Expected an empty selection to be refused, got done %v message %q", done, selector.message)
+	}
+	if done, start := press("q"); !done || start {
+		t.Errorf("Expected q to cancel the selection, got done %v start %v", done, start)
+	}
+}
+
+func TestScanProgress(t *testing.T) {
+	projects := []scanner.Project{{Dir: "apps/web"}, {Dir: "apps/api"}}
+	progress := newScanProgress(projects)
+	progress.apply(scanner.ProjectStarted{Project: "apps/web", Index: 1, Total: 2})
+	progress.apply(scanner.StepStarted{Project: "apps/web", Step: scanner.StepInstall})
+	progress.apply(scanner.ProjectFinished{Project: "apps/api", Status: scanner.StatusFailed, Duration: time.Second})
+
+	screen := strings.Join(progress.render(24), "\n")
+	for _, want := range []string{"Scanning 2 project(s)", "install", "failed", "ctrl-c interrupt"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Expected the progress screen to contain %q:\n%s", want, screen)
+		}
+	}
+
+	// 完了後はプロジェクトを選んで記録された出力を表示できる
+	progress.finish(&scanner.Report{Results: []scanner.ScanResult{
+		{ProjectPath: "apps/web", Status: scanner.StatusSuccess,
+			SecurityScan: scanner.ActionResult{Success: true, Output: "found 0 vulnerabilities\x1b[0m\tok"}},
+		{ProjectPath: "apps/api", Status: scanner.StatusFailed,
+			NpmInstall: scanner.ActionResult{Error: "npm ci failed", Output: "ERESOLVE"}},
+	}})
+	for _, k := range parseKeys([]byte("\x1b[B\r")) {
+		progress.handle(k, 24)
+	}
+	screen = strings.Join(progress.render(24), "\n")
+	for _, want := range []string{"apps/api · failed", "error: npm ci failed", "ERESOLVE"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Expected the output of apps/api to contain %q:\n%s", want, screen)
 		}
 	}
+	if quit := progress.handle(key{code: keyEscape}, 24); quit || progress.detail != -1 {
+		t.Errorf("Expected esc to return to the result list")
+	}
+	if quit := progress.handle(key{code: keyRune, r: 'q'}, 24); !quit {
+		t.Errorf("Expected q to leave the TUI")
+	}
 }
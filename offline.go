@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/pality/npm-security-scanner/scanner"
+	"github.com/spf13/cobra"
+)
+
+// advisoryDBPath is the local advisory database used by the offline subcommand (--db)
+var advisoryDBPath string
+
+// newOfflineCommand creates the subcommand that matches lockfiles against a
+// local advisory database without network access or npm
+func newOfflineCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline [target-directory]",
+		Short: "Match lockfiles against a local advisory database without network access",
+		Long: `package-lock.json / npm-shrinkwrap.json（lockfileVersion 1, 2, 3）を直接解析し、
+ローカルの脆弱性データベース（OSV JSONダンプまたはGitHub Advisoryエクスポート）と照合します。
+npmやネットワークを使用せず、プロジェクトも変更しないため、エアギャップ環境で再現性のある結果が得られます。`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runOffline,
+	}
+
+	cmd.Flags().StringVar(&advisoryDBPath, "db", "",
+		"advisory database: OSV/GitHub advisory JSON file, directory of JSON files or OSV zip dump")
+	_ = cmd.MarkFlagRequired("db")
+	addReportFlags(cmd.Flags())
+	return cmd
+}
+
+func runOffline(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	targetDir := "."
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+
+	routeProgressOutput()
+	infoColor.Printf("🔍 NPM Security Scanner v%s (offline)\n", appVersion)
+	infoColor.Printf("Target directory: %s\n\n", targetDir)
+
+	if err := validateOptions(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	detectNonInteractive()
+
+	infoColor.Printf("📚 Loading advisory database %s...\n", advisoryDBPath)
+	db, err := scanner.LoadAdvisoryDatabase(advisoryDBPath)
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	successColor.Printf("✅ Loaded %d advisories\n\n", db.Count)
+
+	if options.IOCs, err = loadIOCs(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if options.Suppressions, err = loadSuppressionFile(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if options.Baseline, err = loadBaselineFile(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	if err := setupEventStream(); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	projects, err := discoverProjects(ctx, targetDir)
+	if err != nil {
+		errorColor.Printf("❌ Failed to find NPM projects: %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	if len(projects) == 0 {
+		warningColor.Println("⚠️  No NPM projects found in the specified directory")
+		return
+	}
+
+	// オフライン照合はプロジェクトを変更しないため確認なしで実行する
+	showProjects(projects)
+
+	options.ReadOnly = true
+	options.AdvisoryDB = db
+	options.TargetDir = targetDir
+	if interrupted := scanWithInterrupt(ctx, projects); interrupted {
+		warningColor.Println("⚠️  Scan interrupted")
+		os.Exit(ExitInterrupted)
+	}
+
+	os.Exit(reportExitCode(currentReport))
+}
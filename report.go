@@ -3,249 +3,234 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"html"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/pality/npm-security-scanner/scanner"
 )
 
-// ScanResult represents the result of scanning a single project
-type ScanResult struct {
-	StartTime       time.Time       `json:"start_time"`
-	EndTime         time.Time       `json:"end_time"`
-	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
-	ProjectPath     string          `json:"project_path"`
-	Status          string          `json:"status"`
-	NodeModules     ActionResult    `json:"node_modules"`
-	NpmInstall      ActionResult    `json:"npm_install"`
-	SecurityScan    ActionResult    `json:"security_scan"`
-	Duration        time.Duration   `json:"duration"`
-}
-
-// ActionResult represents the result of a specific action
-type ActionResult struct {
-	Error   string `json:"error"`
-	Output  string `json:"output"`
-	Success bool   `json:"success"`
-}
-
-// Vulnerability represents a security vulnerability found during scan
-type Vulnerability struct {
-	Severity    string `json:"severity"`
-	Package     string `json:"package"`
-	Description string `json:"description"`
-	Fixed       bool   `json:"fixed"`
-}
-
-// ScanReport represents the complete scan report
-type ScanReport struct {
-	StartTime       time.Time     `json:"start_time"`
-	EndTime         time.Time     `json:"end_time"`
-	TotalDuration   time.Duration `json:"total_duration"`
-	Results         []ScanResult  `json:"results"`
-	ScanID          string        `json:"scan_id"`
-	ProjectsScanned int           `json:"projects_scanned"`
-	SuccessCount    int           `json:"success_count"`
-	ErrorCount      int           `json:"error_count"`
-	SafeChainMode   bool          `json:"safe_chain_mode"`
-}
-
-var currentReport *ScanReport
-
-// initReport initializes a new scan report
-func initReport() {
-	currentReport = &ScanReport{
-		ScanID:        fmt.Sprintf("scan_%d", time.Now().Unix()),
-		StartTime:     time.Now(),
-		Results:       make([]ScanResult, 0),
-		SafeChainMode: false,
-	}
-}
-
-// setSafeChainMode sets whether Safe Chain is being used
-func setSafeChainMode(enabled bool) {
-	if currentReport != nil {
-		currentReport.SafeChainMode = enabled
-	}
-}
-
-// addProjectResult adds a project scan result to the report
-func addProjectResult(result *ScanResult) {
-	if currentReport == nil {
-		return
-	}
-
-	currentReport.Results = append(currentReport.Results, *result)
-	currentReport.ProjectsScanned++
-
-	if result.Status == StatusSuccess {
-		currentReport.SuccessCount++
-	} else {
-		currentReport.ErrorCount++
-	}
-}
-
-// finalizeReport completes the scan report
-func finalizeReport() {
-	if currentReport == nil {
-		return
-	}
-
-	currentReport.EndTime = time.Now()
-	currentReport.TotalDuration = currentReport.EndTime.Sub(currentReport.StartTime)
-}
+// currentReport is the finalized report rendered by the terminal, HTML and JSON reporters
+var currentReport *scanner.Report
 
 // printTerminalReport prints the scan report to terminal
-func printTerminalReport() {
+func printTerminalReport(w io.Writer) {
 	if currentReport == nil {
-		warningColor.Println("⚠️  No scan report available")
+		warningColor.Fprintln(w, "⚠️  No scan report available")
 		return
 	}
 
-	printReportHeader()
-	printReportSummary()
-	printProjectResults()
-	fmt.Println(strings.Repeat("=", ReportSeparator))
+	printReportHeader(w)
+	printReportSummary(w)
+	printProjectResults(w)
+	fmt.Fprintln(w, strings.Repeat("=", ReportSeparator))
 }
 
 // printReportHeader prints the report header
-func printReportHeader() {
-	fmt.Println("\n" + strings.Repeat("=", ReportSeparator))
-	infoColor.Printf("📊 SCAN REPORT - %s\n", currentReport.ScanID)
-	fmt.Println(strings.Repeat("=", ReportSeparator))
+func printReportHeader(w io.Writer) {
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", ReportSeparator))
+	infoColor.Fprintf(w, "📊 SCAN REPORT - %s\n", currentReport.ScanID)
+	fmt.Fprintln(w, strings.Repeat("=", ReportSeparator))
 }
 
 // printReportSummary prints the report summary statistics
-func printReportSummary() {
-	infoColor.Printf("⏱️  Total Duration: %v\n", currentReport.TotalDuration.Round(time.Second))
-	infoColor.Printf("📁 Projects Scanned: %d\n", currentReport.ProjectsScanned)
-	successColor.Printf("✅ Successful: %d\n", currentReport.SuccessCount)
+func printReportSummary(w io.Writer) {
+	infoColor.Fprintf(w, "⏱️  Total Duration: %v\n", currentReport.TotalDuration.Round(time.Second))
+	infoColor.Fprintf(w, "📁 Projects Scanned: %d\n", currentReport.ProjectsScanned)
+	successColor.Fprintf(w, "✅ Successful: %d\n", currentReport.SuccessCount)
 	if currentReport.ErrorCount > 0 {
-		errorColor.Printf("❌ Failed: %d\n", currentReport.ErrorCount)
+		errorColor.Fprintf(w, "❌ Failed: %d\n", currentReport.ErrorCount)
+	}
+	infoColor.Fprintf(w, "🔒 Safe Chain Mode: %v\n", currentReport.SafeChainMode)
+	if currentReport.ReadOnly {
+		infoColor.Fprintln(w, "📖 Read-only Mode: true")
 	}
-	infoColor.Printf("🔒 Safe Chain Mode: %v\n", currentReport.SafeChainMode)
-	fmt.Println()
+	if currentReport.AdvisoryDB != "" {
+		infoColor.Fprintf(w, "📚 Offline Advisory DB: %s\n", currentReport.AdvisoryDB)
+	}
+	if suppressed, expired := currentReport.CountSuppressions(); suppressed > 0 || expired > 0 {
+		infoColor.Fprintf(w, "🔕 Suppressed: %d", suppressed)
+		if expired > 0 {
+			warningColor.Fprintf(w, " (%d finding(s) with expired suppressions)", expired)
+		}
+		fmt.Fprintln(w)
+	}
+	if currentReport.Baseline != "" {
+		infoColor.Fprintf(w, "📌 Baseline: %d known finding(s) not reported, %d resolved (%s)\n",
+			currentReport.CountBaselined(), len(currentReport.ResolvedSinceBaseline), currentReport.Baseline)
+	}
+	if malware := currentReport.CountFindingsAtOrAbove(scanner.SeverityMalware); malware > 0 {
+		malwareColor.Fprintf(w, "🦠 Known-malicious packages: %d", malware)
+		fmt.Fprintln(w)
+	}
+	if currentReport.Interrupted {
+		warningColor.Fprintln(w, "⚠️  Interrupted: partial report")
+	}
+	fmt.Fprintln(w)
 }
 
 // printProjectResults prints detailed results for each project
-func printProjectResults() {
+func printProjectResults(w io.Writer) {
 	for i := range currentReport.Results {
 		result := &currentReport.Results[i]
-		printProjectHeader(i+1, result)
-		printProjectActions(result)
-		printProjectVulnerabilities(result)
-		fmt.Println()
+		printProjectHeader(w, i+1, result)
+		printProjectActions(w, result)
+		printProjectVulnerabilities(w, result)
+		fmt.Fprintln(w)
 	}
+	printResolvedSinceBaseline(w)
+}
+
+// printResolvedSinceBaseline lists baseline findings that no longer occur
+func printResolvedSinceBaseline(w io.Writer) {
+	if len(currentReport.ResolvedSinceBaseline) == 0 {
+		return
+	}
+	successColor.Fprintf(w, "🎉 Resolved since baseline: %d\n", len(currentReport.ResolvedSinceBaseline))
+	for _, finding := range currentReport.ResolvedSinceBaseline {
+		label := finding.Package
+		if finding.Version != "" {
+			label += "@" + finding.Version
+		}
+		fmt.Fprintf(w, "      - %s: %s (%s) in %s\n", finding.Severity, label, finding.Advisory, finding.Project)
+	}
+	fmt.Fprintln(w)
 }
 
 // printProjectHeader prints project basic info
-func printProjectHeader(index int, result *ScanResult) {
-	fmt.Printf("📦 [%d/%d] %s\n", index, len(currentReport.Results), result.ProjectPath)
-	fmt.Printf("    Status: ")
-	if result.Status == StatusSuccess {
-		successColor.Printf("✅ Success")
+func printProjectHeader(w io.Writer, index int, result *scanner.ScanResult) {
+	fmt.Fprintf(w, "📦 [%d/%d] %s\n", index, len(currentReport.Results), result.ProjectPath)
+	fmt.Fprintf(w, "    Status: ")
+	if result.Status == scanner.StatusSuccess {
+		successColor.Fprintf(w, "✅ Success")
 	} else {
-		errorColor.Printf("❌ %s", result.Status)
+		errorColor.Fprintf(w, "❌ %s", result.Status)
 	}
-	fmt.Printf(" (Duration: %v)\n", result.Duration.Round(time.Second))
+	fmt.Fprintf(w, " (Duration: %v)\n", result.Duration.Round(time.Second))
 }
 
 // printProjectActions prints project action results
-func printProjectActions(result *ScanResult) {
-	printActionResult("🗑️  Node Modules", result.NodeModules, "Removed")
-	printActionResult("📦 NPM Install", result.NpmInstall, "Success")
-	printActionResult("🔍 Security Scan", result.SecurityScan, "Completed")
+func printProjectActions(w io.Writer, result *scanner.ScanResult) {
+	printActionResult(w, "🗑️  Node Modules", result.NodeModules, "Removed")
+	printActionResult(w, "📦 NPM Install", result.NpmInstall, "Success")
+	if result.PackageManager != "" {
+		fmt.Fprintf(w, "    🧰 Package Manager: %s\n", result.PackageManager)
+	}
+	if result.InstallStrategy != "" {
+		fmt.Fprintf(w, "    📦 Install Strategy: %s\n",
+			result.InstallCommand())
+	}
+	if len(result.WorkspaceMembers) > 0 {
+		fmt.Fprintf(w, "    🗂️  Workspace: %d member(s) (%s)\n", len(result.WorkspaceMembers),
+			strings.Join(result.WorkspaceMembers, ", "))
+	}
+	printActionResult(w, "🔍 Security Scan", result.SecurityScan, "Completed")
+	printActionResult(w, "🔧 Audit Fix", result.AuditFix, "Applied")
+	if result.RolledBack {
+		warningColor.Fprintln(w, "    ↩️  Fix rolled back")
+	}
+	if len(result.FixPlan) > 0 {
+		fmt.Fprintf(w, "    📋 Fix plan: %d change(s)\n", len(result.FixPlan))
+		for _, change := range result.FixPlan {
+			fmt.Fprintf(w, "      %s\n", change.String())
+		}
+	}
 }
 
 // printActionResult prints a single action result
-func printActionResult(name string, action ActionResult, successMsg string) {
-	if action.Success {
-		fmt.Printf("    %s: ✅ %s\n", name, successMsg)
+func printActionResult(w io.Writer, name string, action scanner.ActionResult, successMsg string) {
+	if action.Skipped {
+		fmt.Fprintf(w, "    %s: ⏭️  Skipped\n", name)
+	} else if action.Success {
+		fmt.Fprintf(w, "    %s: ✅ %s\n", name, successMsg)
 	} else if action.Error != "" {
-		fmt.Printf("    %s: ❌ %s\n", name, action.Error)
+		fmt.Fprintf(w, "    %s: ❌ %s\n", name, action.Error)
 	}
 }
 
 // printProjectVulnerabilities prints vulnerability information
-func printProjectVulnerabilities(result *ScanResult) {
+func printProjectVulnerabilities(w io.Writer, result *scanner.ScanResult) {
 	if len(result.Vulnerabilities) > 0 {
-		fmt.Printf("    🚨 Vulnerabilities: %d found\n", len(result.Vulnerabilities))
+		fmt.Fprintf(w, "    🚨 Vulnerabilities: %d found\n", len(result.Vulnerabilities))
 		for _, vuln := range result.Vulnerabilities {
-			printSingleVulnerability(vuln)
+			printSingleVulnerability(w, vuln)
 		}
 	} else if result.SecurityScan.Success {
-		fmt.Printf("    🛡️  No vulnerabilities detected\n")
+		fmt.Fprintf(w, "    🛡️  No vulnerabilities detected\n")
+	}
+	// ベースライン済みの検出結果は件数のみ表示する
+	if len(result.Baselined) > 0 {
+		fmt.Fprintf(w, "    📌 In baseline: %d\n", len(result.Baselined))
+	}
+
+	// 抑制された脆弱性は別セクションに表示する
+	if len(result.Suppressed) > 0 {
+		fmt.Fprintf(w, "    🔕 Suppressed: %d\n", len(result.Suppressed))
+		for _, vuln := range result.Suppressed {
+			printSingleVulnerability(w, vuln)
+		}
 	}
 }
 
 // printSingleVulnerability prints a single vulnerability
-func printSingleVulnerability(vuln Vulnerability) {
+func printSingleVulnerability(w io.Writer, vuln scanner.Vulnerability) {
 	severityColor := getSeverityColor(vuln.Severity)
-	severityColor.Printf("      - %s: %s (%s)", vuln.Severity, vuln.Package, vuln.Description)
+	severityColor.Fprintf(w, "      - %s: %s (%s)", vuln.Severity, vuln.Label(), vuln.Description)
 	if vuln.Fixed {
-		successColor.Printf(" - FIXED")
+		successColor.Fprintf(w, " - FIXED")
+	}
+	fmt.Fprintln(w)
+	if details := vuln.Details(); details != "" {
+		fmt.Fprintf(w, "        %s\n", details)
+	}
+	if vuln.Script != "" {
+		fmt.Fprintf(w, "        script: %s\n", vuln.Script)
+	}
+	if vuln.Evidence != "" && vuln.Evidence != vuln.Script {
+		fmt.Fprintf(w, "        > %s\n", vuln.Evidence)
 	}
-	fmt.Println()
 }
 
 // getSeverityColor returns appropriate color for vulnerability severity
 func getSeverityColor(severity string) *color.Color {
 	switch severity {
-	case SeverityHigh, SeverityCritical:
+	case scanner.SeverityMalware:
+		return malwareColor
+	case scanner.SeverityHigh, scanner.SeverityCritical:
 		return errorColor
-	case SeverityLow:
+	case scanner.SeverityLow:
 		return infoColor
 	default:
 		return warningColor
 	}
 }
 
-// generateHTMLReport generates an HTML report file
-func generateHTMLReport() error {
-	if currentReport == nil {
-		return fmt.Errorf("no scan report available")
+// sbomFileName returns the report file of a project's BOM, e.g.
+// "scan_1700000000-apps_web.cdx.json"
+func sbomFileName(report *scanner.Report, result *scanner.ScanResult, extension string) string {
+	project := scanner.FindingProject(report.TargetDir, result.ProjectPath)
+	if project == "." {
+		project = "root"
 	}
-
-	htmlContent := generateHTMLContent()
-
-	reportDir := ReportsDirName
-	if err := os.MkdirAll(reportDir, DirPermSecure); err != nil {
-		return fmt.Errorf("failed to create reports directory: %w", err)
-	}
-
-	filename := fmt.Sprintf("%s/%s.html", reportDir, currentReport.ScanID)
-	if err := os.WriteFile(filename, []byte(htmlContent), FilePermSecure); err != nil {
-		return fmt.Errorf("failed to write HTML report: %w", err)
-	}
-
-	successColor.Printf("📄 HTML Report generated: %s\n", filename)
-	return nil
+	slug := strings.NewReplacer("/", "_", "..", "_").Replace(project)
+	return report.ScanID + "-" + slug + extension
 }
 
-// generateJSONReport generates a JSON report file
-func generateJSONReport() error {
-	if currentReport == nil {
-		return fmt.Errorf("no scan report available")
-	}
-
-	jsonData, err := json.MarshalIndent(currentReport, "", "  ")
+// loadReport reads a JSON report written by the json reporter
+func loadReport(path string) (*scanner.Report, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON report: %w", err)
-	}
-
-	reportDir := ReportsDirName
-	if err := os.MkdirAll(reportDir, DirPermSecure); err != nil {
-		return fmt.Errorf("failed to create reports directory: %w", err)
+		return nil, fmt.Errorf("failed to read report: %w", err)
 	}
-
-	filename := fmt.Sprintf("%s/%s.json", reportDir, currentReport.ScanID)
-	if err := os.WriteFile(filename, jsonData, FilePermSecure); err != nil {
-		return fmt.Errorf("failed to write JSON report: %w", err)
+	var report scanner.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %w", path, err)
 	}
-
-	successColor.Printf("📄 JSON Report generated: %s\n", filename)
-	return nil
+	return &report, nil
 }
 
 // generateHTMLContent generates the HTML content for the report using Bulma CSS
@@ -282,7 +267,7 @@ func generateBulmaProjectsHTML() string {
 }
 
 // generateBulmaProjectCard generates a Bulma card for a single project
-func generateBulmaProjectCard(index int, result *ScanResult) string {
+func generateBulmaProjectCard(index int, result *scanner.ScanResult) string {
 	statusClass, statusIcon := getProjectStatusStyle(result)
 	return fmt.Sprintf(`
         <div class="card project-card">
@@ -300,26 +285,31 @@ func generateBulmaProjectCard(index int, result *ScanResult) string {
 		generateProjectCardHeader(index, result, statusClass, statusIcon),
 		generateProjectCardMeta(result),
 		generateBulmaActionsHTML(result),
-		generateBulmaVulnerabilitiesHTML(result.Vulnerabilities, result.SecurityScan.Success))
+		generateBulmaVulnerabilitiesHTML(result.Vulnerabilities, result.SecurityScan.Success)+
+			generateSuppressedHTML(result.Suppressed)+generateBaselinedHTML(result.Baselined))
 }
 
 // generateBulmaActionsHTML generates Bulma tags for each action
-func generateBulmaActionsHTML(result *ScanResult) string {
+func generateBulmaActionsHTML(result *scanner.ScanResult) string {
 	actions := []struct {
 		icon   string
 		name   string
-		action ActionResult
+		action scanner.ActionResult
 	}{
 		{"fas fa-trash", "Node Modules", result.NodeModules},
 		{"fas fa-download", "NPM Install", result.NpmInstall},
 		{"fas fa-search", "Security Scan", result.SecurityScan},
+		{"fas fa-wrench", "Audit Fix", result.AuditFix},
 	}
 
 	html := ""
 	for _, action := range actions {
 		tagClass := BulmaSuccess
 		icon := "fas fa-check"
-		if !action.action.Success {
+		if action.action.Skipped {
+			tagClass = "is-light"
+			icon = "fas fa-forward"
+		} else if !action.action.Success {
 			tagClass = BulmaDanger
 			icon = "fas fa-times"
 		}
@@ -342,7 +332,7 @@ func generateBulmaActionsHTML(result *ScanResult) string {
 }
 
 // generateBulmaVulnerabilitiesHTML generates HTML for vulnerabilities using Bulma
-func generateBulmaVulnerabilitiesHTML(vulnerabilities []Vulnerability, scanSuccess bool) string {
+func generateBulmaVulnerabilitiesHTML(vulnerabilities []scanner.Vulnerability, scanSuccess bool) string {
 	if len(vulnerabilities) == 0 {
 		if scanSuccess {
 			return `
@@ -362,10 +352,53 @@ func generateBulmaVulnerabilitiesHTML(vulnerabilities []Vulnerability, scanSucce
                         </label>`, len(vulnerabilities))
 
 	for _, vuln := range vulnerabilities {
-		// Determine severity styling
-		severityClass, severityIcon := getVulnerabilitySeverityStyle(vuln)
+		html += generateVulnerabilityItemHTML(vuln)
+	}
 
-		html += fmt.Sprintf(`
+	html += `
+                    </div>`
+
+	return html
+}
+
+// generateSuppressedHTML generates the separate section of suppressed findings
+func generateSuppressedHTML(suppressed []scanner.Vulnerability) string {
+	if len(suppressed) == 0 {
+		return ""
+	}
+
+	html := fmt.Sprintf(`
+                    <div class="field">
+                        <label class="label has-text-grey">
+                            <i class="fas fa-bell-slash"></i>&nbsp;
+                            Suppressed Findings (%d)
+                        </label>`, len(suppressed))
+	for _, vuln := range suppressed {
+		html += generateVulnerabilityItemHTML(vuln)
+	}
+	html += `
+                    </div>`
+
+	return html
+}
+
+// generateBaselinedHTML notes how many of the project's findings are hidden by the baseline
+func generateBaselinedHTML(baselined []scanner.Vulnerability) string {
+	if len(baselined) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+                    <p class="has-text-grey">
+                        <i class="fas fa-thumbtack"></i>&nbsp; %d known finding(s) in baseline not shown
+                    </p>`, len(baselined))
+}
+
+// generateVulnerabilityItemHTML generates the Bulma item of a single finding
+func generateVulnerabilityItemHTML(vuln scanner.Vulnerability) string {
+	// Determine severity styling
+	severityClass, severityIcon := getVulnerabilitySeverityStyle(vuln)
+
+	return fmt.Sprintf(`
                         <div class="vulnerability-item %s">
                             <div class="level">
                                 <div class="level-left">
@@ -379,6 +412,7 @@ func generateBulmaVulnerabilitiesHTML(vulnerabilities []Vulnerability, scanSucce
                                         <div>
                                             <p class="has-text-weight-bold">%s</p>
                                             <p class="is-size-7 has-text-grey">%s</p>
+                                            <p class="is-size-7 has-text-grey-light">%s</p>%s
                                         </div>
                                     </div>
                                 </div>
@@ -387,35 +421,52 @@ func generateBulmaVulnerabilitiesHTML(vulnerabilities []Vulnerability, scanSucce
                                 </div>
                             </div>
                         </div>`,
-			getVulnBgClass(vuln),
-			severityClass,
-			severityIcon,
-			strings.ToUpper(vuln.Severity),
-			vuln.Package,
-			vuln.Description,
-			getFixedBadgeHTML(vuln.Fixed))
+		getVulnBgClass(vuln),
+		severityClass,
+		severityIcon,
+		strings.ToUpper(vuln.Severity),
+		escapeHTML(vuln.Label()),
+		escapeHTML(vuln.Description),
+		escapeHTML(vuln.Details()),
+		generateScriptEvidenceHTML(vuln),
+		getFixedBadgeHTML(vuln.Fixed))
+}
+
+// generateScriptEvidenceHTML shows the lifecycle script and offending line of a script finding
+func generateScriptEvidenceHTML(vuln scanner.Vulnerability) string {
+	html := ""
+	if vuln.Script != "" {
+		html += fmt.Sprintf(`
+                                            <p class="is-size-7"><code>%s</code></p>`, escapeHTML(vuln.Script))
+	}
+	if vuln.Evidence != "" && vuln.Evidence != vuln.Script {
+		html += fmt.Sprintf(`
+                                            <p class="is-size-7"><code>%s</code></p>`, escapeHTML(vuln.Evidence))
 	}
-
-	html += `
-                    </div>`
-
 	return html
 }
 
+// escapeHTML escapes text taken from npm output before embedding it in the HTML report
+func escapeHTML(text string) string {
+	return html.EscapeString(text)
+}
+
 // getVulnBgClass returns the background class for vulnerability severity
-func getVulnBgClass(vuln Vulnerability) string {
+func getVulnBgClass(vuln scanner.Vulnerability) string {
 	if vuln.Fixed {
 		return "vuln-fixed"
 	}
 
 	switch vuln.Severity {
-	case SeverityCritical:
+	case scanner.SeverityMalware:
+		return "vuln-malware"
+	case scanner.SeverityCritical:
 		return "vuln-critical"
-	case SeverityHigh:
+	case scanner.SeverityHigh:
 		return "vuln-high"
-	case SeverityModerate:
+	case scanner.SeverityModerate:
 		return "vuln-moderate"
-	case SeverityLow:
+	case scanner.SeverityLow:
 		return "vuln-low"
 	default:
 		return "vuln-moderate"
@@ -423,19 +474,21 @@ func getVulnBgClass(vuln Vulnerability) string {
 }
 
 // getVulnerabilitySeverityStyle returns the appropriate Bulma classes for vulnerability severity
-func getVulnerabilitySeverityStyle(vuln Vulnerability) (string, string) {
+func getVulnerabilitySeverityStyle(vuln scanner.Vulnerability) (string, string) {
 	if vuln.Fixed {
 		return BulmaSuccess, "fas fa-check-circle"
 	}
 
 	switch vuln.Severity {
-	case SeverityCritical:
+	case scanner.SeverityMalware:
+		return "is-black", "fas fa-biohazard"
+	case scanner.SeverityCritical:
 		return BulmaDanger, "fas fa-skull-crossbones"
-	case SeverityHigh:
+	case scanner.SeverityHigh:
 		return BulmaDanger, "fas fa-exclamation-circle"
-	case SeverityModerate:
+	case scanner.SeverityModerate:
 		return BulmaWarning, "fas fa-exclamation-triangle"
-	case SeverityLow:
+	case scanner.SeverityLow:
 		return BulmaInfo, "fas fa-info-circle"
 	default:
 		return BulmaWarning, "fas fa-exclamation-triangle"
@@ -463,7 +516,7 @@ func generateBulmaHTMLReport() string {
 %s
 <section class="section">
     <div class="container">
-        %s
+        %s%s
     </div>
 </section>
 %s
@@ -472,10 +525,66 @@ func generateBulmaHTMLReport() string {
 		generateBulmaHTMLHead(),
 		generateBulmaHeroSection(),
 		generateBulmaStatsSection(),
+		generateInterruptedNoticeHTML()+generateMalwareNoticeHTML()+generateBaselineNoticeHTML(),
 		generateBulmaProjectsHTML(),
 		generateBulmaFooter())
 }
 
+// generateInterruptedNoticeHTML warns that the report only covers part of the scan
+func generateInterruptedNoticeHTML() string {
+	if !currentReport.Interrupted {
+		return ""
+	}
+	return fmt.Sprintf(`<div class="notification %s is-light">
+            <i class="fas fa-hand-paper"></i>&nbsp;
+            <strong>Scan interrupted</strong> - this is a partial report
+        </div>`, BulmaWarning)
+}
+
+// generateMalwareNoticeHTML highlights known-malicious packages above all other findings
+func generateMalwareNoticeHTML() string {
+	count := currentReport.CountFindingsAtOrAbove(scanner.SeverityMalware)
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`<div class="notification %s">
+            <i class="fas fa-biohazard"></i>&nbsp;
+            <strong>%d known-malicious package(s) detected</strong> - treat affected machines as compromised
+            and rotate credentials
+        </div>`, BulmaDanger, count)
+}
+
+// generateBaselineNoticeHTML summarizes the baseline comparison and lists resolved findings
+func generateBaselineNoticeHTML() string {
+	if currentReport.Baseline == "" {
+		return ""
+	}
+
+	resolved := ""
+	for _, finding := range currentReport.ResolvedSinceBaseline {
+		label := finding.Package
+		if finding.Version != "" {
+			label += "@" + finding.Version
+		}
+		resolved += fmt.Sprintf(`
+                <li><span class="tag is-light">%s</span> <strong>%s</strong> (%s) in %s</li>`,
+			html.EscapeString(finding.Severity), html.EscapeString(label),
+			html.EscapeString(finding.Advisory), html.EscapeString(finding.Project))
+	}
+	if resolved != "" {
+		resolved = `
+            <ul>` + resolved + `
+            </ul>`
+	}
+
+	return fmt.Sprintf(`<div class="notification is-info is-light">
+            <i class="fas fa-thumbtack"></i>&nbsp;
+            <strong>scanner.Baseline %s</strong> - only new findings are shown: %d known finding(s) hidden,
+            %d resolved since the baseline%s
+        </div>`, html.EscapeString(currentReport.Baseline), currentReport.CountBaselined(),
+		len(currentReport.ResolvedSinceBaseline), resolved)
+}
+
 // generateBulmaHTMLHead generates HTML head section
 func generateBulmaHTMLHead() string {
 	return fmt.Sprintf(`<html lang="en">
@@ -496,6 +605,7 @@ func generateBulmaCSS() string {
         .vulnerability-item { border-left: 4px solid; border-radius: 6px; padding: 1rem; margin-bottom: 0.75rem; 
                               transition: all 0.2s ease; }
         .vulnerability-item:hover { transform: translateY(-2px); box-shadow: 0 4px 12px rgba(0,0,0,0.15); }
+        .vuln-malware { border-left-color: #0a0a0a; background: linear-gradient(to right, #ffd6de, #feecf0); }
         .vuln-critical { border-left-color: #ff3860; background: linear-gradient(to right, #feecf0, #fef7f7); }
         .vuln-high { border-left-color: #ff6348; background: linear-gradient(to right, #fef0ef, #fef7f7); }
         .vuln-moderate { border-left-color: #ffdd57; background: linear-gradient(to right, #fffbeb, #fffef7); }
@@ -636,15 +746,15 @@ func generateBulmaFooter() string {
 }
 
 // getProjectStatusStyle returns status class and icon for project
-func getProjectStatusStyle(result *ScanResult) (string, string) {
-	if result.Status == StatusSuccess {
+func getProjectStatusStyle(result *scanner.ScanResult) (string, string) {
+	if result.Status == scanner.StatusSuccess {
 		return BulmaSuccess, "fas fa-check-circle"
 	}
 	return BulmaDanger, "fas fa-times-circle"
 }
 
 // generateProjectCardHeader generates card header for project
-func generateProjectCardHeader(index int, result *ScanResult, statusClass, statusIcon string) string {
+func generateProjectCardHeader(index int, result *scanner.ScanResult, statusClass, statusIcon string) string {
 	return fmt.Sprintf(`<header class="card-header">
                 <p class="card-header-title is-size-4">
                     <i class="fas fa-folder-open"></i>&nbsp;
@@ -660,7 +770,7 @@ func generateProjectCardHeader(index int, result *ScanResult, statusClass, statu
 }
 
 // generateProjectCardMeta generates project metadata section
-func generateProjectCardMeta(result *ScanResult) string {
+func generateProjectCardMeta(result *scanner.ScanResult) string {
 	return fmt.Sprintf(`<div class="level">
                         <div class="level-left">
                             <div class="level-item">
@@ -674,7 +784,82 @@ func generateProjectCardMeta(result *ScanResult) string {
                                     <p class="heading">Started</p>
                                     <p class="title is-6">%s</p>
                                 </div>
-                            </div>
+                            </div>%s
                         </div>
-                    </div>`, result.Duration.Round(time.Second), result.StartTime.Format("15:04:05"))
+                    </div>`, result.Duration.Round(time.Second), result.StartTime.Format("15:04:05"),
+		generateInstallMetaHTML(result))
+}
+
+// generateInstallMetaHTML generates the metadata items for the package manager and install command used
+func generateInstallMetaHTML(result *scanner.ScanResult) string {
+	html := ""
+	if result.PackageManager != "" {
+		html += fmt.Sprintf(`
+                            <div class="level-item">
+                                <div>
+                                    <p class="heading">Package Manager</p>
+                                    <p class="title is-6">%s</p>
+                                </div>
+                            </div>`, escapeHTML(result.PackageManager))
+	}
+	if result.InstallStrategy != "" {
+		html += fmt.Sprintf(`
+                            <div class="level-item">
+                                <div>
+                                    <p class="heading">Install</p>
+                                    <p class="title is-6"><code>%s</code></p>
+                                </div>
+                            </div>`,
+			escapeHTML(result.InstallCommand()))
+	}
+	if len(result.WorkspaceMembers) > 0 {
+		html += fmt.Sprintf(`
+                            <div class="level-item">
+                                <div>
+                                    <p class="heading">Workspace Members</p>
+                                    <p class="title is-6" title="%s">%d</p>
+                                </div>
+                            </div>`,
+			escapeHTML(strings.Join(result.WorkspaceMembers, ", ")), len(result.WorkspaceMembers))
+	}
+	return html
+}
+
+// showReportLinks displays clickable links to the generated reports
+func showReportLinks(htmlReportPath, jsonReportPath string) {
+	fmt.Fprintln(color.Output)
+	infoColor.Println("📄 Generated Reports:")
+
+	if htmlReportPath != "" {
+		// Get absolute path for better user experience
+		absPath, err := filepath.Abs(htmlReportPath)
+		if err != nil {
+			absPath = htmlReportPath
+		}
+
+		// Show clickable file:// URL for HTML report
+		fileURL := fmt.Sprintf("file://%s", absPath)
+		infoColor.Printf("🌐 HTML Report: ")
+		successColor.Printf("%s\n", fileURL)
+
+		// Show local file path
+		infoColor.Printf("📁 Local Path: ")
+		successColor.Printf("%s\n", absPath)
+	}
+
+	// Show JSON report path
+	if jsonReportPath != "" {
+		jsonPath, err := filepath.Abs(jsonReportPath)
+		if err != nil {
+			jsonPath = jsonReportPath
+		}
+		infoColor.Printf("📋 JSON Report: ")
+		successColor.Printf("%s\n", jsonPath)
+	}
+
+	fmt.Fprintln(color.Output)
+	infoColor.Println("💡 Tips:")
+	infoColor.Println("  - Click the file:// URL above to open in browser")
+	infoColor.Println("  - Copy the local path to open manually")
+	infoColor.Println("  - Use JSON report for automation/scripting")
 }
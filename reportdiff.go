@@ -0,0 +1,646 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pality/npm-security-scanner/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// diffFormat is the output format of the diff command (--format)
+	diffFormat string
+	// diffOutput is the file the diff is written to instead of stdout (--output)
+	diffOutput string
+)
+
+// ReportDiff is the comparison of two saved JSON reports
+type ReportDiff struct {
+	Old             DiffSource       `json:"old"`
+	New             DiffSource       `json:"new"`
+	ProjectsAdded   []ProjectChange  `json:"projects_added"`
+	ProjectsRemoved []ProjectChange  `json:"projects_removed"`
+	StatusChanges   []StatusChange   `json:"status_changes"`
+	Introduced      []DiffFinding    `json:"introduced"`
+	Resolved        []DiffFinding    `json:"resolved"`
+	Changed         []VersionChange  `json:"changed"`
+	SeverityChanges []SeverityChange `json:"severity_changes"`
+}
+
+// DiffSource identifies one of the compared reports
+type DiffSource struct {
+	EndTime time.Time `json:"end_time"`
+	Path    string    `json:"path"`
+	ScanID  string    `json:"scan_id"`
+}
+
+// ProjectChange is a project that only appears in one of the reports
+type ProjectChange struct {
+	Project  string `json:"project"`
+	Status   string `json:"status"`
+	Findings int    `json:"findings"`
+}
+
+// StatusChange is a project whose scan status differs between the reports
+type StatusChange struct {
+	Project string `json:"project"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// DiffFinding is a finding that only appears in one of the reports
+type DiffFinding struct {
+	Project string `json:"project"`
+	scanner.Vulnerability
+}
+
+// SeverityChange is a finding whose severity differs between the reports
+type SeverityChange struct {
+	DiffFinding
+	OldSeverity string `json:"old_severity"`
+}
+
+// VersionChange is a finding whose installed version changed between the
+// reports while the package is still affected by the same advisory
+type VersionChange struct {
+	DiffFinding
+	OldVersion  string `json:"old_version"`
+	OldSeverity string `json:"old_severity"`
+}
+
+// hasChanges reports whether the reports differ at all
+func (d *ReportDiff) hasChanges() bool {
+	return len(d.ProjectsAdded)+len(d.ProjectsRemoved)+len(d.StatusChanges)+
+		len(d.Introduced)+len(d.Resolved)+len(d.Changed)+len(d.SeverityChanges) > 0
+}
+
+// openFindings returns the findings still present in a project, keyed by fingerprint.
+// Baselined and suppressed findings are included because they were not resolved.
+func openFindings(project string, result *scanner.ScanResult) map[string]scanner.Vulnerability {
+	findings := make(map[string]scanner.Vulnerability)
+	for _, list := range [][]scanner.Vulnerability{result.Vulnerabilities, result.Baselined, result.Suppressed} {
+		for _, vuln := range list {
+			if vuln.Fixed {
+				continue
+			}
+			// 古いレポートにはフィンガープリントがないため常に計算し直す
+			vuln.Fingerprint = scanner.FindingFingerprint(project, vuln)
+			findings[vuln.Fingerprint] = vuln
+		}
+	}
+	return findings
+}
+
+// findingLifetimeKey identifies a finding of a project by its package and
+// advisory, independent of the installed version
+func findingLifetimeKey(pkg, advisory string) string {
+	return pkg + "\x00" + advisory
+}
+
+// findingPair is a finding of the old report matched to one of the new report
+type findingPair struct {
+	before, after scanner.Vulnerability
+}
+
+// matchFindings pairs the findings of a project in both reports, first by
+// fingerprint and then the remaining ones by package and advisory, so that a
+// version bump within the vulnerable range is a change rather than a resolved
+// and an introduced finding
+func matchFindings(previous, current map[string]scanner.Vulnerability) (pairs []findingPair,
+	introduced, resolved []scanner.Vulnerability) {
+	candidates := make(map[string][]scanner.Vulnerability)
+	for _, fingerprint := range sortedFingerprints(previous) {
+		if _, same := current[fingerprint]; same {
+			continue
+		}
+		vuln := previous[fingerprint]
+		key := findingLifetimeKey(vuln.Package, scanner.FindingAdvisory(vuln))
+		candidates[key] = append(candidates[key], vuln)
+	}
+
+	for _, fingerprint := range sortedFingerprints(current) {
+		vuln := current[fingerprint]
+		if before, same := previous[fingerprint]; same {
+			pairs = append(pairs, findingPair{before: before, after: vuln})
+			continue
+		}
+		key := findingLifetimeKey(vuln.Package, scanner.FindingAdvisory(vuln))
+		if remaining := candidates[key]; len(remaining) > 0 {
+			pairs = append(pairs, findingPair{before: remaining[0], after: vuln})
+			candidates[key] = remaining[1:]
+			continue
+		}
+		introduced = append(introduced, vuln)
+	}
+
+	for _, remaining := range candidates {
+		resolved = append(resolved, remaining...)
+	}
+	return pairs, introduced, resolved
+}
+
+// sortedFingerprints returns the keys of the findings in a stable order
+func sortedFingerprints(findings map[string]scanner.Vulnerability) []string {
+	fingerprints := make([]string, 0, len(findings))
+	for fingerprint := range findings {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+	return fingerprints
+}
+
+// indexResults maps the report's results by project path relative to its target
+func indexResults(report *scanner.Report) (map[string]*scanner.ScanResult, []string) {
+	results := make(map[string]*scanner.ScanResult, len(report.Results))
+	var projects []string
+	for i := range report.Results {
+		project := scanner.FindingProject(report.TargetDir, report.Results[i].ProjectPath)
+		if _, ok := results[project]; !ok {
+			projects = append(projects, project)
+		}
+		results[project] = &report.Results[i]
+	}
+	sort.Strings(projects)
+	return results, projects
+}
+
+// diffReports compares two reports. Projects are matched by their path relative
+// to the scanned directory and findings by package and advisory; a finding whose
+// installed version changed is reported as changed.
+func diffReports(oldReport, newReport *scanner.Report) *ReportDiff {
+	diff := &ReportDiff{
+		Old:             DiffSource{ScanID: oldReport.ScanID, EndTime: oldReport.EndTime},
+		New:             DiffSource{ScanID: newReport.ScanID, EndTime: newReport.EndTime},
+		ProjectsAdded:   []ProjectChange{},
+		ProjectsRemoved: []ProjectChange{},
+		StatusChanges:   []StatusChange{},
+		Introduced:      []DiffFinding{},
+		Resolved:        []DiffFinding{},
+		Changed:         []VersionChange{},
+		SeverityChanges: []SeverityChange{},
+	}
+
+	oldResults, oldProjects := indexResults(oldReport)
+	newResults, newProjects := indexResults(newReport)
+
+	for _, project := range oldProjects {
+		if result, ok := newResults[project]; !ok {
+			old := oldResults[project]
+			diff.ProjectsRemoved = append(diff.ProjectsRemoved, ProjectChange{
+				Project: project, Status: old.Status, Findings: len(openFindings(project, old)),
+			})
+		} else if old := oldResults[project]; old.Status != result.Status {
+			diff.StatusChanges = append(diff.StatusChanges, StatusChange{
+				Project: project, Old: old.Status, New: result.Status,
+			})
+		}
+	}
+
+	for _, project := range newProjects {
+		result := newResults[project]
+		current := openFindings(project, result)
+
+		old, ok := oldResults[project]
+		if !ok {
+			diff.ProjectsAdded = append(diff.ProjectsAdded, ProjectChange{
+				Project: project, Status: result.Status, Findings: len(current),
+			})
+		}
+
+		var previous map[string]scanner.Vulnerability
+		if ok {
+			previous = openFindings(project, old)
+		}
+		pairs, introduced, resolved := matchFindings(previous, current)
+		for _, vuln := range introduced {
+			diff.Introduced = append(diff.Introduced, DiffFinding{Project: project, Vulnerability: vuln})
+		}
+		for _, pair := range pairs {
+			finding := DiffFinding{Project: project, Vulnerability: pair.after}
+			switch {
+			case pair.before.Version != pair.after.Version:
+				diff.Changed = append(diff.Changed, VersionChange{
+					DiffFinding: finding, OldVersion: pair.before.Version, OldSeverity: pair.before.Severity,
+				})
+			case pair.before.Severity != pair.after.Severity:
+				diff.SeverityChanges = append(diff.SeverityChanges, SeverityChange{
+					DiffFinding: finding, OldSeverity: pair.before.Severity,
+				})
+			}
+		}
+
+		// スキャンに失敗したプロジェクトでは検出結果が消えても解消とはみなさない
+		if !result.SecurityScan.Success {
+			continue
+		}
+		for _, vuln := range resolved {
+			diff.Resolved = append(diff.Resolved, DiffFinding{Project: project, Vulnerability: vuln})
+		}
+	}
+
+	sortDiffFindings(diff.Introduced)
+	sortDiffFindings(diff.Resolved)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diffFindingLess(diff.Changed[i].DiffFinding, diff.Changed[j].DiffFinding)
+	})
+	sort.Slice(diff.SeverityChanges, func(i, j int) bool {
+		return diffFindingLess(diff.SeverityChanges[i].DiffFinding, diff.SeverityChanges[j].DiffFinding)
+	})
+	return diff
+}
+
+// sortDiffFindings orders findings by severity (highest first), project and package
+func sortDiffFindings(findings []DiffFinding) {
+	sort.Slice(findings, func(i, j int) bool {
+		return diffFindingLess(findings[i], findings[j])
+	})
+}
+
+func diffFindingLess(a, b DiffFinding) bool {
+	if ra, rb := scanner.SeverityRank(a.Severity), scanner.SeverityRank(b.Severity); ra != rb {
+		return ra > rb
+	}
+	if a.Project != b.Project {
+		return a.Project < b.Project
+	}
+	if a.Package != b.Package {
+		return a.Package < b.Package
+	}
+	return a.Fingerprint < b.Fingerprint
+}
+
+// countIntroducedAtOrAbove counts introduced findings and findings whose
+// severity rose to at least the given severity
+func (d *ReportDiff) countIntroducedAtOrAbove(severity string) int {
+	threshold := scanner.SeverityRank(severity)
+	raised := func(oldSeverity, newSeverity string) bool {
+		return scanner.SeverityRank(newSeverity) >= threshold && scanner.SeverityRank(oldSeverity) < threshold
+	}
+	count := 0
+	for _, finding := range d.Introduced {
+		if scanner.SeverityRank(finding.Severity) >= threshold {
+			count++
+		}
+	}
+	for _, change := range d.Changed {
+		if raised(change.OldSeverity, change.Severity) {
+			count++
+		}
+	}
+	for _, change := range d.SeverityChanges {
+		if raised(change.OldSeverity, change.Severity) {
+			count++
+		}
+	}
+	return count
+}
+
+// newDiffCommand creates the subcommand that compares two saved JSON reports
+func newDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <old.json> <new.json>",
+		Short: "Compare two saved JSON reports",
+		Long: `保存済みの2つのJSONレポートを比較し、追加・削除されたプロジェクト、新規・解消された脆弱性、
+重大度の変化、スキャン状態の変化を表示します。--fail-onを指定すると、新規の検出結果で終了コード1になります。`,
+		Args: cobra.ExactArgs(2),
+		Run:  runDiff,
+	}
+
+	cmd.Flags().StringVar(&diffFormat, "format", ReportFormatTerminal,
+		"output format (terminal|json|html)")
+	cmd.Flags().StringVarP(&diffOutput, "output", "o", "",
+		"file to write the diff to (default: stdout)")
+	cmd.Flags().SetAnnotation("format", configIgnoreAnnotation, []string{"true"})
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	if err := validateOutputFormat(diffFormat); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	failOn = strings.ToLower(failOn)
+	if failOn != "none" && scanner.SeverityRank(failOn) == 0 {
+		errorColor.Printf("❌ invalid --fail-on severity %q (expected low, moderate, high, critical or none)\n", failOn)
+		os.Exit(ExitMisconfigured)
+	}
+
+	oldReport, err := loadReport(args[0])
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+	newReport, err := loadReport(args[1])
+	if err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	diff := diffReports(oldReport, newReport)
+	diff.Old.Path, diff.New.Path = args[0], args[1]
+
+	if err := writeDiff(diff); err != nil {
+		errorColor.Printf("❌ %v\n", err)
+		os.Exit(ExitMisconfigured)
+	}
+
+	if failOn != "none" {
+		if count := diff.countIntroducedAtOrAbove(failOn); count > 0 {
+			if diffFormat == ReportFormatTerminal {
+				errorColor.Printf("🚨 %d new finding(s) at or above %s severity\n", count, failOn)
+			}
+			os.Exit(ExitFindings)
+		}
+	}
+}
+
+// validateOutputFormat rejects unknown output formats of the diff and history commands
+func validateOutputFormat(format string) error {
+	switch format {
+	case ReportFormatTerminal, ReportFormatJSON, ReportFormatHTML:
+		return nil
+	}
+	return fmt.Errorf("invalid format %q (expected %s, %s or %s)",
+		format, ReportFormatTerminal, ReportFormatJSON, ReportFormatHTML)
+}
+
+// createOutput opens the --output file, or returns stdout when path is empty
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, scanner.FilePermReadable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output: %w", err)
+	}
+	return file, nil
+}
+
+// nopWriteCloser keeps stdout open when the output is closed
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeDiff renders the diff in the selected format to --output or stdout
+func writeDiff(diff *ReportDiff) error {
+	out, err := createOutput(diffOutput)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch diffFormat {
+	case ReportFormatJSON:
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff: %w", err)
+		}
+		if _, err := fmt.Fprintf(out, "%s\n", data); err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+	case ReportFormatHTML:
+		if _, err := io.WriteString(out, generateDiffHTML(diff)); err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+	default:
+		printDiff(out, diff)
+	}
+
+	if diffOutput != "" {
+		successColor.Printf("📄 Diff written to %s\n", diffOutput)
+	}
+	return nil
+}
+
+// printDiff prints the diff for the terminal
+func printDiff(out io.Writer, diff *ReportDiff) {
+	fmt.Fprintln(out, strings.Repeat("=", ReportSeparator))
+	infoColor.Fprintf(out, "🔀 REPORT DIFF - %s → %s\n", diff.Old.ScanID, diff.New.ScanID)
+	fmt.Fprintln(out, strings.Repeat("=", ReportSeparator))
+
+	if !diff.hasChanges() {
+		successColor.Fprintln(out, "✅ No differences")
+		return
+	}
+
+	if len(diff.ProjectsAdded) > 0 {
+		infoColor.Fprintf(out, "➕ Projects added: %d\n", len(diff.ProjectsAdded))
+		for _, p := range diff.ProjectsAdded {
+			fmt.Fprintf(out, "      + %s (%s, %d finding(s))\n", p.Project, p.Status, p.Findings)
+		}
+	}
+	if len(diff.ProjectsRemoved) > 0 {
+		infoColor.Fprintf(out, "➖ Projects removed: %d\n", len(diff.ProjectsRemoved))
+		for _, p := range diff.ProjectsRemoved {
+			fmt.Fprintf(out, "      - %s (%s, %d finding(s))\n", p.Project, p.Status, p.Findings)
+		}
+	}
+	if len(diff.StatusChanges) > 0 {
+		warningColor.Fprintf(out, "🔄 Status changes: %d\n", len(diff.StatusChanges))
+		for _, c := range diff.StatusChanges {
+			fmt.Fprintf(out, "      %s: %s → %s\n", c.Project, c.Old, c.New)
+		}
+	}
+	if len(diff.Introduced) > 0 {
+		errorColor.Fprintf(out, "🚨 Introduced: %d\n", len(diff.Introduced))
+		for _, f := range diff.Introduced {
+			printDiffFinding(out, "+", f, f.Severity)
+		}
+	}
+	if len(diff.Resolved) > 0 {
+		successColor.Fprintf(out, "🎉 Resolved: %d\n", len(diff.Resolved))
+		for _, f := range diff.Resolved {
+			printDiffFinding(out, "-", f, f.Severity)
+		}
+	}
+	if len(diff.Changed) > 0 {
+		warningColor.Fprintf(out, "🔁 Changed: %d\n", len(diff.Changed))
+		for _, c := range diff.Changed {
+			printDiffFinding(out, "~", c.DiffFinding, severityTransition(c.OldSeverity, c.Severity))
+			fmt.Fprintf(out, "        version %s → %s, still affected\n", c.OldVersion, c.Version)
+		}
+	}
+	if len(diff.SeverityChanges) > 0 {
+		warningColor.Fprintf(out, "📈 Severity changes: %d\n", len(diff.SeverityChanges))
+		for _, c := range diff.SeverityChanges {
+			printDiffFinding(out, "~", c.DiffFinding, severityTransition(c.OldSeverity, c.Severity))
+		}
+	}
+}
+
+// severityTransition formats a severity that may have changed between the reports
+func severityTransition(oldSeverity, newSeverity string) string {
+	if oldSeverity == newSeverity {
+		return newSeverity
+	}
+	return oldSeverity + " → " + newSeverity
+}
+
+// printDiffFinding prints a single finding of the diff
+func printDiffFinding(out io.Writer, marker string, finding DiffFinding, severity string) {
+	getSeverityColor(finding.Severity).Fprintf(out, "      %s %s: %s (%s)", marker, severity,
+		finding.Vulnerability.Label(), finding.Description)
+	fmt.Fprintf(out, " in %s\n", finding.Project)
+	if details := finding.Vulnerability.Details(); details != "" {
+		fmt.Fprintf(out, "        %s\n", details)
+	}
+}
+
+// generateDiffHTML renders the diff with the Bulma styling of the scan report
+func generateDiffHTML(diff *ReportDiff) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>NPM Security Scanner Diff - %s → %s</title>
+    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/bulma@0.9.4/css/bulma.min.css">
+    <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/font-awesome/6.4.0/css/all.min.css">
+    <style>%s</style>
+</head>
+<body>
+<section class="hero is-medium hero-gradient">
+    <div class="hero-body">
+        <div class="container">
+            <h1 class="title is-1 has-text-white">
+                <i class="fas fa-code-compare"></i> Report Diff
+            </h1>
+            <h2 class="subtitle is-4 has-text-white-ter">%s (%s) → %s (%s)</h2>
+        </div>
+    </div>
+</section>
+<section class="section">
+    <div class="container">
+        <div class="columns">%s</div>
+        %s
+    </div>
+</section>
+</body>
+</html>`,
+		html.EscapeString(diff.Old.ScanID), html.EscapeString(diff.New.ScanID),
+		generateBulmaCSS(),
+		html.EscapeString(diff.Old.ScanID), diff.Old.EndTime.Format("2006-01-02 15:04:05"),
+		html.EscapeString(diff.New.ScanID), diff.New.EndTime.Format("2006-01-02 15:04:05"),
+		generateDiffStatsHTML(diff),
+		generateDiffSectionsHTML(diff))
+}
+
+// generateDiffStatsHTML generates the summary cards of the diff
+func generateDiffStatsHTML(diff *ReportDiff) string {
+	cards := []struct {
+		label, class string
+		count        int
+	}{
+		{"Introduced", "has-text-danger", len(diff.Introduced)},
+		{"Resolved", "has-text-success", len(diff.Resolved)},
+		{"Changed", "has-text-warning-dark", len(diff.Changed)},
+		{"Severity Changes", "has-text-warning-dark", len(diff.SeverityChanges)},
+		{"Projects Added / Removed", "has-text-info", len(diff.ProjectsAdded) + len(diff.ProjectsRemoved)},
+	}
+
+	html := ""
+	for _, card := range cards {
+		html += fmt.Sprintf(`
+            <div class="column">
+                <div class="box has-text-centered stats-card">
+                    <p class="heading">%s</p>
+                    <p class="title %s">%d</p>
+                </div>
+            </div>`, card.label, card.class, card.count)
+	}
+	return html
+}
+
+// generateDiffSectionsHTML generates one Bulma box per kind of change
+func generateDiffSectionsHTML(diff *ReportDiff) string {
+	if !diff.hasChanges() {
+		return `<div class="notification is-success is-light">
+            <i class="fas fa-equals"></i>&nbsp; <strong>No differences</strong>
+        </div>`
+	}
+
+	sections := ""
+	if len(diff.Introduced) > 0 {
+		items := ""
+		for _, f := range diff.Introduced {
+			items += generateDiffFindingHTML(f, "")
+		}
+		sections += generateDiffBoxHTML("fas fa-bug", "Introduced Vulnerabilities", len(diff.Introduced), items)
+	}
+	if len(diff.Resolved) > 0 {
+		items := ""
+		for _, f := range diff.Resolved {
+			f.Fixed = true
+			items += generateDiffFindingHTML(f, "")
+		}
+		sections += generateDiffBoxHTML("fas fa-check-circle", "Resolved Vulnerabilities", len(diff.Resolved), items)
+	}
+	if len(diff.Changed) > 0 {
+		items := ""
+		for _, c := range diff.Changed {
+			items += generateDiffFindingHTML(c.DiffFinding,
+				"version "+c.OldVersion+" → "+c.Version+", "+severityTransition(c.OldSeverity, c.Severity))
+		}
+		sections += generateDiffBoxHTML("fas fa-code-branch", "Changed Vulnerabilities", len(diff.Changed), items)
+	}
+	if len(diff.SeverityChanges) > 0 {
+		items := ""
+		for _, c := range diff.SeverityChanges {
+			items += generateDiffFindingHTML(c.DiffFinding, severityTransition(c.OldSeverity, c.Severity))
+		}
+		sections += generateDiffBoxHTML("fas fa-arrows-up-down", "Severity Changes", len(diff.SeverityChanges), items)
+	}
+
+	projects := ""
+	for _, p := range diff.ProjectsAdded {
+		projects += fmt.Sprintf(`
+                <li><span class="tag %s">added</span> <strong>%s</strong> (%s, %d finding(s))</li>`,
+			BulmaInfo, html.EscapeString(p.Project), html.EscapeString(p.Status), p.Findings)
+	}
+	for _, p := range diff.ProjectsRemoved {
+		projects += fmt.Sprintf(`
+                <li><span class="tag is-light">removed</span> <strong>%s</strong> (%s, %d finding(s))</li>`,
+			html.EscapeString(p.Project), html.EscapeString(p.Status), p.Findings)
+	}
+	for _, c := range diff.StatusChanges {
+		projects += fmt.Sprintf(`
+                <li><span class="tag %s">status</span> <strong>%s</strong>: %s → %s</li>`,
+			BulmaWarning, html.EscapeString(c.Project), html.EscapeString(c.Old), html.EscapeString(c.New))
+	}
+	if projects != "" {
+		count := len(diff.ProjectsAdded) + len(diff.ProjectsRemoved) + len(diff.StatusChanges)
+		sections += generateDiffBoxHTML("fas fa-folder-tree", "Project Changes", count,
+			`<ul>`+projects+`
+            </ul>`)
+	}
+	return sections
+}
+
+// generateDiffBoxHTML wraps a section of the diff in a Bulma box
+func generateDiffBoxHTML(icon, title string, count int, content string) string {
+	return fmt.Sprintf(`
+        <div class="box">
+            <h3 class="title is-4"><i class="%s"></i>&nbsp; %s (%d)</h3>
+            %s
+        </div>`, icon, title, count, content)
+}
+
+// generateDiffFindingHTML renders a finding of the diff with the report's
+// vulnerability item, prefixed by its project and any change
+func generateDiffFindingHTML(finding DiffFinding, change string) string {
+	label := html.EscapeString(finding.Project)
+	if change != "" {
+		label += fmt.Sprintf(` &middot; <span class="tag is-light">%s</span>`, html.EscapeString(change))
+	}
+	return fmt.Sprintf(`
+            <p class="heading">%s</p>%s`, label, generateVulnerabilityItemHTML(finding.Vulnerability))
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pality/npm-security-scanner/scanner"
+	"github.com/spf13/pflag"
+)
+
+// Report formats selectable with --format (reports.formats)
+const (
+	ReportFormatTerminal = "terminal"
+	ReportFormatHTML     = "html"
+	ReportFormatJSON     = "json"
+	ReportFormatSARIF    = "sarif"
+	// CycloneDX 1.5 SBOMs per project
+	ReportFormatCycloneDXJSON = "cyclonedx-json"
+	ReportFormatCycloneDXXML  = "cyclonedx-xml"
+)
+
+// Reporter renders currentReport in one output format. Reporters are
+// registered by their --format name with registerReporter.
+type Reporter interface {
+	Write(out *reportOutput) error
+}
+
+var (
+	// reporters maps each --format name to its reporter
+	reporters = make(map[string]Reporter)
+	// reporterNames lists the registered formats in registration order
+	reporterNames []string
+)
+
+// registerReporter makes a reporter selectable with --format name
+func registerReporter(name string, reporter Reporter) {
+	if _, exists := reporters[name]; exists {
+		panic("reporter already registered: " + name)
+	}
+	reporters[name] = reporter
+	reporterNames = append(reporterNames, name)
+}
+
+func init() {
+	registerReporter(ReportFormatTerminal, terminalReporter{})
+	registerReporter(ReportFormatHTML, fileReporter{ReportFormatHTML, "HTML Report", HTMLExtension, renderHTMLReport})
+	registerReporter(ReportFormatJSON, fileReporter{ReportFormatJSON, "JSON Report", JSONExtension, renderJSONReport})
+	registerReporter(ReportFormatSARIF,
+		fileReporter{ReportFormatSARIF, "SARIF Report", SARIFExtension, renderSARIFReport})
+	registerReporter(ReportFormatCycloneDXJSON,
+		sbomReporter{ReportFormatCycloneDXJSON, CycloneDXJSONExtension, scanner.WriteCycloneDXJSON})
+	registerReporter(ReportFormatCycloneDXXML,
+		sbomReporter{ReportFormatCycloneDXXML, CycloneDXXMLExtension, scanner.WriteCycloneDXXML})
+}
+
+var (
+	// reportsDir is the directory report files are written to (--output-dir, reports.dir)
+	reportsDir = ReportsDirName
+	// reportFormats are the reports rendered after each scan (--format, reports.formats)
+	reportFormats = defaultReportFormats()
+	// reportOutputPath replaces the output directory for the single selected
+	// format (--output); "-" writes the report to stdout
+	reportOutputPath string
+	// reportStdout is where the terminal report and --output - are written
+	reportStdout io.Writer = os.Stdout
+)
+
+// defaultReportFormats returns the reports rendered when no format is configured
+func defaultReportFormats() []string {
+	return []string{ReportFormatTerminal, ReportFormatHTML, ReportFormatJSON}
+}
+
+// addReportFlags registers the report selection flags of the scanning commands
+func addReportFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&reportFormats, "format", defaultReportFormats(),
+		"reports rendered after the scan ("+strings.Join(reporterNames, ", ")+")")
+	flags.StringVar(&reportsDir, "output-dir", ReportsDirName,
+		"directory the report files are written to")
+	flags.StringVarP(&reportOutputPath, "output", "o", "",
+		"write the single selected --format to this file instead; - writes it to stdout and progress to stderr")
+}
+
+// validateReportFormats rejects unknown report formats and an --output shared by several formats
+func validateReportFormats(formats []string) error {
+	for _, format := range formats {
+		if _, ok := reporters[format]; !ok {
+			return fmt.Errorf("invalid report format %q (expected %s)", format, strings.Join(reporterNames, ", "))
+		}
+	}
+	if reportOutputPath != "" && len(formats) != 1 {
+		return fmt.Errorf("--output takes a single report; select exactly one --format (got %d)", len(formats))
+	}
+	return nil
+}
+
+// hasReportFormat reports whether the report format is enabled
+func hasReportFormat(format string) bool {
+	for _, f := range reportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// routeProgressOutput moves all progress output to stderr when the event stream
+// or a report other than the terminal summary goes to stdout, so it can be
+// piped into jq. Progress is written to color.Output, never to os.Stdout directly.
+func routeProgressOutput() {
+	switch {
+	case eventsPath == "-":
+		// 標準出力にはイベントだけを書き込み、ターミナル表示も標準エラー出力へ移す
+		reportStdout = os.Stderr
+	case reportOutputPath == "-" && !hasReportFormat(ReportFormatTerminal):
+		reportStdout = os.Stdout
+	default:
+		return
+	}
+	color.Output = color.Error
+}
+
+// reportOutput is where the reporters write: report files go to the output
+// directory, or to --output when a single format is selected
+type reportOutput struct {
+	dir  string
+	path string
+	// files are the report files written so far, by format
+	files map[string]string
+	// used is set once the --output file has been written
+	used bool
+}
+
+// newReportOutput returns the report destination configured by the flags
+func newReportOutput() *reportOutput {
+	return &reportOutput{dir: reportsDir, path: reportOutputPath, files: make(map[string]string)}
+}
+
+// writeFile writes a report file named name in the output directory, or to --output
+func (o *reportOutput) writeFile(format, label, name string, render func(io.Writer) error) error {
+	var data bytes.Buffer
+	if err := render(&data); err != nil {
+		return err
+	}
+
+	filename := filepath.Join(o.dir, name)
+	if o.path != "" {
+		// --outputには1つのファイルしか書き出せない
+		if o.used {
+			return fmt.Errorf("%s writes one file per project; use --output-dir instead of --output", format)
+		}
+		o.used = true
+		if o.path == "-" {
+			_, err := reportStdout.Write(data.Bytes())
+			return err
+		}
+		filename = o.path
+	} else if err := os.MkdirAll(o.dir, scanner.DirPermSecure); err != nil {
+		return fmt.Errorf("failed to create reports directory: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data.Bytes(), scanner.FilePermSecure); err != nil {
+		return fmt.Errorf("failed to write %s: %w", label, err)
+	}
+	o.files[format] = filename
+	successColor.Printf("📄 %s generated: %s\n", label, filename)
+	return nil
+}
+
+// writeStdout writes a report meant for the terminal to stdout, or to the --output file
+func (o *reportOutput) writeStdout(format, label string, render func(io.Writer) error) error {
+	if o.path == "" || o.path == "-" {
+		return render(reportStdout)
+	}
+	// ファイルにはエスケープシーケンスを書き込まない
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+	return o.writeFile(format, label, "", render)
+}
+
+// terminalReporter prints the human-readable summary
+type terminalReporter struct{}
+
+func (terminalReporter) Write(out *reportOutput) error {
+	return out.writeStdout(ReportFormatTerminal, "Terminal Report", func(w io.Writer) error {
+		printTerminalReport(w)
+		return nil
+	})
+}
+
+// fileReporter writes the whole report to one file named after the scan ID
+type fileReporter struct {
+	format    string
+	label     string
+	extension string
+	render    func(io.Writer) error
+}
+
+func (r fileReporter) Write(out *reportOutput) error {
+	return out.writeFile(r.format, r.label, currentReport.ScanID+r.extension, r.render)
+}
+
+// renderHTMLReport renders the Bulma HTML report
+func renderHTMLReport(w io.Writer) error {
+	_, err := io.WriteString(w, generateHTMLContent())
+	return err
+}
+
+// renderJSONReport renders the report as indented JSON, readable by loadReport
+func renderJSONReport(w io.Writer) error {
+	data, err := json.MarshalIndent(currentReport, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON report: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// renderSARIFReport renders the SARIF 2.1.0 log
+func renderSARIFReport(w io.Writer) error {
+	return scanner.WriteSARIF(w, currentReport)
+}
+
+// sbomReporter writes a CycloneDX BOM per scanned project
+type sbomReporter struct {
+	format    string
+	extension string
+	write     func(io.Writer, *scanner.ScanResult) error
+}
+
+func (r sbomReporter) Write(out *reportOutput) error {
+	for i := range currentReport.Results {
+		result := &currentReport.Results[i]
+		var data bytes.Buffer
+		// 依存関係を読み取れないプロジェクトはスキップして他のプロジェクトを出力する
+		if err := r.write(&data, result); err != nil {
+			warningColor.Printf("⚠️  %v\n", err)
+			continue
+		}
+		err := out.writeFile(r.format, "CycloneDX SBOM", sbomFileName(currentReport, result, r.extension),
+			func(w io.Writer) error {
+				_, err := w.Write(data.Bytes())
+				return err
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// showScanResults renders the scan report in every selected format and records the scan history
+func showScanResults() {
+	if currentReport == nil {
+		return
+	}
+	out := newReportOutput()
+
+	// ターミナル表示は履歴の記録やファイル出力より先に行う
+	if hasReportFormat(ReportFormatTerminal) {
+		if err := reporters[ReportFormatTerminal].Write(out); err != nil {
+			errorColor.Printf("❌ Failed to write terminal report: %v\n", err)
+		}
+	}
+
+	// 中断された部分的な結果は推移を歪めるため履歴に残さない
+	if recordHistory && !currentReport.Interrupted {
+		if err := appendHistory(historyPath(), currentReport); err != nil {
+			errorColor.Printf("❌ Failed to record scan history: %v\n", err)
+		}
+	}
+
+	var formats []string
+	for _, format := range reportFormats {
+		if format != ReportFormatTerminal {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		return
+	}
+	infoColor.Println("📄 Generating reports...")
+
+	for _, format := range formats {
+		if err := reporters[format].Write(out); err != nil {
+			errorColor.Printf("❌ Failed to generate %s report: %v\n", format, err)
+		}
+	}
+
+	successColor.Println("✅ Reports generated successfully!")
+
+	// Show report links
+	htmlReportPath, jsonReportPath := out.files[ReportFormatHTML], out.files[ReportFormatJSON]
+	if htmlReportPath != "" || jsonReportPath != "" {
+		showReportLinks(htmlReportPath, jsonReportPath)
+	}
+}
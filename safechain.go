@@ -1,45 +1,67 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pality/npm-security-scanner/scanner"
+)
+
+var (
+	// errTerminalRestartRequired is returned after Safe Chain was installed;
+	// the shell integration only takes effect in a new terminal
+	errTerminalRestartRequired = errors.New("terminal restart required")
+	// errSafeChainNotInstalled is returned when Safe Chain is missing and the
+	// session cannot confirm installing it (--yes or non-interactive)
+	errSafeChainNotInstalled = errors.New("safe chain is not installed")
 )
 
-// checkSafeChainInstallation checks and optionally installs Safe Chain
-func checkSafeChainInstallation() error {
+// checkSafeChainInstallation checks and optionally installs Safe Chain. The
+// global install is only offered in an interactive session.
+func checkSafeChainInstallation(ctx context.Context) error {
 	infoColor.Println("🔧 Checking Safe Chain installation...")
 
 	// safe-chainコマンドで確認
-	if err := checkCommand("safe-chain"); err != nil {
+	if err := checkCommand(options.CommandRunner(), options.SafeChainCommand); err != nil {
 		warningColor.Println("⚠️  Safe Chain is not installed globally")
 
-		if !askForConfirmation("Would you like to install Safe Chain now?") {
+		// --yesや非対話モードではCIホストへのグローバルインストールを自動で行わない
+		if assumeYes || nonInteractive {
+			warningColor.Println("📋 Install Safe Chain before scanning:")
+			warningColor.Printf("   1. Run: npm install -g %s\n", options.SafeChainPackage)
+			warningColor.Printf("   2. Run: %s setup\n", options.SafeChainCommand)
+			return fmt.Errorf("%w (it is never installed automatically with --yes or in non-interactive mode)",
+				errSafeChainNotInstalled)
+		}
+
+		if !askForConfirmation("Would you like to install Safe Chain now?", false) {
 			warningColor.Println("🔧 Running in demo mode without Safe Chain")
 			warningColor.Println("📋 To install Safe Chain later:")
-			warningColor.Println("   1. Run: npm install -g safe-chain-test")
-			warningColor.Println("   2. Run: safe-chain setup")
+			warningColor.Printf("   1. Run: npm install -g %s\n", options.SafeChainPackage)
+			warningColor.Printf("   2. Run: %s setup\n", options.SafeChainCommand)
 			warningColor.Println("   3. Restart your terminal")
 			return nil
 		}
 
 		// Safe Chainのインストール
-		if err := installSafeChain(); err != nil {
+		if err := installSafeChain(ctx); err != nil {
 			return fmt.Errorf("failed to install Safe Chain: %w", err)
 		}
 
 		infoColor.Println("✅ Safe Chain installation completed")
 		warningColor.Println("🔄 Please restart your terminal and run the scanner again")
 		warningColor.Println("💡 After restart, Safe Chain setup will be automatically completed")
-		return fmt.Errorf("terminal restart required")
+		return errTerminalRestartRequired
 	}
 
 	// Safe Chainセットアップの確認
 	infoColor.Println("🔧 Checking Safe Chain setup status...")
-	if !isSafeChainSetupComplete() {
+	if !scanner.IsSafeChainSetupComplete(ctx, options.CommandRunner(), options.SafeChainCommand, color.Output) {
 		warningColor.Println("⚠️  Safe Chain is not properly set up")
 		warningColor.Println("📋 Please run the following commands:")
-		warningColor.Println("   1. Run: safe-chain setup")
+		warningColor.Printf("   1. Run: %s setup\n", options.SafeChainCommand)
 		warningColor.Println("   2. Restart your terminal")
 		warningColor.Println("🔧 Continuing in demo mode...")
 		return nil
@@ -50,12 +72,12 @@ func checkSafeChainInstallation() error {
 }
 
 // installSafeChain installs Safe Chain globally using npm
-func installSafeChain() error {
+func installSafeChain(ctx context.Context) error {
 	infoColor.Println("📦 Installing Safe Chain globally...")
 
 	// npm install -g safe-chain-test
-	cmd := exec.Command("npm", "install", "-g", "safe-chain-test")
-	output, err := cmd.CombinedOutput()
+	output, err := options.CommandRunner().Run(ctx, scanner.Command{Name: "npm",
+		Args: []string{"install", "-g", options.SafeChainPackage}, Combined: true, Timeout: scanner.DefaultInstallTimeout})
 	if err != nil {
 		return fmt.Errorf("npm install failed: %w\nOutput: %s", err, string(output))
 	}
@@ -63,9 +85,9 @@ func installSafeChain() error {
 	successColor.Println("✅ Safe Chain package installed")
 
 	// safe-chain setup実行
-	infoColor.Println("⚙️  Running safe-chain setup...")
-	setupCmd := exec.Command("safe-chain", "setup")
-	setupOutput, err := setupCmd.CombinedOutput()
+	infoColor.Printf("⚙️  Running %s setup...\n", options.SafeChainCommand)
+	setupOutput, err := options.CommandRunner().Run(ctx, scanner.Command{Name: options.SafeChainCommand,
+		Args: []string{"setup"}, Combined: true, Timeout: scanner.DefaultInstallTimeout})
 	if err != nil {
 		// setupコマンドが失敗した場合も続行（初回インストール時によくある）
 		warningColor.Printf("⚠️  Setup command output: %s\n", string(setupOutput))
@@ -77,279 +99,3 @@ func installSafeChain() error {
 
 	return nil
 }
-
-// isSafeChainSetupComplete checks if Safe Chain setup is complete
-func isSafeChainSetupComplete() bool {
-	// safe-chainコマンドが利用可能かどうかチェック
-	if err := checkCommand("safe-chain"); err != nil {
-		return false
-	}
-
-	// Safe Chainのセットアップを詳細チェック
-	// 複数のシェル環境で確認
-	shells := []string{"zsh", "bash", "sh"}
-
-	for _, shell := range shells {
-		// shellのfunction/aliasがセットアップされているかチェック
-		cmd := exec.Command(shell, "-c", "type npm 2>/dev/null")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			continue
-		}
-
-		outputStr := string(output)
-		// aliasまたはshell functionとして設定されているかチェック
-		if strings.Contains(outputStr, "aliased to") ||
-			strings.Contains(outputStr, "shell function") ||
-			strings.Contains(outputStr, "function") {
-			return true
-		}
-	}
-
-	// Safe Chainコマンドが存在すれば、セットアップ済みとして扱う
-	// （実際の環境ではsetupが完了していない可能性があるが、利用可能として継続）
-	infoColor.Println("  ℹ️  Safe Chain is installed but setup may not be complete in current shell")
-	return true
-}
-
-// runSecurityScan executes security scan in the given project directory
-func runSecurityScan(projectDir string, result *ScanResult) error {
-	infoColor.Printf("  🔍 Running security scan in %s...\n", projectDir)
-
-	if err := checkCommand("safe-chain"); err != nil {
-		warningColor.Printf("  ⚠️  Safe Chain not found, running demo scan for %s\n", projectDir)
-		return runDemoScan(projectDir, result)
-	}
-
-	auditOutput, auditErr := executeNpmAudit(projectDir)
-	fixOutput, fixErr := executeNpmAuditFix(projectDir)
-
-	processAuditResults(result, auditOutput, auditErr)
-	processFixResults(result, fixOutput, fixErr, projectDir)
-	displayScanResults(result, projectDir)
-
-	return nil
-}
-
-// executeNpmAudit executes npm audit command
-func executeNpmAudit(projectDir string) (string, error) {
-	infoColor.Printf("  🔍 Running npm audit (wrapped by Safe Chain) in %s...\n", projectDir)
-	auditCmd := exec.Command("npm", "audit", "--audit-level=moderate")
-	auditCmd.Dir = projectDir
-	auditOutput, auditErr := auditCmd.CombinedOutput()
-	return string(auditOutput), auditErr
-}
-
-// executeNpmAuditFix executes npm audit fix command
-func executeNpmAuditFix(projectDir string) (string, error) {
-	infoColor.Printf("  🔧 Running npm audit fix (wrapped by Safe Chain) in %s...\n", projectDir)
-	fixCmd := exec.Command("npm", "audit", "fix")
-	fixCmd.Dir = projectDir
-	fixOutput, fixErr := fixCmd.CombinedOutput()
-	return string(fixOutput), fixErr
-}
-
-// processAuditResults processes npm audit results
-func processAuditResults(result *ScanResult, auditOutput string, auditErr error) {
-	result.SecurityScan.Output = auditOutput
-	result.Vulnerabilities = parseVulnerabilities(auditOutput)
-
-	if auditErr != nil {
-		// exit status 1は脆弱性発見を意味するので、成功として扱う
-		if strings.Contains(auditErr.Error(), "exit status 1") {
-			result.SecurityScan.Success = true
-		} else {
-			result.SecurityScan.Success = false
-			result.SecurityScan.Error = auditErr.Error()
-		}
-	} else {
-		result.SecurityScan.Success = true
-	}
-}
-
-// processFixResults processes npm audit fix results
-func processFixResults(result *ScanResult, fixOutput string, fixErr error, projectDir string) {
-	if fixErr == nil {
-		infoColor.Printf("  🔧 Fix results:\n%s\n", fixOutput)
-		successColor.Printf("  ✅ Security scan completed in %s\n", projectDir)
-		// Update vulnerabilities as fixed if fix was successful
-		for i := range result.Vulnerabilities {
-			result.Vulnerabilities[i].Fixed = true
-		}
-	} else {
-		warningColor.Printf("  ⚠️  Some fixes may not have been applied: %s\n", fixOutput)
-		successColor.Printf("  ✅ Security scan completed in %s (with warnings)\n", projectDir)
-	}
-}
-
-// displayScanResults displays scan results summary
-func displayScanResults(result *ScanResult, projectDir string) {
-	infoColor.Printf("  📊 Security scan results for %s:\n", projectDir)
-	if len(result.Vulnerabilities) > 0 {
-		warningColor.Printf("  🚨 Found %d vulnerabilities\n", len(result.Vulnerabilities))
-	} else {
-		successColor.Printf("  🛡️  No vulnerabilities detected\n")
-	}
-}
-
-// runDemoScan runs a demo scan when Safe Chain is not available
-func runDemoScan(projectDir string, result *ScanResult) error {
-	infoColor.Printf("  📊 Demo scan results for %s:\n", projectDir)
-	successColor.Printf("  ✅ Demo scan completed - no vulnerabilities detected in %s\n", projectDir)
-	warningColor.Printf("  💡 Install Safe Chain for real vulnerability scanning\n")
-	warningColor.Printf("      1. Run: npm install -g safe-chain-test\n")
-	warningColor.Printf("      2. Run: safe-chain setup\n")
-	warningColor.Printf("      3. Restart your terminal\n")
-
-	// Demo結果をレポートに記録
-	result.SecurityScan.Success = true
-	result.SecurityScan.Output = "Demo scan - no vulnerabilities detected"
-	result.Vulnerabilities = []Vulnerability{} // No vulnerabilities in demo mode
-
-	return nil
-}
-
-// parseVulnerabilities parses npm audit output and extracts vulnerabilities
-func parseVulnerabilities(auditOutput string) []Vulnerability {
-	vulnerabilities := []Vulnerability{}
-
-	// Parse direct vulnerability information
-	vulnerabilities = append(vulnerabilities, parseSeverityLines(auditOutput)...)
-
-	// Parse vulnerability count information
-	vulnerabilities = append(vulnerabilities, parseVulnerabilityCountLines(auditOutput)...)
-
-	// Remove duplicates
-	return removeDuplicateVulnerabilities(vulnerabilities)
-}
-
-// parseSeverityLines parses lines containing "Severity: " information
-func parseSeverityLines(auditOutput string) []Vulnerability {
-	vulnerabilities := []Vulnerability{}
-
-	if !strings.Contains(auditOutput, "vulnerabilities") {
-		return vulnerabilities
-	}
-
-	lines := strings.Split(auditOutput, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Parse direct severity information
-		if strings.Contains(line, "Severity: ") {
-			vuln := parseSeverityLine(line)
-			if vuln != nil {
-				vulnerabilities = append(vulnerabilities, *vuln)
-			}
-		}
-
-		// Update package name if found
-		if strings.Contains(line, "node_modules/") && len(vulnerabilities) > 0 {
-			updatePackageName(line, &vulnerabilities[len(vulnerabilities)-1])
-		}
-	}
-
-	return vulnerabilities
-}
-
-// parseSeverityLine parses a single line containing severity information
-func parseSeverityLine(line string) *Vulnerability {
-	parts := strings.Split(line, "Severity: ")
-	if len(parts) <= 1 {
-		return nil
-	}
-
-	severity := strings.ToLower(strings.TrimSpace(parts[1]))
-	return &Vulnerability{
-		Severity:    severity,
-		Package:     "detected-package",
-		Description: fmt.Sprintf("%s severity vulnerability found", severity),
-		Fixed:       false,
-	}
-}
-
-// updatePackageName updates the package name from node_modules path
-func updatePackageName(line string, vuln *Vulnerability) {
-	parts := strings.Split(line, "node_modules/")
-	if len(parts) > 1 {
-		packageName := strings.Split(parts[1], " ")[0]
-		vuln.Package = packageName
-	}
-}
-
-// parseVulnerabilityCountLines parses lines containing vulnerability count information
-func parseVulnerabilityCountLines(auditOutput string) []Vulnerability {
-	vulnerabilities := []Vulnerability{}
-
-	if !strings.Contains(auditOutput, VulnerabilityText) {
-		return vulnerabilities
-	}
-
-	lines := strings.Split(auditOutput, "\n")
-	for _, line := range lines {
-		if hasVulnerabilityInfo(line) {
-			vulns := parseVulnerabilityCountLine(line)
-			vulnerabilities = append(vulnerabilities, vulns...)
-		}
-	}
-
-	return vulnerabilities
-}
-
-// hasVulnerabilityInfo checks if line contains vulnerability information
-func hasVulnerabilityInfo(line string) bool {
-	hasVulnText := strings.Contains(line, VulnerabilityText)
-	hasFoundText := strings.Contains(line, "found") ||
-		strings.Contains(line, SeverityModerate) ||
-		strings.Contains(line, SeverityHigh) ||
-		strings.Contains(line, SeverityCritical) ||
-		strings.Contains(line, SeverityLow)
-	return hasVulnText && hasFoundText
-}
-
-// parseVulnerabilityCountLine parses a line containing vulnerability count
-func parseVulnerabilityCountLine(line string) []Vulnerability {
-	vulnerabilities := []Vulnerability{}
-	words := strings.Fields(line)
-
-	for i, word := range words {
-		if isSeverityWord(word) && i > 0 {
-			severity := strings.ToLower(word)
-			vulnerabilities = append(vulnerabilities, Vulnerability{
-				Severity:    severity,
-				Package:     "npm-audit-detected",
-				Description: fmt.Sprintf("%s severity vulnerability detected via npm audit", severity),
-				Fixed:       false,
-			})
-			break
-		}
-	}
-
-	return vulnerabilities
-}
-
-// isSeverityWord checks if word is a severity keyword
-func isSeverityWord(word string) bool {
-	switch word {
-	case "moderate", "high", "critical", "low":
-		return true
-	default:
-		return false
-	}
-}
-
-// removeDuplicateVulnerabilities removes duplicate vulnerabilities
-func removeDuplicateVulnerabilities(vulnerabilities []Vulnerability) []Vulnerability {
-	uniqueVulns := []Vulnerability{}
-	seen := make(map[string]bool)
-
-	for _, v := range vulnerabilities {
-		key := v.Severity + v.Package + v.Description
-		if !seen[key] {
-			uniqueVulns = append(uniqueVulns, v)
-			seen[key] = true
-		}
-	}
-
-	return uniqueVulns
-}
@@ -0,0 +1,577 @@
+package scanner
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// advisory is a vulnerability record loaded from a local advisory database
+type advisory struct {
+	ID       string
+	Summary  string
+	Severity string
+	URL      string
+	Aliases  []string
+	Affected []affectedPackage
+	CVSS     float64
+}
+
+// affectedPackage describes which versions of an npm package an advisory affects
+type affectedPackage struct {
+	Versions  map[string]bool
+	Name      string
+	RangeText string
+	Intervals []advisoryInterval
+}
+
+// advisoryInterval is one contiguous vulnerable range and the version that fixes it
+type advisoryInterval struct {
+	Fixed       string
+	Comparators []semverComparator
+}
+
+// AdvisoryDatabase indexes advisories by npm package name
+type AdvisoryDatabase struct {
+	byPackage map[string][]advisoryEntry
+	seen      map[string]bool
+	// Source is the path the database was loaded from
+	Source string
+	Count  int
+}
+
+// advisoryEntry links an advisory to one of its affected packages
+type advisoryEntry struct {
+	advisory *advisory
+	affected *affectedPackage
+}
+
+// osvRecord is an advisory in the OSV schema (osv.dev dumps, GHSA in OSV format)
+type osvRecord struct {
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+	ID         string         `json:"id"`
+	Summary    string         `json:"summary"`
+	Details    string         `json:"details"`
+	Withdrawn  string         `json:"withdrawn"`
+	Aliases    []string       `json:"aliases"`
+	Severity   []osvSeverity  `json:"severity"`
+	Affected   []osvAffected  `json:"affected"`
+	References []osvReference `json:"references"`
+}
+
+// osvSeverity is a severity score such as a CVSS vector
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// osvReference is a link attached to an OSV advisory
+type osvReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// osvAffected is an affected package with its vulnerable ranges and versions
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+// osvRange is an ordered list of introduced/fixed events
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+// osvEvent is a single range event; exactly one field is set
+type osvEvent struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+	Limit        string `json:"limit"`
+}
+
+// githubAdvisoryRecord is an advisory from the GitHub global advisories REST API
+type githubAdvisoryRecord struct {
+	CVSS struct {
+		VectorString string  `json:"vector_string"`
+		Score        float64 `json:"score"`
+	} `json:"cvss"`
+	GhsaID          string                 `json:"ghsa_id"`
+	CveID           string                 `json:"cve_id"`
+	Summary         string                 `json:"summary"`
+	Severity        string                 `json:"severity"`
+	HTMLURL         string                 `json:"html_url"`
+	Withdrawn       string                 `json:"withdrawn_at"`
+	Identifiers     []githubIdentifier     `json:"identifiers"`
+	Vulnerabilities []githubVulnerableSpec `json:"vulnerabilities"`
+}
+
+// githubIdentifier is a GHSA or CVE identifier of a GitHub advisory
+type githubIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// githubVulnerableSpec is an affected package of a GitHub advisory
+type githubVulnerableSpec struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	VulnerableVersionRange string               `json:"vulnerable_version_range"`
+	FirstPatchedVersion    githubPatchedVersion `json:"first_patched_version"`
+}
+
+// githubPatchedVersion accepts both "1.2.3" and {"identifier": "1.2.3"}
+type githubPatchedVersion string
+
+// UnmarshalJSON decodes the patched version in either export format
+func (v *githubPatchedVersion) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = githubPatchedVersion(s)
+		return nil
+	}
+
+	var obj struct {
+		Identifier string `json:"identifier"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*v = githubPatchedVersion(obj.Identifier)
+	return nil
+}
+
+// LoadAdvisoryDatabase loads advisories from a JSON file, a directory of JSON
+// files or a zip archive (as published by osv.dev). Files may contain a single
+// advisory, an array of advisories or one advisory per line.
+func LoadAdvisoryDatabase(path string) (*AdvisoryDatabase, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open advisory database: %w", err)
+	}
+
+	db := &AdvisoryDatabase{
+		Source:    path,
+		byPackage: make(map[string][]advisoryEntry),
+		seen:      make(map[string]bool),
+	}
+
+	switch {
+	case info.IsDir():
+		err = db.loadDirectory(path)
+	case strings.EqualFold(filepath.Ext(path), ".zip"):
+		err = db.loadZip(path)
+	default:
+		var data []byte
+		if data, err = os.ReadFile(path); err == nil {
+			err = db.loadDocument(data)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load advisory database %s: %w", path, err)
+	}
+
+	for name := range db.byPackage {
+		entries := db.byPackage[name]
+		sort.Slice(entries, func(i, j int) bool { return entries[i].advisory.ID < entries[j].advisory.ID })
+	}
+	return db, nil
+}
+
+// loadDirectory loads every .json file below dir
+func (db *AdvisoryDatabase) loadDirectory(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := db.loadDocument(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// loadZip loads every .json file inside a zip archive
+func (db *AdvisoryDatabase) loadZip(path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(file.Name), ".json") {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := db.loadDocument(data); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// loadDocument loads all advisory records in a JSON document
+func (db *AdvisoryDatabase) loadDocument(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		records := []json.RawMessage{raw}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(raw, &records); err != nil {
+				return err
+			}
+		}
+
+		for _, record := range records {
+			if err := db.loadRecord(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// loadRecord detects the format of a single advisory record and indexes it
+func (db *AdvisoryDatabase) loadRecord(data json.RawMessage) error {
+	var probe struct {
+		GhsaID   *string          `json:"ghsa_id"`
+		Affected *json.RawMessage `json:"affected"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+
+	var adv *advisory
+	switch {
+	case probe.GhsaID != nil:
+		var record githubAdvisoryRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		if record.Withdrawn != "" {
+			return nil
+		}
+		adv = convertGitHubAdvisory(&record)
+	case probe.Affected != nil:
+		var record osvRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		if record.Withdrawn != "" {
+			return nil
+		}
+		adv = convertOSVAdvisory(&record)
+	default:
+		return errors.New("unrecognized advisory record (expected OSV or GitHub advisory format)")
+	}
+
+	db.add(adv)
+	return nil
+}
+
+// add indexes an advisory. The same advisory may appear in several exports;
+// only the first copy is kept.
+func (db *AdvisoryDatabase) add(adv *advisory) {
+	if adv.ID == "" || len(adv.Affected) == 0 {
+		return
+	}
+	keys := []string{adv.ID}
+	for _, alias := range adv.Aliases {
+		if strings.HasPrefix(alias, "GHSA-") {
+			keys = append(keys, alias)
+		}
+	}
+	for _, key := range keys {
+		if db.seen[key] {
+			return
+		}
+	}
+	for _, key := range keys {
+		db.seen[key] = true
+	}
+
+	db.Count++
+	for i := range adv.Affected {
+		affected := &adv.Affected[i]
+		db.byPackage[affected.Name] = append(db.byPackage[affected.Name], advisoryEntry{advisory: adv, affected: affected})
+	}
+}
+
+// convertOSVAdvisory converts the npm entries of an OSV record
+func convertOSVAdvisory(record *osvRecord) *advisory {
+	adv := &advisory{
+		ID:      record.ID,
+		Summary: firstNonEmpty(record.Summary, firstLine(record.Details), record.ID),
+		Aliases: record.Aliases,
+		URL:     osvAdvisoryURL(record),
+	}
+
+	for _, sev := range record.Severity {
+		if score, ok := cvssV3BaseScore(sev.Score); ok && score > adv.CVSS {
+			adv.CVSS = score
+		}
+	}
+
+	switch {
+	case record.DatabaseSpecific.Severity != "":
+		adv.Severity = normalizeSeverity(record.DatabaseSpecific.Severity)
+	case adv.CVSS > 0:
+		adv.Severity = severityFromCVSS(adv.CVSS)
+	default:
+		adv.Severity = SeverityModerate
+	}
+
+	for _, a := range record.Affected {
+		if !strings.EqualFold(a.Package.Ecosystem, "npm") || a.Package.Name == "" {
+			continue
+		}
+
+		affected := affectedPackage{Name: a.Package.Name, Versions: make(map[string]bool)}
+		for _, v := range a.Versions {
+			affected.Versions[v] = true
+		}
+		var ranges []string
+		for _, r := range a.Ranges {
+			if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+				continue
+			}
+			intervals, text := osvIntervals(r.Events)
+			affected.Intervals = append(affected.Intervals, intervals...)
+			ranges = append(ranges, text...)
+		}
+		affected.RangeText = strings.Join(ranges, " || ")
+		adv.Affected = append(adv.Affected, affected)
+	}
+	return adv
+}
+
+// osvIntervals turns ordered introduced/fixed events into vulnerable intervals
+func osvIntervals(events []osvEvent) ([]advisoryInterval, []string) {
+	var intervals []advisoryInterval
+	var texts []string
+	var current *advisoryInterval
+	var currentText []string
+
+	closeInterval := func() {
+		if current != nil {
+			intervals = append(intervals, *current)
+			texts = append(texts, strings.Join(currentText, " "))
+			current, currentText = nil, nil
+		}
+	}
+
+	for _, ev := range events {
+		switch {
+		case ev.Introduced != "":
+			closeInterval()
+			current = &advisoryInterval{}
+			if ev.Introduced == "0" {
+				currentText = []string{">=0.0.0"}
+				continue
+			}
+			v, err := parseSemver(ev.Introduced)
+			if err != nil {
+				// 解釈できない区間は全バージョンに一致させない
+				current = nil
+				continue
+			}
+			current.Comparators = append(current.Comparators, semverComparator{op: ">=", version: v})
+			currentText = []string{">=" + ev.Introduced}
+		case current == nil:
+			continue
+		case ev.Fixed != "":
+			if v, err := parseSemver(ev.Fixed); err == nil {
+				current.Comparators = append(current.Comparators, semverComparator{op: "<", version: v})
+				current.Fixed = ev.Fixed
+				currentText = append(currentText, "<"+ev.Fixed)
+			}
+			closeInterval()
+		case ev.LastAffected != "":
+			if v, err := parseSemver(ev.LastAffected); err == nil {
+				current.Comparators = append(current.Comparators, semverComparator{op: "<=", version: v})
+				currentText = append(currentText, "<="+ev.LastAffected)
+			}
+			closeInterval()
+		case ev.Limit != "":
+			if v, err := parseSemver(ev.Limit); err == nil {
+				current.Comparators = append(current.Comparators, semverComparator{op: "<", version: v})
+				currentText = append(currentText, "<"+ev.Limit)
+			}
+			closeInterval()
+		}
+	}
+	closeInterval()
+	return intervals, texts
+}
+
+// osvAdvisoryURL picks the most specific advisory link of an OSV record
+func osvAdvisoryURL(record *osvRecord) string {
+	for _, want := range []string{"ADVISORY", "WEB"} {
+		for _, ref := range record.References {
+			if ref.Type == want {
+				return ref.URL
+			}
+		}
+	}
+	if strings.HasPrefix(record.ID, "GHSA-") {
+		return "https://github.com/advisories/" + record.ID
+	}
+	if len(record.References) > 0 {
+		return record.References[0].URL
+	}
+	return ""
+}
+
+// convertGitHubAdvisory converts the npm entries of a GitHub advisory
+func convertGitHubAdvisory(record *githubAdvisoryRecord) *advisory {
+	adv := &advisory{
+		ID:       record.GhsaID,
+		Summary:  firstNonEmpty(record.Summary, record.GhsaID),
+		Severity: normalizeSeverity(record.Severity),
+		URL:      firstNonEmpty(record.HTMLURL, "https://github.com/advisories/"+record.GhsaID),
+		CVSS:     record.CVSS.Score,
+	}
+	if adv.CVSS == 0 {
+		adv.CVSS, _ = cvssV3BaseScore(record.CVSS.VectorString)
+	}
+	if record.CveID != "" {
+		adv.Aliases = append(adv.Aliases, record.CveID)
+	}
+	for _, id := range record.Identifiers {
+		if id.Value != adv.ID && id.Value != record.CveID {
+			adv.Aliases = append(adv.Aliases, id.Value)
+		}
+	}
+
+	for _, spec := range record.Vulnerabilities {
+		if !strings.EqualFold(spec.Package.Ecosystem, "npm") || spec.Package.Name == "" {
+			continue
+		}
+
+		affected := affectedPackage{Name: spec.Package.Name, RangeText: spec.VulnerableVersionRange}
+		r, err := parseSemverRange(spec.VulnerableVersionRange)
+		if err != nil {
+			continue
+		}
+		for _, set := range r {
+			affected.Intervals = append(affected.Intervals, advisoryInterval{
+				Comparators: set,
+				Fixed:       string(spec.FirstPatchedVersion),
+			})
+		}
+		adv.Affected = append(adv.Affected, affected)
+	}
+	return adv
+}
+
+// lookup returns a vulnerability for every advisory affecting name@version
+func (db *AdvisoryDatabase) lookup(name, version string) []Vulnerability {
+	entries := db.byPackage[name]
+	if len(entries) == 0 {
+		return nil
+	}
+
+	v, err := parseSemver(version)
+	if err != nil {
+		// git/file/tarball依存などsemverでないバージョンは照合できない
+		return nil
+	}
+
+	var vulnerabilities []Vulnerability
+	for _, entry := range entries {
+		interval, ok := entry.affected.match(version, v)
+		if !ok {
+			continue
+		}
+		vulnerabilities = append(vulnerabilities, entry.advisory.toVulnerability(entry.affected, version, interval))
+	}
+	return vulnerabilities
+}
+
+// match reports whether the version is affected and returns the matching interval
+func (a *affectedPackage) match(raw string, v semverVersion) (advisoryInterval, bool) {
+	for _, interval := range a.Intervals {
+		if semverRange([][]semverComparator{interval.Comparators}).contains(v) {
+			return interval, true
+		}
+	}
+	if a.Versions[raw] || a.Versions[v.String()] {
+		return advisoryInterval{}, true
+	}
+	return advisoryInterval{}, false
+}
+
+// toVulnerability converts a matched advisory into the scanner's finding record
+func (adv *advisory) toVulnerability(affected *affectedPackage, version string, interval advisoryInterval) Vulnerability {
+	vuln := Vulnerability{
+		Severity:        adv.Severity,
+		Package:         affected.Name,
+		Version:         version,
+		Description:     adv.Summary,
+		AdvisoryID:      adv.ID,
+		URL:             adv.URL,
+		VulnerableRange: affected.RangeText,
+		CVSS:            adv.CVSS,
+		FixAvailable:    interval.Fixed != "",
+	}
+	if interval.Fixed != "" {
+		vuln.FixVersion = fmt.Sprintf("%s@%s", affected.Name, interval.Fixed)
+	}
+
+	for _, id := range append([]string{adv.ID}, adv.Aliases...) {
+		switch {
+		case strings.HasPrefix(id, "GHSA-") && vuln.GHSA == "":
+			vuln.GHSA = id
+		case strings.HasPrefix(id, "CVE-"):
+			vuln.CVEs = append(vuln.CVEs, id)
+		}
+	}
+	return vuln
+}
+
+// firstLine returns the first non-empty line of a text
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
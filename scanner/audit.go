@@ -0,0 +1,433 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultAuditLevel is the lowest severity passed to the audit command unless configured
+const DefaultAuditLevel = SeverityModerate
+
+// auditReport is the union of the npm audit JSON report formats.
+// npm 6 emits version 1 (advisories keyed by ID), npm 7+ emits version 2
+// (vulnerabilities keyed by package name).
+type auditReport struct {
+	Advisories         map[string]auditAdvisoryV1 `json:"advisories"`
+	Vulnerabilities    map[string]auditPackageV2  `json:"vulnerabilities"`
+	Error              *auditError                `json:"error"`
+	Message            string                     `json:"message"`
+	AuditReportVersion int                        `json:"auditReportVersion"`
+}
+
+// auditError is the error object npm and pnpm print with --json when audit cannot run
+type auditError struct {
+	Code    string `json:"code"`
+	Summary string `json:"summary"`
+	Detail  string `json:"detail"`
+	// Message is used by pnpm instead of summary
+	Message string `json:"message"`
+}
+
+// auditCVSS is the CVSS block shared by both report formats
+type auditCVSS struct {
+	VectorString string  `json:"vectorString"`
+	Score        float64 `json:"score"`
+}
+
+// auditAdvisoryV1 is a single advisory in an npm 6 audit report
+type auditAdvisoryV1 struct {
+	CVSS               *auditCVSS       `json:"cvss"`
+	ModuleName         string           `json:"module_name"`
+	Severity           string           `json:"severity"`
+	Title              string           `json:"title"`
+	URL                string           `json:"url"`
+	VulnerableVersions string           `json:"vulnerable_versions"`
+	PatchedVersions    string           `json:"patched_versions"`
+	GithubAdvisoryID   string           `json:"github_advisory_id"`
+	CVEs               []string         `json:"cves"`
+	Findings           []auditFindingV1 `json:"findings"`
+	ID                 json.Number      `json:"id"`
+}
+
+// auditFindingV1 lists the installed versions and paths affected by a v1 advisory
+type auditFindingV1 struct {
+	Version string   `json:"version"`
+	Paths   []string `json:"paths"`
+}
+
+// auditPackageV2 is a vulnerable package entry in an npm 7+ audit report
+type auditPackageV2 struct {
+	FixAvailable auditFixAvailable `json:"fixAvailable"`
+	Name         string            `json:"name"`
+	Severity     string            `json:"severity"`
+	Range        string            `json:"range"`
+	Via          []auditVia        `json:"via"`
+	Effects      []string          `json:"effects"`
+	Nodes        []string          `json:"nodes"`
+	IsDirect     bool              `json:"isDirect"`
+}
+
+// auditVia is either an advisory object or the name of another vulnerable package
+type auditVia struct {
+	Advisory *auditAdvisoryV2
+	Package  string
+}
+
+// UnmarshalJSON decodes a via entry, which npm emits as a string or an object
+func (v *auditVia) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &v.Package)
+	}
+	v.Advisory = &auditAdvisoryV2{}
+	return json.Unmarshal(data, v.Advisory)
+}
+
+// auditAdvisoryV2 is an advisory object inside a v2 via list
+type auditAdvisoryV2 struct {
+	CVSS       *auditCVSS  `json:"cvss"`
+	Name       string      `json:"name"`
+	Dependency string      `json:"dependency"`
+	Title      string      `json:"title"`
+	URL        string      `json:"url"`
+	Severity   string      `json:"severity"`
+	Range      string      `json:"range"`
+	CWE        []string    `json:"cwe"`
+	CVEs       []string    `json:"cves"`
+	Source     json.Number `json:"source"`
+}
+
+// auditFixAvailable is either a boolean or an object describing the fix
+type auditFixAvailable struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Available     bool   `json:"-"`
+	IsSemVerMajor bool   `json:"isSemVerMajor"`
+}
+
+// UnmarshalJSON decodes fixAvailable, which npm emits as a bool or an object
+func (f *auditFixAvailable) UnmarshalJSON(data []byte) error {
+	if b, err := strconv.ParseBool(string(data)); err == nil {
+		f.Available = b
+		return nil
+	}
+
+	type plain auditFixAvailable
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*f = auditFixAvailable(p)
+	f.Available = true
+	return nil
+}
+
+// parseAuditJSON parses the output of `npm audit --json` into vulnerabilities.
+// npm 7+ reports only name the install paths of a vulnerable package, so the
+// installed versions and dependency chains are resolved from lock, if not nil.
+func parseAuditJSON(output string, lock *lockfile) ([]Vulnerability, error) {
+	data := extractJSONObject(output)
+	if data == "" {
+		return nil, fmt.Errorf("no JSON object found in npm audit output")
+	}
+
+	var report auditReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse npm audit JSON: %w", err)
+	}
+
+	if report.Error != nil {
+		message := firstNonEmpty(report.Error.Summary, report.Error.Message, report.Message, report.Error.Detail,
+			"unknown error")
+		if report.Error.Code != "" {
+			return nil, fmt.Errorf("npm audit error %s: %s", report.Error.Code, message)
+		}
+		return nil, fmt.Errorf("npm audit error: %s", message)
+	}
+
+	var vulnerabilities []Vulnerability
+	if report.Advisories != nil && report.AuditReportVersion < 2 {
+		vulnerabilities = convertAuditV1(report.Advisories)
+	} else {
+		vulnerabilities = convertAuditV2(report.Vulnerabilities, lock)
+	}
+
+	sortVulnerabilities(vulnerabilities)
+	return removeDuplicateVulnerabilities(vulnerabilities), nil
+}
+
+// extractJSONObject returns the outermost JSON object in output, skipping any
+// banner lines printed by wrappers such as Safe Chain
+func extractJSONObject(output string) string {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return ""
+	}
+	return output[start : end+1]
+}
+
+// convertAuditV1 converts npm 6 advisories to vulnerabilities, one per installed version
+func convertAuditV1(advisories map[string]auditAdvisoryV1) []Vulnerability {
+	vulnerabilities := []Vulnerability{}
+
+	for key := range advisories {
+		adv := advisories[key]
+		advisoryID := adv.ID.String()
+		if advisoryID == "" {
+			advisoryID = key
+		}
+
+		base := Vulnerability{
+			Severity:        normalizeSeverity(adv.Severity),
+			Package:         adv.ModuleName,
+			Description:     adv.Title,
+			AdvisoryID:      advisoryID,
+			GHSA:            firstNonEmpty(adv.GithubAdvisoryID, ghsaFromURL(adv.URL)),
+			CVEs:            adv.CVEs,
+			URL:             adv.URL,
+			VulnerableRange: adv.VulnerableVersions,
+			FixAvailable:    adv.PatchedVersions != "" && adv.PatchedVersions != "<0.0.0",
+		}
+		if adv.CVSS != nil {
+			base.CVSS = adv.CVSS.Score
+		}
+
+		if len(adv.Findings) == 0 {
+			vulnerabilities = append(vulnerabilities, base)
+			continue
+		}
+
+		for _, finding := range adv.Findings {
+			vuln := base
+			vuln.Version = finding.Version
+			if len(finding.Paths) > 0 {
+				vuln.Via = strings.Split(finding.Paths[0], ">")
+				vuln.IsDirect = len(vuln.Via) == 1
+			}
+			vulnerabilities = append(vulnerabilities, vuln)
+		}
+	}
+
+	return vulnerabilities
+}
+
+// convertAuditV2 converts npm 7+ vulnerable packages to vulnerabilities, one
+// per installed version. Each advisory object in `via` becomes one finding;
+// packages that are only vulnerable through another package get a single
+// transitive finding.
+func convertAuditV2(packages map[string]auditPackageV2, lock *lockfile) []Vulnerability {
+	vulnerabilities := []Vulnerability{}
+	locate := auditLocatorV2(packages, lock)
+
+	for name := range packages {
+		pkg := packages[name]
+		pkgName := firstNonEmpty(pkg.Name, name)
+		locations := locate(name)
+
+		var viaPackages []string
+		advisories := 0
+		for _, via := range pkg.Via {
+			if via.Advisory == nil {
+				viaPackages = append(viaPackages, via.Package)
+				continue
+			}
+
+			adv := via.Advisory
+			advisories++
+			base := Vulnerability{
+				Severity:        normalizeSeverity(firstNonEmpty(adv.Severity, pkg.Severity)),
+				Package:         pkgName,
+				Description:     adv.Title,
+				AdvisoryID:      adv.Source.String(),
+				GHSA:            ghsaFromURL(adv.URL),
+				CVEs:            adv.CVEs,
+				URL:             adv.URL,
+				VulnerableRange: firstNonEmpty(adv.Range, pkg.Range),
+			}
+			if adv.CVSS != nil {
+				base.CVSS = adv.CVSS.Score
+			}
+			applyFixAvailable(&base, pkg.FixAvailable)
+			for _, loc := range locations {
+				vuln := base
+				vuln.Version, vuln.Via, vuln.IsDirect = loc.version, loc.via, loc.isDirect
+				vulnerabilities = append(vulnerabilities, vuln)
+			}
+		}
+
+		if advisories == 0 && len(viaPackages) > 0 {
+			base := Vulnerability{
+				Severity:        normalizeSeverity(pkg.Severity),
+				Package:         pkgName,
+				Description:     fmt.Sprintf("Depends on vulnerable %s", strings.Join(viaPackages, ", ")),
+				VulnerableRange: pkg.Range,
+			}
+			applyFixAvailable(&base, pkg.FixAvailable)
+			for _, loc := range locations {
+				vuln := base
+				vuln.Version, vuln.IsDirect = loc.version, loc.isDirect
+				// 依存チェーンの先に脆弱なパッケージを続ける
+				vuln.Via = append(append([]string{}, loc.via...), viaPackages...)
+				vulnerabilities = append(vulnerabilities, vuln)
+			}
+		}
+	}
+
+	return vulnerabilities
+}
+
+// auditLocation is one installed copy of a vulnerable package in an npm 7+ report
+type auditLocation struct {
+	version  string
+	via      []string
+	isDirect bool
+}
+
+// auditLocatorV2 returns a function listing the installed copies of a
+// vulnerable package. The install paths in `nodes` are looked up in the
+// lockfile; without a lockfile entry the chain is rebuilt from `effects` and
+// the version stays unknown.
+func auditLocatorV2(packages map[string]auditPackageV2, lock *lockfile) func(name string) []auditLocation {
+	var index map[string]*lockPackage
+	var chains map[string][]string
+	var direct map[string]bool
+	if lock != nil {
+		index = lock.index()
+		direct = projectDirectDependencies(filepath.Dir(lock.Path), lock)
+		chains = lock.dependencyChains(direct)
+	}
+
+	return func(name string) []auditLocation {
+		pkg := packages[name]
+		nodes := append([]string{}, pkg.Nodes...)
+		sort.Strings(nodes)
+
+		var locations []auditLocation
+		for _, node := range nodes {
+			installed, ok := index[node]
+			if !ok {
+				continue
+			}
+			locations = append(locations, auditLocation{
+				version:  installed.Version,
+				via:      chains[node],
+				isDirect: lock.isDirect(installed, direct),
+			})
+		}
+		if len(locations) == 0 {
+			locations = append(locations, auditLocation{via: auditEffectsChain(packages, name), isDirect: pkg.IsDirect})
+		}
+		return locations
+	}
+}
+
+// auditEffectsChain rebuilds the dependency chain of a vulnerable package by
+// following `effects` (the packages depending on it) up to a direct dependency
+func auditEffectsChain(packages map[string]auditPackageV2, name string) []string {
+	chain := []string{firstNonEmpty(packages[name].Name, name)}
+	seen := map[string]bool{name: true}
+	for current := packages[name]; !current.IsDirect; {
+		effects := append([]string{}, current.Effects...)
+		sort.Strings(effects)
+
+		next := ""
+		for _, effect := range effects {
+			if _, ok := packages[effect]; ok && !seen[effect] {
+				next = effect
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		seen[next] = true
+		current = packages[next]
+		chain = append([]string{firstNonEmpty(current.Name, next)}, chain...)
+	}
+	return chain
+}
+
+// readAuditLockfile parses the npm lockfile of the audited directory, or
+// returns nil when there is none to resolve installed versions from
+func readAuditLockfile(projectDir string) *lockfile {
+	path, ok := findLockfile(projectDir, npmManager{})
+	if !ok {
+		return nil
+	}
+	lock, err := parseNpmLockfile(path)
+	if err != nil {
+		return nil
+	}
+	return lock
+}
+
+// applyFixAvailable copies fix availability information onto a vulnerability
+func applyFixAvailable(vuln *Vulnerability, fix auditFixAvailable) {
+	vuln.FixAvailable = fix.Available
+	if fix.Version != "" {
+		vuln.FixVersion = fmt.Sprintf("%s@%s", fix.Name, fix.Version)
+		vuln.FixIsSemVerMajor = fix.IsSemVerMajor
+	}
+}
+
+// ghsaFromURL extracts a GHSA identifier from a GitHub advisory URL
+func ghsaFromURL(url string) string {
+	idx := strings.Index(url, "GHSA-")
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimRight(url[idx:], "/")
+}
+
+// normalizeSeverity maps npm severity names onto the scanner's severity constants
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case SeverityCritical:
+		return SeverityCritical
+	case SeverityHigh:
+		return SeverityHigh
+	case SeverityLow, "info":
+		return SeverityLow
+	default:
+		return SeverityModerate
+	}
+}
+
+// SeverityRank orders severities from least to most severe
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityModerate:
+		return 2
+	case SeverityHigh:
+		return 3
+	case SeverityCritical:
+		return 4
+	case SeverityMalware:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// sortVulnerabilities orders vulnerabilities by severity (most severe first), then package
+func sortVulnerabilities(vulnerabilities []Vulnerability) {
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		a, b := vulnerabilities[i], vulnerabilities[j]
+		if ra, rb := SeverityRank(a.Severity), SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.AdvisoryID < b.AdvisoryID
+	})
+}
@@ -0,0 +1,232 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Baseline file defaults
+const (
+	// DefaultBaselineFile is written to the target directory by the baseline command
+	DefaultBaselineFile = ".npm-security-scanner-baseline.json"
+	baselineVersion     = 1
+)
+
+// Baseline is the set of findings accepted when adopting the scanner on an
+// existing code base
+type Baseline struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	ScanID    string            `json:"scan_id,omitempty"`
+	Findings  []BaselineFinding `json:"findings"`
+	// Path is the file the baseline was loaded from
+	Path string `json:"-"`
+	// index maps fingerprints to findings
+	index map[string]BaselineFinding
+}
+
+// BaselineFinding identifies a finding by a fingerprint of its project,
+// package, version and advisory; the other fields are for humans
+type BaselineFinding struct {
+	Fingerprint string `json:"fingerprint"`
+	Project     string `json:"project"`
+	Package     string `json:"package"`
+	Version     string `json:"version,omitempty"`
+	Advisory    string `json:"advisory"`
+	Severity    string `json:"severity"`
+	Description string `json:"description,omitempty"`
+}
+
+// FindingProject returns the project path relative to the target directory so
+// fingerprints do not depend on where the scanner was started
+func FindingProject(targetDir, project string) string {
+	if rel, err := filepath.Rel(firstNonEmpty(targetDir, "."), project); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filepath.Clean(project))
+}
+
+// FindingAdvisory returns the most stable identifier of what a finding reports
+func FindingAdvisory(vuln Vulnerability) string {
+	if id := firstNonEmpty(vuln.GHSA, vuln.AdvisoryID); id != "" {
+		return id
+	}
+	if len(vuln.CVEs) > 0 {
+		return strings.Join(vuln.CVEs, ",")
+	}
+	// npm auditの推移的な検出結果には識別子がないため、パッケージ名で識別する
+	if vuln.Type == "" {
+		return TransitiveAdvisoryPrefix + vuln.Package
+	}
+	// スクリプト解析やIOCの検出結果には識別子がないため、種類・場所・内容で識別する
+	return strings.Join([]string{vuln.Type, vuln.Location, vuln.Description}, ":")
+}
+
+// FindingFingerprint hashes the project, package, version and advisory of a finding
+func FindingFingerprint(project string, vuln Vulnerability) string {
+	sum := sha256.Sum256([]byte(strings.Join(
+		[]string{project, vuln.Package, vuln.Version, FindingAdvisory(vuln)}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// assignFingerprints sets the fingerprint of every finding of the project
+func assignFingerprints(targetDir string, result *ScanResult) {
+	project := FindingProject(targetDir, result.ProjectPath)
+	for _, findings := range [][]Vulnerability{result.Vulnerabilities, result.Suppressed} {
+		for i := range findings {
+			findings[i].Fingerprint = FindingFingerprint(project, findings[i])
+		}
+	}
+}
+
+// NewBaseline collects the unfixed findings of a report. Known malware is never
+// baselined so it always fails the scan.
+func NewBaseline(report *Report) *Baseline {
+	baseline := &Baseline{
+		Version:   baselineVersion,
+		CreatedAt: time.Now(),
+		ScanID:    report.ScanID,
+		Findings:  []BaselineFinding{},
+	}
+
+	seen := make(map[string]bool)
+	for i := range report.Results {
+		result := &report.Results[i]
+		project := FindingProject(report.TargetDir, result.ProjectPath)
+		for _, findings := range [][]Vulnerability{result.Vulnerabilities, result.Baselined} {
+			for _, vuln := range findings {
+				if vuln.Fixed || vuln.Type == FindingMalware {
+					continue
+				}
+				fingerprint := FindingFingerprint(project, vuln)
+				if seen[fingerprint] {
+					continue
+				}
+				seen[fingerprint] = true
+				baseline.Findings = append(baseline.Findings, BaselineFinding{
+					Fingerprint: fingerprint,
+					Project:     project,
+					Package:     vuln.Package,
+					Version:     vuln.Version,
+					Advisory:    FindingAdvisory(vuln),
+					Severity:    vuln.Severity,
+					Description: vuln.Description,
+				})
+			}
+		}
+	}
+
+	sort.Slice(baseline.Findings, func(i, j int) bool {
+		a, b := baseline.Findings[i], baseline.Findings[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return baseline
+}
+
+// LoadBaseline reads a baseline file written by the baseline command
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", baseline.Version, path)
+	}
+
+	baseline.Path = path
+	baseline.index = make(map[string]BaselineFinding, len(baseline.Findings))
+	for _, finding := range baseline.Findings {
+		baseline.index[finding.Fingerprint] = finding
+	}
+	return &baseline, nil
+}
+
+// WriteBaseline writes the baseline as indented JSON
+func WriteBaseline(path string, baseline *Baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), FilePermReadable); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+// apply moves the project's findings that are in the baseline out of the gated
+// results. Fingerprints must have been assigned.
+func (b *Baseline) apply(result *ScanResult) {
+	if b == nil {
+		return
+	}
+
+	active := result.Vulnerabilities[:0]
+	for _, vuln := range result.Vulnerabilities {
+		if _, known := b.index[vuln.Fingerprint]; known && vuln.Type != FindingMalware {
+			result.Baselined = append(result.Baselined, vuln)
+			continue
+		}
+		active = append(active, vuln)
+	}
+	result.Vulnerabilities = active
+}
+
+// resolved returns the baseline findings that disappeared from projects that
+// were scanned successfully
+func (b *Baseline) resolved(report *Report) []BaselineFinding {
+	if b == nil {
+		return nil
+	}
+
+	scanned := make(map[string]bool)
+	present := make(map[string]bool)
+	for i := range report.Results {
+		result := &report.Results[i]
+		if !result.SecurityScan.Success {
+			continue
+		}
+		scanned[FindingProject(report.TargetDir, result.ProjectPath)] = true
+		for _, findings := range [][]Vulnerability{result.Vulnerabilities, result.Baselined, result.Suppressed} {
+			for _, vuln := range findings {
+				if !vuln.Fixed {
+					present[vuln.Fingerprint] = true
+				}
+			}
+		}
+	}
+
+	var resolved []BaselineFinding
+	for _, finding := range b.Findings {
+		if scanned[finding.Project] && !present[finding.Fingerprint] {
+			resolved = append(resolved, finding)
+		}
+	}
+	return resolved
+}
+
+// CountBaselined counts findings hidden because they are in the baseline
+func (r *Report) CountBaselined() int {
+	count := 0
+	for i := range r.Results {
+		count += len(r.Results[i].Baselined)
+	}
+	return count
+}
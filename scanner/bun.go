@@ -0,0 +1,229 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bunInstallArgs maps install strategies to bun arguments
+var bunInstallArgs = map[string][]string{
+	InstallStrategyInstall:         {"install"},
+	InstallStrategyCI:              {"install", "--frozen-lockfile"},
+	InstallStrategyCIIgnoreScripts: {"install", "--frozen-lockfile", "--ignore-scripts"},
+	InstallStrategyLockfileOnly:    {"install", "--lockfile-only", "--ignore-scripts"},
+}
+
+// bunManager runs bun and reads the text bun.lock
+type bunManager struct{}
+
+func (bunManager) Name() string    { return PackageManagerBun }
+func (bunManager) Command() string { return "bun" }
+
+// Lockfiles prefers the text lockfile; bun.lockb is only detected, it cannot be parsed
+func (bunManager) Lockfiles() []string { return []string{"bun.lock", "bun.lockb"} }
+
+func (m bunManager) InstallArgs(strategy string) ([]string, error) {
+	return strategyArgs(m, bunInstallArgs, strategy)
+}
+
+func (bunManager) AuditArgs(level string) []string {
+	return []string{"audit", "--json", "--audit-level=" + level}
+}
+
+func (bunManager) ParseAudit(output, _ string) ([]Vulnerability, error) { return parseBunAudit(output) }
+
+func (bunManager) ParseLockfile(path string) (*lockfile, error) { return parseBunLockfile(path) }
+
+// bunLockfileRaw is the text lockfile written by bun 1.2+
+type bunLockfileRaw struct {
+	Workspaces map[string]struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+		Name                 string            `json:"name"`
+	} `json:"workspaces"`
+	// Packages maps an install path ("a" or "a/b" for b nested below a) to
+	// ["name@version", registry, {dependencies...}, integrity]
+	Packages        map[string][]json.RawMessage `json:"packages"`
+	LockfileVersion int                          `json:"lockfileVersion"`
+}
+
+// parseBunLockfile reads bun.lock. The binary bun.lockb cannot be parsed.
+func parseBunLockfile(path string) (*lockfile, error) {
+	if filepath.Base(path) == "bun.lockb" {
+		return nil, fmt.Errorf("%s is a binary lockfile; run `bun install --save-text-lockfile` to create bun.lock", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw bunLockfileRaw
+	if err := json.Unmarshal(stripTrailingCommas(data), &raw); err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+
+	lock := &lockfile{
+		Path:             path,
+		LockfileVersion:  raw.LockfileVersion,
+		RootDependencies: map[string]string{},
+	}
+	if root, ok := raw.Workspaces[""]; ok {
+		lock.Name = root.Name
+		for _, deps := range []map[string]string{root.Dependencies, root.DevDependencies, root.OptionalDependencies} {
+			for name, spec := range deps {
+				lock.RootDependencies[name] = spec
+			}
+		}
+	}
+
+	for key, entry := range raw.Packages {
+		if len(entry) == 0 {
+			continue
+		}
+		var resolution string
+		if err := json.Unmarshal(entry[0], &resolution); err != nil {
+			continue
+		}
+		name, version := splitPackageDescriptor(resolution)
+		// ワークスペース・ローカルパス・gitなどはレジストリのパッケージではない
+		if strings.Contains(version, ":") {
+			continue
+		}
+
+		pkg := lockPackage{
+			Name:    name,
+			Version: version,
+			Path:    bunInstallPath(key),
+		}
+		if len(entry) > 2 {
+			var info struct {
+				Dependencies         map[string]string `json:"dependencies"`
+				OptionalDependencies map[string]string `json:"optionalDependencies"`
+			}
+			if err := json.Unmarshal(entry[2], &info); err == nil {
+				pkg.Dependencies = make(map[string]string)
+				for _, m := range []map[string]string{info.Dependencies, info.OptionalDependencies} {
+					for dep, spec := range m {
+						pkg.Dependencies[dep] = spec
+					}
+				}
+			}
+		}
+		if len(entry) > 3 {
+			_ = json.Unmarshal(entry[3], &pkg.Integrity)
+		}
+		lock.Packages = append(lock.Packages, pkg)
+	}
+
+	sort.Slice(lock.Packages, func(i, j int) bool {
+		return lock.Packages[i].Path < lock.Packages[j].Path
+	})
+	return lock, nil
+}
+
+// bunInstallPath converts a bun.lock package key to an npm style install path
+// ("a/@scope/b" → "node_modules/a/node_modules/@scope/b")
+func bunInstallPath(key string) string {
+	var names []string
+	parts := strings.Split(key, "/")
+	for i := 0; i < len(parts); i++ {
+		if strings.HasPrefix(parts[i], "@") && i+1 < len(parts) {
+			names = append(names, parts[i]+"/"+parts[i+1])
+			i++
+			continue
+		}
+		names = append(names, parts[i])
+	}
+	return "node_modules/" + strings.Join(names, "/node_modules/")
+}
+
+// stripTrailingCommas removes the trailing commas bun.lock allows before } and ]
+func stripTrailingCommas(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			out = append(out, c)
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+		}
+		if c == ',' {
+			j := i + 1
+			for j < len(data) && strings.ContainsRune(" \t\r\n", rune(data[j])) {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// bunAuditAdvisory is an advisory in `bun audit --json` output, which is keyed by package name
+type bunAuditAdvisory struct {
+	CVSS               *auditCVSS  `json:"cvss"`
+	Title              string      `json:"title"`
+	URL                string      `json:"url"`
+	Severity           string      `json:"severity"`
+	VulnerableVersions string      `json:"vulnerable_versions"`
+	ID                 json.Number `json:"id"`
+}
+
+// parseBunAudit parses `bun audit --json`
+func parseBunAudit(output string) ([]Vulnerability, error) {
+	data := extractJSONObject(output)
+	if data == "" {
+		// 脆弱性がない場合、bunはJSONを出力しないことがある
+		if strings.Contains(output, "No vulnerabilities found") {
+			return []Vulnerability{}, nil
+		}
+		return nil, fmt.Errorf("no JSON object found in bun audit output")
+	}
+
+	var report map[string][]bunAuditAdvisory
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse bun audit JSON: %w", err)
+	}
+
+	vulnerabilities := []Vulnerability{}
+	for name, advisories := range report {
+		for _, adv := range advisories {
+			vuln := Vulnerability{
+				Severity:        normalizeSeverity(adv.Severity),
+				Package:         name,
+				Description:     adv.Title,
+				AdvisoryID:      adv.ID.String(),
+				GHSA:            ghsaFromURL(adv.URL),
+				URL:             adv.URL,
+				VulnerableRange: adv.VulnerableVersions,
+				Via:             []string{name},
+			}
+			if adv.CVSS != nil {
+				vuln.CVSS = adv.CVSS.Score
+			}
+			vulnerabilities = append(vulnerabilities, vuln)
+		}
+	}
+
+	sortVulnerabilities(vulnerabilities)
+	return removeDuplicateVulnerabilities(vulnerabilities), nil
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Default per-step timeouts
+const (
+	DefaultInstallTimeout = 10 * time.Minute
+	DefaultAuditTimeout   = 2 * time.Minute
+	DefaultFixTimeout     = 10 * time.Minute
+	ShellProbeTimeout     = 5 * time.Second
+	commandWaitDelay      = 5 * time.Second
+)
+
+// errInterrupted is returned when a step is aborted because the scan was cancelled
+var errInterrupted = errors.New("interrupted")
+
+// Command is an external command run by a scan step
+type Command struct {
+	// Dir is the working directory; empty uses the current directory
+	Dir  string
+	Name string
+	Args []string
+	// Combined captures stderr into the output instead of the ExitError
+	Combined bool
+	// Timeout kills the command when it expires; zero disables the limit
+	Timeout time.Duration
+}
+
+// String returns the command line
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// CommandRunner runs the external commands of a scan. Every install, audit,
+// fix and Safe Chain probe goes through it, so the pipeline can be tested with
+// recorded output instead of a real npm (see RecordingRunner and ReplayRunner).
+type CommandRunner interface {
+	// Run runs the command and returns its output; a non-zero exit is reported as *ExitError
+	Run(ctx context.Context, cmd Command) ([]byte, error)
+	// LookPath searches PATH for an executable
+	LookPath(name string) (string, error)
+}
+
+// ExitError reports a command that exited with a non-zero status
+type ExitError struct {
+	Code int
+	// Stderr is the captured standard error of commands run without Combined
+	Stderr []byte
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExecRunner runs commands with os/exec. Commands are killed together with
+// their process group when ctx is cancelled or the timeout expires, so hung
+// lifecycle scripts do not outlive the step.
+type ExecRunner struct{}
+
+// Run runs the command in cmd.Dir and returns its stdout, or stdout and stderr
+// when cmd.Combined is set
+func (ExecRunner) Run(ctx context.Context, cmd Command) ([]byte, error) {
+	cmdCtx := ctx
+	if cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeout(ctx, cmd.Timeout)
+		defer cancel()
+	}
+
+	c := exec.CommandContext(cmdCtx, cmd.Name, cmd.Args...)
+	c.Dir = cmd.Dir
+	c.WaitDelay = commandWaitDelay
+	configureProcessGroup(c)
+
+	var output []byte
+	var err error
+	if cmd.Combined {
+		output, err = c.CombinedOutput()
+	} else {
+		output, err = c.Output()
+	}
+
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return output, fmt.Errorf("%s: %w", cmd, errInterrupted)
+		case errors.Is(cmdCtx.Err(), context.DeadlineExceeded):
+			return output, fmt.Errorf("%s timed out after %v: %w", cmd, cmd.Timeout, err)
+		}
+		// シグナルで停止した場合以外は終了コードをExitErrorとして返す
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.Exited() {
+			return output, &ExitError{Code: exitErr.ExitCode(), Stderr: exitErr.Stderr}
+		}
+	}
+	return output, err
+}
+
+// LookPath searches PATH for an executable
+func (ExecRunner) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package scanner
+
+import "os/exec"
+
+// configureProcessGroup is a no-op on Windows; cancellation kills the npm
+// process itself via exec.CommandContext
+func configureProcessGroup(_ *exec.Cmd) {}
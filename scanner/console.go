@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// logSuccess writes a green progress message to a scan's log writer
+// (Options.Log or a project's buffer)
+func logSuccess(out io.Writer, format string, a ...interface{}) {
+	logColor(out, color.FgGreen, format, a...)
+}
+
+// logError writes a red progress message to a scan's log writer
+func logError(out io.Writer, format string, a ...interface{}) {
+	logColor(out, color.FgRed, format, a...)
+}
+
+// logWarning writes a yellow progress message to a scan's log writer
+func logWarning(out io.Writer, format string, a ...interface{}) {
+	logColor(out, color.FgYellow, format, a...)
+}
+
+// logInfo writes a cyan progress message to a scan's log writer
+func logInfo(out io.Writer, format string, a ...interface{}) {
+	logColor(out, color.FgCyan, format, a...)
+}
+
+// logColor writes a bold progress message in the foreground color
+func logColor(out io.Writer, fg color.Attribute, format string, a ...interface{}) {
+	_, _ = color.New(fg, color.Bold).Fprintf(out, format, a...)
+}
+
+// scanState is shared by the workers of a single ScanAll call, so concurrent
+// scans do not serialize on each other
+type scanState struct {
+	// consoleMu serializes writes and prompts on the progress log
+	consoleMu sync.Mutex
+	// eventMu serializes Options.Events calls
+	eventMu sync.Mutex
+}
+
+// lockConsole holds the scan's progress log; the returned function releases it.
+// Outside ScanAll there is a single writer and nothing to lock.
+func (o Options) lockConsole() func() {
+	if o.state == nil {
+		return func() {}
+	}
+	o.state.consoleMu.Lock()
+	return o.state.consoleMu.Unlock
+}
+
+// projectOutput buffers the progress output of a single project so that logs from
+// concurrent workers are written as contiguous blocks instead of interleaving
+type projectOutput struct {
+	buf  bytes.Buffer
+	log  io.Writer
+	opts Options
+}
+
+// Write appends to the project's buffer. Each buffer is owned by one worker.
+func (p *projectOutput) Write(data []byte) (int, error) {
+	return p.buf.Write(data)
+}
+
+// Flush writes the buffered output to the log as a single block
+func (p *projectOutput) Flush() {
+	defer p.opts.lockConsole()()
+	p.flushLocked()
+}
+
+// flushLocked writes the buffered output; the caller must hold the console lock
+func (p *projectOutput) flushLocked() {
+	_, _ = p.buf.WriteTo(p.log)
+}
+
+// newProjectOutput returns the writer a project's scan logs go to. A single
+// worker streams directly to the log; multiple workers buffer per project.
+func newProjectOutput(opts Options, jobs int) io.Writer {
+	if jobs <= 1 {
+		return opts.log()
+	}
+	return &projectOutput{log: opts.log(), opts: opts}
+}
+
+// flushProjectOutput writes any buffered output for a finished project
+func flushProjectOutput(out io.Writer) {
+	if po, ok := out.(*projectOutput); ok {
+		po.Flush()
+	}
+}
+
+// confirmForProject asks opts.Confirm a yes/no question during a project scan.
+// Buffered output is flushed first so the user sees the context of the
+// question, and the log is held until the answer is given.
+func confirmForProject(out io.Writer, opts Options, question string) bool {
+	defer opts.lockConsole()()
+
+	if po, ok := out.(*projectOutput); ok {
+		po.flushLocked()
+	}
+	return opts.confirm(question)
+}
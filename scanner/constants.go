@@ -0,0 +1,42 @@
+package scanner
+
+// Scanner identity, reported by the SARIF and CycloneDX outputs
+const (
+	ToolName = "npm-security-scanner"
+	Version  = "1.3.0"
+)
+
+// Status constants
+const (
+	StatusSuccess     = "success"
+	StatusFailed      = "failed"
+	StatusInProgress  = "in_progress"
+	StatusInterrupted = "interrupted"
+)
+
+// File permissions (secure defaults)
+const (
+	FilePermSecure   = 0o600 // Owner read/write only
+	DirPermSecure    = 0o755 // Owner full, group/other read/execute
+	FilePermReadable = 0o644 // Owner read/write, others read
+)
+
+// Severity levels
+const (
+	SeverityLow      = "low"
+	SeverityModerate = "moderate"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+	// SeverityMalware is used for known-malicious packages and outranks critical
+	SeverityMalware = "malware"
+)
+
+// Finding types (vulnerabilities from npm audit or advisories leave Type empty)
+const (
+	FindingMalware         = "malware"
+	FindingLifecycleScript = "lifecycle-script"
+)
+
+// TransitiveAdvisoryPrefix identifies audit findings that are only vulnerable
+// through a dependency and carry no advisory, GHSA or CVE of their own
+const TransitiveAdvisoryPrefix = "npm-transitive/"
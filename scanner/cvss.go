@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"math"
+	"strings"
+)
+
+// cvssV3Weights holds the metric weights from the CVSS v3.1 specification
+var cvssV3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvssV3BaseScore computes the base score of a CVSS v3.0/v3.1 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H". It returns false for other
+// vector versions or incomplete vectors.
+func cvssV3BaseScore(vector string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(vector), "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, false
+	}
+
+	metrics := make(map[string]string)
+	for _, part := range parts[1:] {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			metrics[key] = value
+		}
+	}
+
+	scopeChanged := metrics["S"] == "C"
+	if metrics["S"] != "U" && !scopeChanged {
+		return 0, false
+	}
+
+	values := make(map[string]float64)
+	for metric, weights := range cvssV3Weights {
+		w, ok := weights[metrics[metric]]
+		if !ok {
+			return 0, false
+		}
+		values[metric] = w
+	}
+
+	pr, ok := cvssV3PrivilegesRequired(metrics["PR"], scopeChanged)
+	if !ok {
+		return 0, false
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if scopeChanged {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+
+	exploitability := 8.22 * values["AV"] * values["AC"] * pr * values["UI"]
+	if scopeChanged {
+		return cvssRoundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return cvssRoundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// cvssV3PrivilegesRequired returns the PR weight, which depends on the scope
+func cvssV3PrivilegesRequired(value string, scopeChanged bool) (float64, bool) {
+	switch value {
+	case "N":
+		return 0.85, true
+	case "L":
+		if scopeChanged {
+			return 0.68, true
+		}
+		return 0.62, true
+	case "H":
+		if scopeChanged {
+			return 0.5, true
+		}
+		return 0.27, true
+	default:
+		return 0, false
+	}
+}
+
+// cvssRoundUp implements the Roundup function of CVSS v3.1 (smallest value with
+// one decimal place that is equal to or higher than its input)
+func cvssRoundUp(value float64) float64 {
+	scaled := int64(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
+
+// severityFromCVSS maps a CVSS base score onto the scanner's severity levels
+func severityFromCVSS(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityModerate
+	default:
+		return SeverityLow
+	}
+}
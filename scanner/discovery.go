@@ -0,0 +1,285 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Ignore files are read in every directory of the tree; .gitignore only when
+// DiscoveryOptions.Gitignore is set
+const (
+	gitignoreFile     = ".gitignore"
+	npmscanignoreFile = ".npmscanignore"
+)
+
+// DefaultExcludes are directories that hold VCS metadata, build output, vendored
+// code, fixtures or examples rather than projects to scan
+var DefaultExcludes = []string{".git", ".hg", ".svn", "dist", "vendor", "fixtures", "__fixtures__", "examples"}
+
+// DiscoveryOptions controls which directories are searched for projects
+type DiscoveryOptions struct {
+	// Include limits scanning to projects whose path relative to the target
+	// directory matches one of these globs; empty includes every project
+	Include []string
+	// Exclude skips directories matching one of these globs, with everything below them
+	Exclude []string
+	// MaxDepth limits how many directories below the target projects are searched
+	// for; 0 means unlimited
+	MaxDepth int
+	// NoDefaultExcludes also searches the directories in DefaultExcludes
+	NoDefaultExcludes bool
+	// Gitignore skips directories ignored by .gitignore files in the tree
+	Gitignore bool
+}
+
+// excludes returns the exclude globs including the defaults
+func (o DiscoveryOptions) excludes() []string {
+	if o.NoDefaultExcludes {
+		return o.Exclude
+	}
+	return append(append([]string{}, DefaultExcludes...), o.Exclude...)
+}
+
+// ignoreFiles returns the ignore files honoured in each directory
+func (o DiscoveryOptions) ignoreFiles() []string {
+	if o.Gitignore {
+		return []string{gitignoreFile, npmscanignoreFile}
+	}
+	return []string{npmscanignoreFile}
+}
+
+// matchPathPattern matches a slash-separated path relative to the target
+// directory. Like .gitignore, a pattern without a slash matches the last path
+// segment at any depth; otherwise it matches the whole path.
+func matchPathPattern(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		return matchGlob(pattern, path.Base(rel))
+	}
+	return matchGlob(pattern, rel)
+}
+
+// matchAnyPattern reports whether the path matches one of the patterns
+func matchAnyPattern(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchPathPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule is one pattern of an ignore file
+type ignoreRule struct {
+	// base is the directory of the ignore file relative to the target directory ("" for the target)
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreFile reads the .gitignore syntax subset that matters for
+// directories: comments, "!" negation, trailing "/" and anchoring with "/"
+func parseIgnoreFile(filename, base string) ([]ignoreRule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// 先頭または途中にスラッシュがあるパターンはファイルのあるディレクトリからの相対パスに一致する
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// matches reports whether the rule applies to the path relative to the target directory
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if r.anchored {
+		return matchGlob(r.pattern, rel)
+	}
+	return matchGlob(r.pattern, path.Base(rel))
+}
+
+// projectWalker applies the discovery options while walking the target directory
+type projectWalker struct {
+	opts  DiscoveryOptions
+	root  string
+	rules map[string][]ignoreRule
+}
+
+// newProjectWalker validates the globs and prepares a walk of rootDir
+func newProjectWalker(rootDir string, opts DiscoveryOptions) (*projectWalker, error) {
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return &projectWalker{opts: opts, root: rootDir, rules: make(map[string][]ignoreRule)}, nil
+}
+
+// relative returns the slash-separated path relative to the target directory
+func (w *projectWalker) relative(p string) string {
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// skipDir reports whether the directory and everything below it is skipped
+func (w *projectWalker) skipDir(dir string) bool {
+	rel := w.relative(dir)
+	if rel == "." {
+		return false
+	}
+	if filepath.Base(dir) == "node_modules" {
+		return true
+	}
+	if w.opts.MaxDepth > 0 && strings.Count(rel, "/")+1 > w.opts.MaxDepth {
+		return true
+	}
+	return matchAnyPattern(w.opts.excludes(), rel) || w.ignored(rel, true)
+}
+
+// ignored applies the ignore files of every ancestor directory in order, so
+// rules of deeper files and later lines win
+func (w *projectWalker) ignored(rel string, isDir bool) bool {
+	ignored := false
+	parts := strings.Split(rel, "/")
+	for i := 0; i < len(parts); i++ {
+		base := strings.Join(parts[:i], "/")
+		for _, rule := range w.rules[base] {
+			if rule.matches(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// loadIgnoreFiles reads the ignore files of a directory that is being entered
+func (w *projectWalker) loadIgnoreFiles(dir string) error {
+	base := w.relative(dir)
+	if base == "." {
+		base = ""
+	}
+	for _, name := range w.opts.ignoreFiles() {
+		rules, err := parseIgnoreFile(filepath.Join(dir, name), base)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read %s: %w", filepath.Join(dir, name), err)
+		}
+		w.rules[base] = append(w.rules[base], rules...)
+	}
+	return nil
+}
+
+// includeProject reports whether a project directory matches the include globs
+func (w *projectWalker) includeProject(dir string) bool {
+	if len(w.opts.Include) == 0 {
+		return true
+	}
+	return matchAnyPattern(w.opts.Include, w.relative(dir))
+}
+
+// Project is an npm project found by Discover
+type Project struct {
+	// Dir is the project directory
+	Dir string
+	// PackageManager is the package manager the project uses (npm, yarn, pnpm or bun)
+	PackageManager string
+	// Members are the workspace packages scanned as part of this workspace root
+	Members []WorkspaceMember
+}
+
+// Discover searches root recursively for npm projects, skipping directories
+// excluded by the discovery options or ignore files. Workspace members are not
+// returned on their own but as Members of their workspace root.
+func Discover(ctx context.Context, root string, opts DiscoveryOptions) ([]Project, error) {
+	walker, err := newProjectWalker(root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// node_modules・除外パターン・ignoreファイルに一致するディレクトリはスキップ
+		if info.IsDir() {
+			if walker.skipDir(path) {
+				return filepath.SkipDir
+			}
+			return walker.loadIgnoreFiles(path)
+		}
+
+		// node_modules内のpackage.jsonは除外
+		if info.Name() == "package.json" {
+			projectDir := filepath.Dir(path)
+			if !strings.Contains(projectDir, "node_modules") && walker.includeProject(projectDir) {
+				dirs = append(dirs, projectDir)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	// ワークスペースのメンバーは単独では扱わず、ルートからまとめてスキャンする
+	dirs, workspaces := groupWorkspaceProjects(dirs)
+	projects := make([]Project, 0, len(dirs))
+	for _, dir := range dirs {
+		projects = append(projects, Project{
+			Dir:            dir,
+			PackageManager: detectPackageManager(dir).Name(),
+			Members:        workspaces[dir],
+		})
+	}
+	return projects, nil
+}
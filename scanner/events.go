@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Steps of a project scan reported by StepStarted and StepFinished
+const (
+	StepRemove  = "remove"
+	StepInstall = "install"
+	StepAudit   = "audit"
+	StepFix     = "fix"
+)
+
+// Triage states of a FindingDetected event
+const (
+	FindingActive     = "active"
+	FindingSuppressed = "suppressed"
+	FindingBaselined  = "baselined"
+)
+
+// Event is a typed progress notification of a scan, delivered to Options.Events.
+// ScanAll emits ProjectDiscovered for every project first, then ProjectStarted,
+// StepStarted/StepFinished, FindingDetected and ProjectFinished per project,
+// and ScanCompleted last.
+type Event interface {
+	// EventType is the "type" of the event in the NDJSON stream
+	EventType() string
+}
+
+// ProjectDiscovered announces a project that ScanAll is about to scan
+type ProjectDiscovered struct {
+	Project        string `json:"project"`
+	PackageManager string `json:"package_manager"`
+	// Members are the workspace member names scanned as part of the project
+	Members []string `json:"members,omitempty"`
+	Index   int      `json:"index"`
+	Total   int      `json:"total"`
+}
+
+// ProjectStarted is emitted when a worker starts scanning a project
+type ProjectStarted struct {
+	Project string `json:"project"`
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+}
+
+// StepStarted is emitted when a project step (see the Step constants) starts
+type StepStarted struct {
+	Project string `json:"project"`
+	Step    string `json:"step"`
+}
+
+// StepFinished is emitted when a project step ends
+type StepFinished struct {
+	Project  string        `json:"project"`
+	Step     string        `json:"step"`
+	Success  bool          `json:"success"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// FindingDetected reports a finding of a finished project after suppressions
+// and the baseline were applied
+type FindingDetected struct {
+	Project string `json:"project"`
+	// State is one of the Finding constants
+	State   string        `json:"state"`
+	Finding Vulnerability `json:"finding"`
+}
+
+// ProjectFinished is emitted with the final status of a project
+type ProjectFinished struct {
+	Project  string        `json:"project"`
+	Status   string        `json:"status"`
+	Findings int           `json:"findings"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ScanCompleted is emitted once the report of ScanAll is final
+type ScanCompleted struct {
+	ScanID          string        `json:"scan_id"`
+	ProjectsScanned int           `json:"projects_scanned"`
+	SuccessCount    int           `json:"success_count"`
+	ErrorCount      int           `json:"error_count"`
+	Interrupted     bool          `json:"interrupted"`
+	Duration        time.Duration `json:"duration"`
+}
+
+func (ProjectDiscovered) EventType() string { return "project_discovered" }
+func (ProjectStarted) EventType() string    { return "project_started" }
+func (StepStarted) EventType() string       { return "step_started" }
+func (StepFinished) EventType() string      { return "step_finished" }
+func (FindingDetected) EventType() string   { return "finding_detected" }
+func (ProjectFinished) EventType() string   { return "project_finished" }
+func (ScanCompleted) EventType() string     { return "scan_completed" }
+
+// emit delivers an event to the Events handler, if any
+func (o Options) emit(event Event) {
+	if o.Events == nil {
+		return
+	}
+	// ScanAllのワーカー間でのみ直列化し、別のスキャンとはロックを共有しない
+	if o.state != nil {
+		o.state.eventMu.Lock()
+		defer o.state.eventMu.Unlock()
+	}
+	o.Events(event)
+}
+
+// startStep emits StepStarted and returns the function emitting StepFinished
+// with the step's action result
+func (o Options) startStep(project, step string) func(ActionResult) {
+	o.emit(StepStarted{Project: project, Step: step})
+	started := time.Now()
+	return func(action ActionResult) {
+		o.emit(StepFinished{
+			Project:  project,
+			Step:     step,
+			Success:  action.Success,
+			Skipped:  action.Skipped,
+			Error:    action.Error,
+			Duration: time.Since(started),
+		})
+	}
+}
+
+// emitFindings reports the active, suppressed and baselined findings of a project
+func (o Options) emitFindings(result *ScanResult) {
+	for _, group := range []struct {
+		state    string
+		findings []Vulnerability
+	}{
+		{FindingActive, result.Vulnerabilities},
+		{FindingSuppressed, result.Suppressed},
+		{FindingBaselined, result.Baselined},
+	} {
+		for _, finding := range group.findings {
+			o.emit(FindingDetected{Project: result.ProjectPath, State: group.state, Finding: finding})
+		}
+	}
+}
+
+// NDJSONEvents returns an Options.Events handler writing each event to w as a
+// single JSON line with its "type" and "time", followed by the event's fields:
+//
+//	{"type":"step_started","time":"2024-05-01T12:00:00Z","project":"apps/web","step":"install"}
+//
+// Write errors are ignored so a closed consumer does not abort the scan.
+func NDJSONEvents(w io.Writer) func(Event) {
+	return func(event Event) {
+		header, err := json.Marshal(struct {
+			Type string    `json:"type"`
+			Time time.Time `json:"time"`
+		}{event.EventType(), time.Now()})
+		if err != nil {
+			return
+		}
+		fields, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		// {"type":...,"time":...} と {"project":...} を1つのオブジェクトに連結する
+		line := append(header[:len(header)-1], ',')
+		line = append(line, fields[1:]...)
+		_, _ = w.Write(append(line, '\n'))
+	}
+}
@@ -0,0 +1,319 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FixChange describes a single package change planned by npm audit fix
+type FixChange struct {
+	Action  string `json:"action"`
+	Package string `json:"package"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+// manifestFiles are snapshotted before npm audit fix so they can be rolled back
+var manifestFiles = []string{"package.json", "package-lock.json", "npm-shrinkwrap.json"}
+
+// manifestSnapshot holds the original contents of the manifest files.
+// A nil entry means the file did not exist and must be removed on rollback.
+type manifestSnapshot struct {
+	files map[string][]byte
+	dir   string
+}
+
+// auditFixDryRunV6 is the npm 6 `npm audit fix --dry-run --json` output
+type auditFixDryRunV6 struct {
+	Added   []auditFixActionV6 `json:"added"`
+	Removed []auditFixActionV6 `json:"removed"`
+	Updated []auditFixActionV6 `json:"updated"`
+}
+
+// auditFixSummary is the JSON summary npm 7+ prints after the dry-run lines,
+// counting the changes instead of listing them
+type auditFixSummary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// auditFixActionV6 is a single planned action in npm 6 dry-run output
+type auditFixActionV6 struct {
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previousVersion"`
+}
+
+// runAuditFix applies npm audit fix after showing the planned changes and asking
+// for confirmation. package.json and the lockfile are restored if the fix or the
+// follow-up audit fails. Vulnerabilities are marked fixed by re-auditing.
+func runAuditFix(ctx context.Context, out io.Writer, projectDir string, result *ScanResult, opts Options) {
+	if len(result.Vulnerabilities) == 0 {
+		result.AuditFix.Skipped = true
+		return
+	}
+
+	plan, err := planAuditFix(ctx, opts.CommandRunner(), out, projectDir, opts.AuditTimeout)
+	if err != nil {
+		logWarning(out, "  ⚠️  Failed to plan npm audit fix in %s: %v\n", projectDir, err)
+		result.AuditFix.Error = err.Error()
+		return
+	}
+	result.FixPlan = plan
+
+	if len(plan) == 0 {
+		logInfo(out, "  💡 npm audit fix has no automatic changes for %s\n", projectDir)
+		result.AuditFix.Skipped = true
+		return
+	}
+
+	printFixPlan(out, projectDir, plan)
+	if !confirmForProject(out, opts, fmt.Sprintf("Apply %d change(s) to %s?", len(plan), projectDir)) {
+		logInfo(out, "  🚫 Fix skipped for %s\n", projectDir)
+		result.AuditFix.Skipped = true
+		return
+	}
+
+	applyAuditFix(ctx, out, projectDir, result, opts)
+}
+
+// applyAuditFix snapshots the manifests, runs npm audit fix, re-audits and rolls
+// back on failure
+func applyAuditFix(ctx context.Context, out io.Writer, projectDir string, result *ScanResult, opts Options) {
+	snapshot, err := snapshotManifests(projectDir)
+	if err != nil {
+		logError(out, "  ❌ Failed to snapshot manifests in %s: %v\n", projectDir, err)
+		result.AuditFix.Error = err.Error()
+		return
+	}
+
+	fixOutput, fixErr := executeNpmAuditFix(ctx, opts.CommandRunner(), out, projectDir, opts.FixTimeout,
+		strategyIgnoresScripts(opts.installStrategy()))
+	result.AuditFix.Output = fixOutput
+	if fixErr != nil {
+		rollbackAuditFix(ctx, out, projectDir, snapshot, result, fmt.Errorf("npm audit fix failed: %w", fixErr), opts)
+		return
+	}
+
+	auditOutput, auditErr := executeAudit(ctx, opts.CommandRunner(), out, projectDir, npmManager{}, opts.auditLevel(),
+		opts.AuditTimeout)
+	remaining, err := parseAuditJSON(auditOutput, readAuditLockfile(projectDir))
+	if err != nil {
+		rollbackAuditFix(ctx, out, projectDir, snapshot, result,
+			fmt.Errorf("re-audit after fix failed: %s", describeAuditError(err, auditErr)), opts)
+		return
+	}
+
+	result.AuditFix.Success = true
+	result.Vulnerabilities = markFixedVulnerabilities(result.Vulnerabilities, remaining)
+	logSuccess(out, "  ✅ npm audit fix applied in %s\n", projectDir)
+}
+
+// rollbackAuditFix restores the manifest snapshot and reinstalls dependencies.
+// When the scan was interrupted only the manifests are restored.
+func rollbackAuditFix(ctx context.Context, out io.Writer, projectDir string, snapshot *manifestSnapshot,
+	result *ScanResult, cause error, opts Options) {
+	logWarning(out, "  ↩️  %v, rolling back %s...\n", cause, projectDir)
+	result.AuditFix.Error = cause.Error()
+
+	if err := snapshot.restore(); err != nil {
+		logError(out, "  ❌ Rollback failed in %s: %v\n", projectDir, err)
+		result.AuditFix.Error += fmt.Sprintf("; rollback failed: %v", err)
+		return
+	}
+
+	if ctx.Err() != nil {
+		result.RolledBack = true
+		logWarning(out, "  ↩️  Restored manifests in %s; run npm install to resync node_modules\n", projectDir)
+		return
+	}
+
+	if err := runInstall(ctx, opts.CommandRunner(), out, projectDir, npmManager{}, opts.installStrategy(),
+		opts.InstallTimeout); err != nil {
+		logError(out, "  ❌ Reinstall after rollback failed in %s: %v\n", projectDir, err)
+		result.AuditFix.Error += fmt.Sprintf("; reinstall after rollback failed: %v", err)
+		return
+	}
+
+	result.RolledBack = true
+	logSuccess(out, "  ✅ Rolled back package.json and lockfile in %s\n", projectDir)
+}
+
+// planAuditFix runs npm audit fix --dry-run and returns the planned changes
+func planAuditFix(ctx context.Context, runner CommandRunner, out io.Writer, projectDir string,
+	timeout time.Duration) ([]FixChange, error) {
+	logInfo(out, "  🔧 Planning npm audit fix (dry run) in %s...\n", projectDir)
+
+	output, err := runner.Run(ctx, Command{Dir: projectDir, Name: "npm",
+		Args: []string{"audit", "fix", "--dry-run", "--json"}, Timeout: timeout})
+
+	plan, parseErr := parseFixPlan(string(output))
+	if parseErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%w (%v)", parseErr, err)
+		}
+		return nil, parseErr
+	}
+	return plan, nil
+}
+
+// parseFixPlan parses npm audit fix --dry-run --json output. npm 7+ prints one
+// "add/remove/change" line per package followed by a JSON summary with counts
+// (only the summary when nothing changes); npm 6 prints a single JSON object
+// with added/removed/updated arrays.
+func parseFixPlan(output string) ([]FixChange, error) {
+	plan := []FixChange{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if change, ok := parseFixPlanLine(strings.TrimSpace(line)); ok {
+			plan = append(plan, change)
+		}
+	}
+	if len(plan) > 0 {
+		return plan, nil
+	}
+
+	data := extractJSONObject(output)
+	if data == "" {
+		return nil, errors.New("no dry-run output from npm audit fix")
+	}
+
+	// 変更行のないnpm 7+のサマリーは、件数がすべて0の場合のみ変更なしを意味する
+	var summary auditFixSummary
+	if err := json.Unmarshal([]byte(data), &summary); err == nil {
+		if summary.Added == 0 && summary.Removed == 0 && summary.Changed == 0 {
+			return plan, nil
+		}
+		return nil, fmt.Errorf("npm audit fix plans %d added, %d removed and %d changed package(s) "+
+			"but the changes could not be read from the dry-run output", summary.Added, summary.Removed, summary.Changed)
+	}
+
+	var report auditFixDryRunV6
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse npm audit fix dry-run JSON: %w", err)
+	}
+
+	for _, a := range report.Added {
+		plan = append(plan, FixChange{Action: "add", Package: a.Name, To: a.Version})
+	}
+	for _, a := range report.Removed {
+		plan = append(plan, FixChange{Action: "remove", Package: a.Name, From: a.Version})
+	}
+	for _, a := range report.Updated {
+		plan = append(plan, FixChange{Action: "change", Package: a.Name, From: a.PreviousVersion, To: a.Version})
+	}
+	return plan, nil
+}
+
+// parseFixPlanLine parses "add <name> <version>", "remove <name> <version>" or
+// "change <name> <from> => <to>"
+func parseFixPlanLine(line string) (FixChange, bool) {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 3 && fields[0] == "add":
+		return FixChange{Action: "add", Package: fields[1], To: fields[2]}, true
+	case len(fields) == 3 && fields[0] == "remove":
+		return FixChange{Action: "remove", Package: fields[1], From: fields[2]}, true
+	case len(fields) == 5 && fields[0] == "change" && fields[3] == "=>":
+		return FixChange{Action: "change", Package: fields[1], From: fields[2], To: fields[4]}, true
+	default:
+		return FixChange{}, false
+	}
+}
+
+// printFixPlan prints the planned version changes for a project
+func printFixPlan(out io.Writer, projectDir string, plan []FixChange) {
+	logInfo(out, "  📋 Planned npm audit fix changes for %s:\n", projectDir)
+	for _, change := range plan {
+		fmt.Fprintf(out, "      %s\n", change.String())
+	}
+}
+
+// String renders a planned change for terminal output
+func (change FixChange) String() string {
+	switch change.Action {
+	case "add":
+		return fmt.Sprintf("+ %s@%s", change.Package, change.To)
+	case "remove":
+		return fmt.Sprintf("- %s@%s", change.Package, change.From)
+	default:
+		return fmt.Sprintf("~ %s %s => %s", change.Package, change.From, change.To)
+	}
+}
+
+// snapshotManifests records package.json and lockfile contents before a fix
+func snapshotManifests(projectDir string) (*manifestSnapshot, error) {
+	snapshot := &manifestSnapshot{dir: projectDir, files: make(map[string][]byte)}
+
+	for _, name := range manifestFiles {
+		data, err := os.ReadFile(filepath.Join(projectDir, name))
+		if os.IsNotExist(err) {
+			snapshot.files[name] = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		snapshot.files[name] = data
+	}
+
+	return snapshot, nil
+}
+
+// restore writes the snapshotted manifest contents back to disk
+func (s *manifestSnapshot) restore() error {
+	for name, data := range s.files {
+		path := filepath.Join(s.dir, name)
+		if data == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, data, FilePermReadable); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// markFixedVulnerabilities marks each original vulnerability as fixed when it no
+// longer appears in the post-fix audit. Findings that only appear after the fix
+// are appended so they are not lost.
+func markFixedVulnerabilities(before, after []Vulnerability) []Vulnerability {
+	remaining := make(map[string]bool)
+	for _, v := range after {
+		remaining[fixKey(v)] = true
+	}
+
+	seen := make(map[string]bool)
+	updated := make([]Vulnerability, 0, len(before))
+	for _, v := range before {
+		key := fixKey(v)
+		v.Fixed = !remaining[key]
+		seen[key] = true
+		updated = append(updated, v)
+	}
+
+	for _, v := range after {
+		if !seen[fixKey(v)] {
+			updated = append(updated, v)
+		}
+	}
+
+	return updated
+}
+
+// fixKey identifies a finding across audits; the installed version is left out
+// because a fix usually changes it
+func fixKey(v Vulnerability) string {
+	return strings.Join([]string{v.Package, v.AdvisoryID, v.GHSA, v.Description}, "|")
+}
@@ -0,0 +1,46 @@
+package scanner
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob matches a slash-separated path against a glob pattern. Each segment
+// is matched with path.Match ("*", "?", "[...]"); a "**" segment matches any
+// number of directories.
+func matchGlob(pattern, name string) bool {
+	pattern = strings.Trim(path.Clean("/"+pattern), "/")
+	name = strings.Trim(path.Clean("/"+name), "/")
+	return matchGlobSegments(splitGlobPath(pattern), splitGlobPath(name))
+}
+
+// splitGlobPath splits a cleaned path into segments; the root is no segments
+func splitGlobPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// matchGlobSegments matches path segments against pattern segments
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**"は0個以上のディレクトリに一致する
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
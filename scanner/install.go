@@ -0,0 +1,65 @@
+package scanner
+
+import "fmt"
+
+// Install strategies (--install-strategy)
+const (
+	// InstallStrategyInstall runs plain npm install, including every lifecycle script
+	InstallStrategyInstall = "install"
+	// InstallStrategyCI runs npm ci, installing exactly what the lockfile pins
+	InstallStrategyCI = "ci"
+	// InstallStrategyCIIgnoreScripts runs npm ci without lifecycle scripts (default)
+	InstallStrategyCIIgnoreScripts = "ci-ignore-scripts"
+	// InstallStrategyLockfileOnly only resolves package-lock.json; nothing is
+	// downloaded into node_modules and no scripts run
+	InstallStrategyLockfileOnly = "package-lock-only"
+)
+
+// DefaultInstallStrategy is the safest strategy that still restores node_modules
+const DefaultInstallStrategy = InstallStrategyCIIgnoreScripts
+
+// installStrategyArgs maps each strategy to its npm arguments. The other package
+// managers have equivalent tables next to their implementations.
+var installStrategyArgs = map[string][]string{
+	InstallStrategyInstall:         {"install"},
+	InstallStrategyCI:              {"ci"},
+	InstallStrategyCIIgnoreScripts: {"ci", "--ignore-scripts"},
+	InstallStrategyLockfileOnly:    {"install", "--package-lock-only", "--ignore-scripts"},
+}
+
+// validateInstallStrategy rejects unknown --install-strategy values
+func validateInstallStrategy(strategy string) error {
+	if _, ok := installStrategyArgs[strategy]; !ok {
+		return fmt.Errorf("invalid --install-strategy %q (expected %s, %s, %s or %s)", strategy,
+			InstallStrategyInstall, InstallStrategyCI, InstallStrategyCIIgnoreScripts, InstallStrategyLockfileOnly)
+	}
+	return nil
+}
+
+// strategyRequiresLockfile reports whether the strategy installs from a frozen lockfile (npm ci)
+func strategyRequiresLockfile(strategy string) bool {
+	return strategy == InstallStrategyCI || strategy == InstallStrategyCIIgnoreScripts
+}
+
+// strategyIgnoresScripts reports whether the strategy never runs lifecycle scripts
+func strategyIgnoresScripts(strategy string) bool {
+	return strategy == InstallStrategyCIIgnoreScripts || strategy == InstallStrategyLockfileOnly
+}
+
+// checkInstallStrategy verifies the project can be installed with the strategy
+// before anything is removed
+func checkInstallStrategy(projectDir string, pm packageManager, strategy string) error {
+	if _, err := pm.InstallArgs(strategy); err != nil {
+		return err
+	}
+	if strategyRequiresLockfile(strategy) && !hasLockfile(projectDir, pm) {
+		// 代替手段はロックファイルのみ解決できるパッケージマネージャーでだけ案内する
+		fallback := InstallStrategyLockfileOnly
+		if _, err := pm.InstallArgs(fallback); err != nil {
+			fallback = InstallStrategyInstall
+		}
+		return fmt.Errorf("%s requires %s; commit a lockfile or rerun with --install-strategy %s",
+			installCommand(pm, strategy), lockfileNames(pm), fallback)
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundledIOCs is the known-malicious package list shipped with the scanner
+//
+//go:embed iocs.json
+var bundledIOCs []byte
+
+// maxIOCFileSize bounds the files hashed for file IOCs; malware droppers are small
+const maxIOCFileSize = 16 << 20
+
+// iocFile is the on-disk format of an IOC list
+type iocFile struct {
+	Updated   string              `json:"updated"`
+	Packages  []iocPackageEntry   `json:"packages"`
+	Integrity []iocIntegrityEntry `json:"integrity"`
+	Files     []iocFileEntry      `json:"files"`
+}
+
+// iocPackageEntry marks versions of a package as malicious ("*" matches every version)
+type iocPackageEntry struct {
+	ID        string   `json:"id,omitempty"`
+	Name      string   `json:"name"`
+	Reason    string   `json:"reason"`
+	Reference string   `json:"reference,omitempty"`
+	Versions  []string `json:"versions"`
+}
+
+// iocIntegrityEntry marks a published tarball by its lockfile integrity hash
+type iocIntegrityEntry struct {
+	ID        string `json:"id,omitempty"`
+	Hash      string `json:"hash"`
+	Package   string `json:"package,omitempty"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// iocFileEntry marks a file by its SHA-256 digest
+type iocFileEntry struct {
+	ID        string `json:"id,omitempty"`
+	SHA256    string `json:"sha256"`
+	Name      string `json:"name,omitempty"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// IOCDatabase indexes IOC entries for matching
+type IOCDatabase struct {
+	packages  map[string]map[string]*iocPackageEntry
+	integrity map[string]*iocIntegrityEntry
+	files     map[string]*iocFileEntry
+	// Sources describes the lists that were loaded
+	Sources []string
+}
+
+// LoadIOCDatabase loads the bundled IOC list and merges the given IOC files into it
+func LoadIOCDatabase(paths []string) (*IOCDatabase, error) {
+	db := &IOCDatabase{
+		packages:  make(map[string]map[string]*iocPackageEntry),
+		integrity: make(map[string]*iocIntegrityEntry),
+		files:     make(map[string]*iocFileEntry),
+	}
+
+	if err := db.merge(bundledIOCs, "bundled"); err != nil {
+		return nil, fmt.Errorf("invalid bundled IOC list: %w", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IOC list: %w", err)
+		}
+		if err := db.merge(data, path); err != nil {
+			return nil, fmt.Errorf("invalid IOC list %s: %w", path, err)
+		}
+	}
+	return db, nil
+}
+
+// merge adds the entries of an IOC list; later lists override earlier entries
+func (db *IOCDatabase) merge(data []byte, source string) error {
+	var list iocFile
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	for i := range list.Packages {
+		entry := &list.Packages[i]
+		if entry.Name == "" {
+			return fmt.Errorf("package entry %d has no name", i)
+		}
+		if db.packages[entry.Name] == nil {
+			db.packages[entry.Name] = make(map[string]*iocPackageEntry)
+		}
+		for _, version := range entry.Versions {
+			db.packages[entry.Name][version] = entry
+		}
+	}
+	for i := range list.Integrity {
+		entry := &list.Integrity[i]
+		db.integrity[entry.Hash] = entry
+	}
+	for i := range list.Files {
+		entry := &list.Files[i]
+		db.files[strings.ToLower(entry.SHA256)] = entry
+	}
+
+	if list.Updated != "" {
+		source = fmt.Sprintf("%s (updated %s)", source, list.Updated)
+	}
+	db.Sources = append(db.Sources, source)
+	return nil
+}
+
+// Size returns the number of IOC entries
+func (db *IOCDatabase) Size() int {
+	n := len(db.integrity) + len(db.files)
+	for _, versions := range db.packages {
+		n += len(versions)
+	}
+	return n
+}
+
+// matchPackage returns the IOC entry for name@version, if any
+func (db *IOCDatabase) matchPackage(name, version string) *iocPackageEntry {
+	versions := db.packages[name]
+	if entry, ok := versions[version]; ok {
+		return entry
+	}
+	return versions["*"]
+}
+
+// matchIntegrity returns the IOC entry for any hash in an SRI integrity string
+func (db *IOCDatabase) matchIntegrity(integrity string) *iocIntegrityEntry {
+	for _, hash := range strings.Fields(integrity) {
+		if entry, ok := db.integrity[hash]; ok {
+			return entry
+		}
+	}
+	return nil
+}
+
+// scanForMalware checks the project's lockfiles and installed node_modules
+// against the IOC database and appends malware findings to the result
+func scanForMalware(out io.Writer, project string, pm packageManager, result *ScanResult, db *IOCDatabase) {
+	logInfo(out, "  🦠 Checking %s against %d known-malicious indicators...\n", project, db.Size())
+
+	var findings []Vulnerability
+	hiddenLockfile := filepath.Join(project, "node_modules", ".package-lock.json")
+	lockfiles := []string{hiddenLockfile}
+	if path, ok := findLockfile(project, pm); ok {
+		lockfiles = append([]string{path}, lockfiles...)
+	}
+	for _, path := range lockfiles {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		// npmがnode_modules内に書き出す隠しロックファイルは常にnpm形式
+		parse := pm.ParseLockfile
+		if path == hiddenLockfile {
+			parse = parseNpmLockfile
+		}
+		lock, err := parse(path)
+		if err != nil {
+			logWarning(out, "  ⚠️  Skipping IOC check of %s: %v\n", path, err)
+			continue
+		}
+		location, err := filepath.Rel(project, path)
+		if err != nil {
+			location = path
+		}
+		findings = append(findings, db.matchLockfile(lock, projectDirectDependencies(project, lock),
+			filepath.ToSlash(location))...)
+	}
+
+	installed, err := db.matchInstalled(project)
+	if err != nil {
+		logWarning(out, "  ⚠️  IOC check of node_modules in %s incomplete: %v\n", project, err)
+	}
+	findings = append(findings, installed...)
+
+	if len(findings) == 0 {
+		logSuccess(out, "  ✅ No known-malicious packages found in %s\n", project)
+		return
+	}
+
+	findings = removeDuplicateVulnerabilities(findings)
+	logError(out, "  🦠 Found %d known-malicious package(s) in %s\n", len(findings), project)
+	result.Vulnerabilities = append(result.Vulnerabilities, findings...)
+	sortVulnerabilities(result.Vulnerabilities)
+}
+
+// matchLockfile matches every lockfile entry by name@version and tarball integrity.
+// location is the lockfile path reported with each finding.
+func (db *IOCDatabase) matchLockfile(lock *lockfile, direct map[string]bool, location string) []Vulnerability {
+	chains := lock.dependencyChains(direct)
+
+	var findings []Vulnerability
+	for i := range lock.Packages {
+		pkg := &lock.Packages[i]
+		if entry := db.matchPackage(pkg.Name, pkg.Version); entry != nil {
+			finding := entry.finding(pkg.Version)
+			finding.Via = chains[pkg.Path]
+			finding.IsDirect = lock.isDirect(pkg, direct)
+			finding.Location = location
+			findings = append(findings, finding)
+		}
+		if entry := db.matchIntegrity(pkg.Integrity); entry != nil {
+			finding := entry.finding(pkg.Name, pkg.Version)
+			finding.Via = chains[pkg.Path]
+			finding.Location = location
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// matchInstalled matches installed packages by name@version and, when file
+// IOCs are configured, every file below node_modules by SHA-256
+func (db *IOCDatabase) matchInstalled(project string) ([]Vulnerability, error) {
+	var findings []Vulnerability
+	err := walkInstalledPackages(project, func(pkg *installedPackage) error {
+		name := firstNonEmpty(pkg.Manifest.Name, packageNameFromPath(pkg.Path))
+		if entry := db.matchPackage(name, pkg.Manifest.Version); entry != nil {
+			finding := entry.finding(pkg.Manifest.Version)
+			finding.Via = chainFromPath(pkg.Path)
+			finding.Location = pkg.Path + "/package.json"
+			findings = append(findings, finding)
+		}
+		return nil
+	})
+	if err != nil || len(db.files) == 0 {
+		return findings, err
+	}
+
+	fileFindings, err := db.matchFiles(project)
+	return append(findings, fileFindings...), err
+}
+
+// matchFiles hashes the regular files below node_modules and matches file IOCs
+func (db *IOCDatabase) matchFiles(project string) ([]Vulnerability, error) {
+	root := filepath.Join(project, "node_modules")
+	if _, err := os.Stat(root); err != nil {
+		return nil, nil
+	}
+
+	var findings []Vulnerability
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxIOCFileSize {
+			return err
+		}
+
+		digest, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		entry, ok := db.files[digest]
+		if !ok {
+			return nil
+		}
+
+		rel, err := filepath.Rel(project, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		finding := entry.finding(packageNameFromFilePath(rel))
+		finding.Location = rel
+		findings = append(findings, finding)
+		return nil
+	})
+	return findings, err
+}
+
+// sha256File returns the hex SHA-256 digest of a file
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packageNameFromFilePath returns the package owning a file below node_modules
+// ("node_modules/@scope/a/dist/x.js" → "@scope/a")
+func packageNameFromFilePath(path string) string {
+	idx := strings.LastIndex(path, "node_modules/")
+	if idx < 0 {
+		return path
+	}
+
+	parts := strings.Split(path[idx+len("node_modules/"):], "/")
+	if strings.HasPrefix(parts[0], "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// finding converts a package IOC match into a malware finding
+func (e *iocPackageEntry) finding(version string) Vulnerability {
+	return newMalwareFinding(firstNonEmpty(e.ID, "ioc:"+e.Name), e.Name, version, e.Reason, e.Reference)
+}
+
+// finding converts an integrity IOC match into a malware finding
+func (e *iocIntegrityEntry) finding(name, version string) Vulnerability {
+	return newMalwareFinding(firstNonEmpty(e.ID, "ioc:integrity"), name, version,
+		e.Reason+" (tarball integrity matches a known-malicious publish)", e.Reference)
+}
+
+// finding converts a file IOC match into a malware finding
+func (e *iocFileEntry) finding(pkg string) Vulnerability {
+	id := e.ID
+	if id == "" && len(e.SHA256) >= 12 {
+		id = "ioc:sha256:" + strings.ToLower(e.SHA256[:12])
+	}
+	return newMalwareFinding(id, pkg, "", e.Reason+" (file hash matches a known-malicious file)", e.Reference)
+}
+
+// newMalwareFinding builds a finding for a known-malicious package or file
+func newMalwareFinding(id, pkg, version, reason, reference string) Vulnerability {
+	vuln := Vulnerability{
+		Type:        FindingMalware,
+		Severity:    SeverityMalware,
+		Package:     pkg,
+		Version:     version,
+		Description: firstNonEmpty(reason, "Known-malicious package"),
+		AdvisoryID:  id,
+		URL:         reference,
+	}
+	if strings.HasPrefix(id, "GHSA-") {
+		vuln.GHSA = id
+	}
+	return vuln
+}
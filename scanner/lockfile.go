@@ -0,0 +1,404 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// lockPackage is a single installed package resolved by a lockfile
+type lockPackage struct {
+	Dependencies map[string]string
+	// DependencyPaths maps each dependency to the Path it resolves to, for
+	// lockfiles that record exact resolutions instead of an install tree (yarn, pnpm)
+	DependencyPaths map[string]string
+	Name            string
+	Version         string
+	// Path is the install location relative to the project, e.g.
+	// "node_modules/a/node_modules/b", or the lockfile key ("a@1.0.0") for
+	// lockfiles that do not record install locations
+	Path      string
+	Resolved  string
+	Integrity string
+	License   string
+	Dev       bool
+	Optional  bool
+}
+
+// lockfile is a parsed npm lockfile, normalized across lockfile versions
+type lockfile struct {
+	// RootDependencies maps the root project's declared dependencies to their ranges
+	RootDependencies map[string]string
+	// RootPaths maps the root project's dependencies to the Path they resolve to,
+	// for lockfiles that record exact resolutions
+	RootPaths map[string]string
+	// Importers maps each workspace member directory ("packages/a", relative to
+	// the lockfile) to its dependencies and the Path each resolves to
+	Importers       map[string]map[string]string
+	Name            string
+	Path            string
+	Packages        []lockPackage
+	LockfileVersion int
+}
+
+// npmLockfileRaw covers package-lock.json and npm-shrinkwrap.json versions 1, 2 and 3
+type npmLockfileRaw struct {
+	Packages        map[string]npmLockEntryV2 `json:"packages"`
+	Dependencies    map[string]npmLockEntryV1 `json:"dependencies"`
+	Name            string                    `json:"name"`
+	LockfileVersion int                       `json:"lockfileVersion"`
+}
+
+// npmLockEntryV2 is an entry of the "packages" map (lockfileVersion 2 and 3)
+type npmLockEntryV2 struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Resolved             string            `json:"resolved"`
+	Integrity            string            `json:"integrity"`
+	License              packageLicense    `json:"license"`
+	Dev                  bool              `json:"dev"`
+	Optional             bool              `json:"optional"`
+	DevOptional          bool              `json:"devOptional"`
+	Link                 bool              `json:"link"`
+}
+
+// npmLockEntryV1 is an entry of the nested "dependencies" tree (lockfileVersion 1)
+type npmLockEntryV1 struct {
+	Requires     map[string]string         `json:"requires"`
+	Dependencies map[string]npmLockEntryV1 `json:"dependencies"`
+	Version      string                    `json:"version"`
+	Resolved     string                    `json:"resolved"`
+	Integrity    string                    `json:"integrity"`
+	Dev          bool                      `json:"dev"`
+	Optional     bool                      `json:"optional"`
+	Bundled      bool                      `json:"bundled"`
+}
+
+// parseNpmLockfile reads and normalizes an npm lockfile
+func parseNpmLockfile(path string) (*lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw npmLockfileRaw
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+
+	lock := &lockfile{
+		Name:             raw.Name,
+		Path:             path,
+		LockfileVersion:  raw.LockfileVersion,
+		RootDependencies: map[string]string{},
+	}
+
+	switch {
+	case raw.Packages != nil:
+		// lockfileVersion 2は1との互換用にdependenciesも持つが、packagesを優先する
+		convertLockPackagesV2(lock, raw.Packages)
+	case raw.Dependencies != nil:
+		convertLockDependenciesV1(lock, "", raw.Dependencies)
+	case raw.LockfileVersion == 0:
+		return nil, fmt.Errorf("invalid lockfile %s: missing lockfileVersion", path)
+	}
+
+	sort.Slice(lock.Packages, func(i, j int) bool {
+		return lock.Packages[i].Path < lock.Packages[j].Path
+	})
+	return lock, nil
+}
+
+// convertLockPackagesV2 flattens the "packages" map. Workspace members and
+// links point to local sources and are not registry packages, so they are
+// skipped; the dependencies of workspace members are recorded as importers.
+func convertLockPackagesV2(lock *lockfile, packages map[string]npmLockEntryV2) {
+	members := make(map[string]npmLockEntryV2)
+	for path := range packages {
+		entry := packages[path]
+
+		if path == "" {
+			for _, deps := range []map[string]string{entry.Dependencies, entry.DevDependencies,
+				entry.OptionalDependencies} {
+				for name, spec := range deps {
+					lock.RootDependencies[name] = spec
+				}
+			}
+			continue
+		}
+		if entry.Link || !strings.Contains(path, "node_modules/") {
+			if !entry.Link && !strings.Contains(path, "node_modules/") {
+				members[path] = entry
+			}
+			continue
+		}
+
+		deps := make(map[string]string)
+		for _, m := range []map[string]string{entry.Dependencies, entry.OptionalDependencies} {
+			for name, spec := range m {
+				deps[name] = spec
+			}
+		}
+
+		lock.Packages = append(lock.Packages, lockPackage{
+			Name:         firstNonEmpty(entry.Name, packageNameFromPath(path)),
+			Version:      entry.Version,
+			Path:         path,
+			Resolved:     entry.Resolved,
+			Integrity:    entry.Integrity,
+			License:      string(entry.License),
+			Dev:          entry.Dev || entry.DevOptional,
+			Optional:     entry.Optional || entry.DevOptional,
+			Dependencies: deps,
+		})
+	}
+
+	if len(members) == 0 {
+		return
+	}
+	// ワークスペースメンバーの依存関係はメンバーのディレクトリからNodeの解決規則で辿る
+	index := lock.index()
+	lock.Importers = make(map[string]map[string]string, len(members))
+	for path, entry := range members {
+		resolved := make(map[string]string)
+		for _, deps := range []map[string]string{entry.Dependencies, entry.DevDependencies,
+			entry.OptionalDependencies} {
+			for name := range deps {
+				if pkg, ok := resolveLockDependency(index, path, name); ok {
+					resolved[name] = pkg.Path
+				}
+			}
+		}
+		lock.Importers[path] = resolved
+	}
+}
+
+// convertLockDependenciesV1 walks the nested lockfileVersion 1 tree
+func convertLockDependenciesV1(lock *lockfile, parent string, deps map[string]npmLockEntryV1) {
+	for name := range deps {
+		entry := deps[name]
+		path := parent + "node_modules/" + name
+
+		lock.Packages = append(lock.Packages, lockPackage{
+			Name:         name,
+			Version:      entry.Version,
+			Path:         path,
+			Resolved:     entry.Resolved,
+			Integrity:    entry.Integrity,
+			Dev:          entry.Dev,
+			Optional:     entry.Optional,
+			Dependencies: entry.Requires,
+		})
+
+		if len(entry.Dependencies) > 0 {
+			convertLockDependenciesV1(lock, path+"/", entry.Dependencies)
+		}
+	}
+}
+
+// projectDirectDependencies returns the names of the dependencies the project
+// declares, from the lockfile root entry and package.json (lockfileVersion 1
+// lockfiles do not record the root's dependencies)
+func projectDirectDependencies(projectDir string, lock *lockfile) map[string]bool {
+	direct := make(map[string]bool)
+	for name := range lock.RootDependencies {
+		direct[name] = true
+	}
+	if manifest, err := readPackageManifest(projectDir); err == nil {
+		for name := range manifest.directDependencies() {
+			direct[name] = true
+		}
+	}
+	return direct
+}
+
+// packageNameFromPath returns the package name of an install path
+// ("node_modules/a/node_modules/@scope/b" → "@scope/b")
+func packageNameFromPath(path string) string {
+	idx := strings.LastIndex(path, "node_modules/")
+	if idx < 0 {
+		return path
+	}
+	return path[idx+len("node_modules/"):]
+}
+
+// resolveLockDependency finds the install path a package at fromPath gets for the
+// dependency name, following Node's module resolution (nearest node_modules first)
+func resolveLockDependency(index map[string]*lockPackage, fromPath, name string) (*lockPackage, bool) {
+	dir := fromPath
+	for {
+		candidate := "node_modules/" + name
+		if dir != "" {
+			candidate = dir + "/" + candidate
+		}
+		if pkg, ok := index[candidate]; ok {
+			return pkg, true
+		}
+		if dir == "" {
+			return nil, false
+		}
+
+		// 1つ上のnode_modulesへ移動する
+		idx := strings.LastIndex(dir, "node_modules/")
+		if idx <= 0 {
+			dir = ""
+		} else {
+			dir = strings.TrimSuffix(dir[:idx], "/")
+		}
+	}
+}
+
+// rootPath returns the Path a direct dependency of the root project resolves to
+func (l *lockfile) rootPath(name string) string {
+	if l.RootPaths != nil {
+		return l.RootPaths[name]
+	}
+	return "node_modules/" + name
+}
+
+// isDirect reports whether the package is the copy of a direct dependency the root project uses
+func (l *lockfile) isDirect(pkg *lockPackage, direct map[string]bool) bool {
+	return direct[pkg.Name] && pkg.Path == l.rootPath(pkg.Name)
+}
+
+// index maps each Path to its package
+func (l *lockfile) index() map[string]*lockPackage {
+	index := make(map[string]*lockPackage, len(l.Packages))
+	for i := range l.Packages {
+		index[l.Packages[i].Path] = &l.Packages[i]
+	}
+	return index
+}
+
+// dependency resolves a dependency of pkg to the package it is installed as
+func (l *lockfile) dependency(index map[string]*lockPackage, pkg *lockPackage, name string) (*lockPackage, bool) {
+	if pkg.DependencyPaths != nil {
+		dep, ok := index[pkg.DependencyPaths[name]]
+		return dep, ok
+	}
+	return resolveLockDependency(index, pkg.Path, name)
+}
+
+// resolveBySpec finds the package a declared dependency range resolves to, for
+// lockfiles that do not record workspace members' resolutions (Yarn 1, bun).
+// The highest version satisfying the range wins.
+func (l *lockfile) resolveBySpec(name, spec string) (string, bool) {
+	rng, rangeErr := parseSemverRange(spec)
+
+	var best *lockPackage
+	var bestVersion semverVersion
+	for i := range l.Packages {
+		pkg := &l.Packages[i]
+		if pkg.Name != name {
+			continue
+		}
+		v, err := parseSemver(pkg.Version)
+		if err != nil || (rangeErr == nil && !rng.contains(v)) {
+			continue
+		}
+		if best == nil || compareSemver(v, bestVersion) > 0 {
+			best, bestVersion = pkg, v
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.Path, true
+}
+
+// reachable returns the Paths of every package reachable from the given Paths
+func (l *lockfile) reachable(index map[string]*lockPackage, start []string) map[string]bool {
+	seen := make(map[string]bool)
+	var queue []*lockPackage
+	for _, path := range start {
+		if pkg, ok := index[path]; ok && !seen[path] {
+			seen[path] = true
+			queue = append(queue, pkg)
+		}
+	}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for name := range pkg.Dependencies {
+			if dep, ok := l.dependency(index, pkg, name); ok && !seen[dep.Path] {
+				seen[dep.Path] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return seen
+}
+
+// dependencyChains returns, for each install path, the shortest chain of
+// package names from a direct dependency of the root project down to the package
+func (l *lockfile) dependencyChains(direct map[string]bool) map[string][]string {
+	index := l.index()
+
+	roots := make([]string, 0, len(direct))
+	for name := range direct {
+		roots = append(roots, name)
+	}
+	sort.Strings(roots)
+
+	chains := make(map[string][]string)
+	var queue []*lockPackage
+	for _, name := range roots {
+		if pkg, ok := index[l.rootPath(name)]; ok && chains[pkg.Path] == nil {
+			chains[pkg.Path] = []string{pkg.Name}
+			queue = append(queue, pkg)
+		}
+	}
+
+	// 幅優先探索で最短の依存チェーンを求める
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		names := make([]string, 0, len(pkg.Dependencies))
+		for name := range pkg.Dependencies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			dep, ok := l.dependency(index, pkg, name)
+			if !ok || chains[dep.Path] != nil {
+				continue
+			}
+			chain := append(append([]string{}, chains[pkg.Path]...), dep.Name)
+			chains[dep.Path] = chain
+			queue = append(queue, dep)
+		}
+	}
+
+	// ルートから辿れないパッケージはインストールパスからチェーンを推定する
+	for _, pkg := range l.Packages {
+		if chains[pkg.Path] == nil {
+			chains[pkg.Path] = chainFromPath(pkg.Path)
+		}
+		if len(chains[pkg.Path]) == 0 {
+			chains[pkg.Path] = []string{pkg.Name}
+		}
+	}
+	return chains
+}
+
+// chainFromPath splits an install path into its package names
+func chainFromPath(path string) []string {
+	var chain []string
+	parts := strings.Split(path, "node_modules/")
+	// 先頭要素はnode_modulesより前のディレクトリ（ワークスペースメンバーなど）
+	for _, part := range parts[1:] {
+		// pnpmの仮想ストア（node_modules/.pnpm/<key>/）はパッケージ名ではない
+		if part = strings.Trim(part, "/"); part != "" && !strings.HasPrefix(part, ".") {
+			chain = append(chain, part)
+		}
+	}
+	return chain
+}
@@ -0,0 +1,250 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspaceMember is a package of a monorepo that is installed and audited as
+// part of its workspace root instead of on its own
+type WorkspaceMember struct {
+	// Name is the package name, or the directory when package.json has none
+	Name string
+	// Dir is the member directory relative to the workspace root, slash-separated
+	Dir string
+}
+
+// workspacePatterns is the workspaces field of package.json: an array of globs
+// (npm, Yarn, bun) or {"packages": [...]} (Yarn 1)
+type workspacePatterns []string
+
+// UnmarshalJSON decodes either form of the workspaces field
+func (w *workspacePatterns) UnmarshalJSON(data []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*w = object.Packages
+	return nil
+}
+
+// workspaceGlobs returns the member globs declared by package.json and
+// pnpm-workspace.yaml in the project directory
+func workspaceGlobs(projectDir string) []string {
+	var patterns []string
+	if manifest, err := readPackageManifest(projectDir); err == nil {
+		patterns = append(patterns, manifest.Workspaces...)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(projectDir, "pnpm-workspace.yaml")); err == nil {
+		var config struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(data, &config); err == nil {
+			patterns = append(patterns, config.Packages...)
+		}
+	}
+	return patterns
+}
+
+// workspaceMembers returns the members of the workspace rooted at projectDir,
+// or nil when the project does not declare workspaces. Patterns starting with
+// "!" exclude directories; node_modules is never searched.
+func workspaceMembers(projectDir string) []WorkspaceMember {
+	patterns := workspaceGlobs(projectDir)
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	var members []WorkspaceMember
+	_ = filepath.WalkDir(projectDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != projectDir && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(projectDir, path)
+		if err != nil || rel == "." || !matchWorkspacePatterns(patterns, filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		manifest, err := readPackageManifest(path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		members = append(members, WorkspaceMember{Name: firstNonEmpty(manifest.Name, rel), Dir: rel})
+		return nil
+	})
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Dir < members[j].Dir })
+	return members
+}
+
+// matchWorkspacePatterns applies the workspace globs in order; a later "!pattern" excludes
+func matchWorkspacePatterns(patterns []string, rel string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated := strings.TrimPrefix(pattern, "!"); negated != pattern {
+			if matchGlob(negated, rel) {
+				matched = false
+			}
+		} else if matchGlob(pattern, rel) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// groupWorkspaceProjects removes workspace members from the discovered projects
+// so each workspace is scanned once from its root. It returns the remaining
+// projects and, per workspace root, its members.
+func groupWorkspaceProjects(projects []string) ([]string, map[string][]WorkspaceMember) {
+	grouped := make(map[string][]WorkspaceMember)
+	isMember := make(map[string]bool)
+
+	// 親ディレクトリが先に来るようにパスの短い順で処理する
+	ordered := append([]string{}, projects...)
+	sort.SliceStable(ordered, func(i, j int) bool { return len(ordered[i]) < len(ordered[j]) })
+	for _, project := range ordered {
+		if isMember[filepath.Clean(project)] {
+			continue
+		}
+		members := workspaceMembers(project)
+		if len(members) == 0 {
+			continue
+		}
+		grouped[project] = members
+		for _, member := range members {
+			isMember[filepath.Join(project, filepath.FromSlash(member.Dir))] = true
+		}
+	}
+
+	var remaining []string
+	for _, project := range projects {
+		if !isMember[filepath.Clean(project)] {
+			remaining = append(remaining, project)
+		}
+	}
+	return remaining, grouped
+}
+
+// attributeWorkspaceFindings records on each finding the workspace members whose
+// declared dependencies lead to the affected package, using the root lockfile
+func attributeWorkspaceFindings(out io.Writer, project string, pm packageManager, members []WorkspaceMember,
+	result *ScanResult) {
+	if len(result.Vulnerabilities) == 0 {
+		return
+	}
+
+	path, ok := findLockfile(project, pm)
+	if !ok {
+		logWarning(out, "  ⚠️  No lockfile in %s, findings are not attributed to workspace members\n", project)
+		return
+	}
+	lock, err := pm.ParseLockfile(path)
+	if err != nil {
+		logWarning(out, "  ⚠️  Cannot attribute findings to workspace members in %s: %v\n", project, err)
+		return
+	}
+
+	// ルートプロジェクトも宣言した依存関係の帰属先として扱う
+	rootName := "(root)"
+	if manifest, err := readPackageManifest(project); err == nil && manifest.Name != "" {
+		rootName = manifest.Name
+	}
+	rootDeps := make(map[string]string)
+	for name := range projectDirectDependencies(project, lock) {
+		rootDeps[name] = lock.rootPath(name)
+	}
+	owners := []struct {
+		name string
+		deps map[string]string
+	}{{rootName, rootDeps}}
+	for _, member := range members {
+		owners = append(owners, struct {
+			name string
+			deps map[string]string
+		}{member.Name, memberDependencyPaths(project, lock, member)})
+	}
+
+	index := lock.index()
+	byPackage := make(map[string]map[string]bool)
+	for _, owner := range owners {
+		start := make([]string, 0, len(owner.deps))
+		for _, target := range owner.deps {
+			start = append(start, target)
+		}
+		for target := range lock.reachable(index, start) {
+			pkg := index[target]
+			for _, key := range []string{pkg.Name + "@" + pkg.Version, pkg.Name} {
+				if byPackage[key] == nil {
+					byPackage[key] = make(map[string]bool)
+				}
+				byPackage[key][owner.name] = true
+			}
+		}
+	}
+
+	for i := range result.Vulnerabilities {
+		vuln := &result.Vulnerabilities[i]
+		owned, ok := byPackage[vuln.Package+"@"+vuln.Version]
+		if !ok || vuln.Version == "" {
+			owned = byPackage[vuln.Package]
+		}
+		vuln.Workspaces = nil
+		for name := range owned {
+			vuln.Workspaces = append(vuln.Workspaces, name)
+		}
+		sort.Strings(vuln.Workspaces)
+	}
+}
+
+// memberDependencyPaths resolves a workspace member's declared dependencies in the
+// root lockfile, from the lockfile's own record when it has one
+func memberDependencyPaths(project string, lock *lockfile, member WorkspaceMember) map[string]string {
+	if deps, ok := lock.Importers[member.Dir]; ok {
+		return deps
+	}
+
+	deps := make(map[string]string)
+	manifest, err := readPackageManifest(filepath.Join(project, filepath.FromSlash(member.Dir)))
+	if err != nil {
+		return deps
+	}
+	for _, declared := range []map[string]string{manifest.Dependencies, manifest.DevDependencies,
+		manifest.OptionalDependencies} {
+		for name, spec := range declared {
+			if target, ok := lock.resolveBySpec(name, spec); ok {
+				deps[name] = target
+			}
+		}
+	}
+	return deps
+}
+
+// workspaceMemberNames lists the member packages of a workspace for the report
+func workspaceMemberNames(members []WorkspaceMember) []string {
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		names = append(names, member.Name)
+	}
+	return names
+}
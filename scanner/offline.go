@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+)
+
+// scanProjectOffline matches the project's lockfile against the advisory database
+func scanProjectOffline(out io.Writer, project string, pm packageManager, result *ScanResult, opts Options) {
+	result.NodeModules.Skipped = true
+	result.NpmInstall.Skipped = true
+	result.AuditFix.Skipped = true
+
+	db := opts.AdvisoryDB
+	finishStep := opts.startStep(project, StepAudit)
+	defer func() { finishStep(result.SecurityScan) }()
+
+	lockPath, ok := findLockfile(project, pm)
+	if !ok {
+		err := fmt.Errorf("no lockfile found (offline scan of %s projects requires %s)", pm.Name(), lockfileNames(pm))
+		logError(out, "❌ Failed to run offline scan in %s: %v\n", project, err)
+		result.SecurityScan.Error = err.Error()
+		result.Status = StatusFailed
+		return
+	}
+
+	logInfo(out, "  📚 Matching %s against the advisory database...\n", lockPath)
+	lock, err := pm.ParseLockfile(lockPath)
+	if err != nil {
+		logError(out, "❌ Failed to run offline scan in %s: %v\n", project, err)
+		result.SecurityScan.Error = err.Error()
+		result.Status = StatusFailed
+		return
+	}
+
+	result.Vulnerabilities = matchLockfile(lock, db, projectDirectDependencies(project, lock))
+	result.SecurityScan.Success = true
+	result.SecurityScan.Output = fmt.Sprintf("matched %d package(s) from %s (lockfileVersion %d) against %d advisories in %s",
+		len(lock.Packages), lockPath, lock.LockfileVersion, db.Count, db.Source)
+	result.Status = StatusSuccess
+
+	displayScanResults(out, result, project)
+}
+
+// matchLockfile returns the vulnerabilities of every package in the lockfile
+func matchLockfile(lock *lockfile, db *AdvisoryDatabase, direct map[string]bool) []Vulnerability {
+	chains := lock.dependencyChains(direct)
+
+	vulnerabilities := []Vulnerability{}
+	for i := range lock.Packages {
+		pkg := &lock.Packages[i]
+		for _, vuln := range db.lookup(pkg.Name, pkg.Version) {
+			vuln.Via = chains[pkg.Path]
+			vuln.IsDirect = lock.isDirect(pkg, direct)
+			vulnerabilities = append(vulnerabilities, vuln)
+		}
+	}
+
+	vulnerabilities = removeDuplicateVulnerabilities(vulnerabilities)
+	sortVulnerabilities(vulnerabilities)
+	return vulnerabilities
+}
@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// packageManifest is the subset of package.json the scanner reads
+type packageManifest struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	Scripts              map[string]string `json:"scripts"`
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	// PackageManager is the Corepack field pinning the package manager, e.g. "pnpm@9.1.0"
+	PackageManager string `json:"packageManager"`
+	// Workspaces lists the globs of the monorepo's member packages
+	Workspaces workspacePatterns `json:"workspaces"`
+	// License is the SPDX expression of the package's license
+	License packageLicense `json:"license"`
+}
+
+// packageLicense is the license field of package.json and npm lockfiles. Old
+// packages use an object ({"type": "MIT"}) instead of an SPDX expression.
+type packageLicense string
+
+// UnmarshalJSON accepts an SPDX expression or a legacy license object
+func (l *packageLicense) UnmarshalJSON(data []byte) error {
+	var expression string
+	if err := json.Unmarshal(data, &expression); err == nil {
+		*l = packageLicense(expression)
+		return nil
+	}
+	var legacy struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		// 不正なlicenseフィールドでマニフェスト全体を失敗させない
+		*l = ""
+		return nil
+	}
+	*l = packageLicense(legacy.Type)
+	return nil
+}
+
+// readPackageManifest reads package.json from the project directory
+func readPackageManifest(projectDir string) (*packageManifest, error) {
+	data, err := os.ReadFile(filepath.Join(projectDir, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest packageManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid package.json: %w", err)
+	}
+	return &manifest, nil
+}
+
+// directDependencies returns the names of all dependencies declared by the manifest
+func (m *packageManifest) directDependencies() map[string]bool {
+	direct := make(map[string]bool)
+	for _, deps := range []map[string]string{m.Dependencies, m.DevDependencies, m.OptionalDependencies} {
+		for name := range deps {
+			direct[name] = true
+		}
+	}
+	return direct
+}
+
+// installedPackage is a package installed below a project's node_modules
+type installedPackage struct {
+	Manifest *packageManifest
+	// Dir is the package directory on disk
+	Dir string
+	// Path is the install location relative to the project, e.g. "node_modules/a/node_modules/b"
+	Path string
+}
+
+// walkInstalledPackages calls fn for every package installed in the project's
+// node_modules tree, including nested and scoped packages. Symlinked packages
+// (workspace links) are not followed and directories without a readable
+// package.json are skipped. pnpm's virtual store (node_modules/.pnpm) is walked
+// because the top-level packages of pnpm projects are symlinks into it.
+func walkInstalledPackages(projectDir string, fn func(pkg *installedPackage) error) error {
+	root := filepath.Join(projectDir, "node_modules")
+	if _, err := os.Stat(root); err != nil {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		name := d.Name()
+		parent := filepath.Base(filepath.Dir(path))
+		grandparent := filepath.Base(filepath.Dir(filepath.Dir(path)))
+
+		switch {
+		case name == "node_modules":
+			return nil
+		case name == ".pnpm" && parent == "node_modules", parent == ".pnpm":
+			// pnpmの仮想ストア: 実体はnode_modules/.pnpm/<key>/node_modules/<name>にある
+			return nil
+		case parent == "node_modules" && strings.HasPrefix(name, "@"):
+			// スコープディレクトリ
+			return nil
+		case parent == "node_modules" && !strings.HasPrefix(name, "."),
+			grandparent == "node_modules" && strings.HasPrefix(parent, "@"):
+			// パッケージディレクトリ: 以降はネストされたnode_modulesのみ辿る
+		default:
+			return filepath.SkipDir
+		}
+
+		manifest, err := readPackageManifest(path)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(projectDir, path)
+		if err != nil {
+			return err
+		}
+		return fn(&installedPackage{Manifest: manifest, Dir: path, Path: filepath.ToSlash(rel)})
+	})
+}
@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Package manager names recorded in scan results
+const (
+	PackageManagerNpm         = "npm"
+	PackageManagerYarnClassic = "yarn-classic"
+	PackageManagerYarnBerry   = "yarn-berry"
+	PackageManagerPnpm        = "pnpm"
+	PackageManagerBun         = "bun"
+)
+
+// packageManager abstracts the commands and lockfile format of a JavaScript
+// package manager so the scan pipeline does not depend on npm
+type packageManager interface {
+	// Name identifies the package manager in reports, e.g. "yarn-berry"
+	Name() string
+	// Command is the executable that runs installs and audits
+	Command() string
+	// Lockfiles lists the lockfile names the manager reads, in order of preference
+	Lockfiles() []string
+	// InstallArgs returns the install arguments for an install strategy
+	InstallArgs(strategy string) ([]string, error)
+	// AuditArgs returns the arguments of the JSON audit command reporting
+	// advisories at or above the audit level
+	AuditArgs(level string) []string
+	// ParseAudit converts the output of the audit command run in projectDir into vulnerabilities
+	ParseAudit(output, projectDir string) ([]Vulnerability, error)
+	// ParseLockfile parses the lockfile for offline matching and IOC checks
+	ParseLockfile(path string) (*lockfile, error)
+}
+
+// packageManagers indexes the supported package managers by name
+var packageManagers = map[string]packageManager{
+	PackageManagerNpm:         npmManager{},
+	PackageManagerYarnClassic: yarnClassicManager{},
+	PackageManagerYarnBerry:   yarnBerryManager{},
+	PackageManagerPnpm:        pnpmManager{},
+	PackageManagerBun:         bunManager{},
+}
+
+// packageManagerByName returns the package manager recorded in a scan result.
+// Results written before package manager detection existed are npm projects.
+func packageManagerByName(name string) packageManager {
+	if pm, ok := packageManagers[name]; ok {
+		return pm
+	}
+	return npmManager{}
+}
+
+// detectPackageManager determines the project's package manager from the
+// packageManager field of package.json, then from its lockfile, defaulting to npm
+func detectPackageManager(projectDir string) packageManager {
+	if manifest, err := readPackageManifest(projectDir); err == nil && manifest.PackageManager != "" {
+		if pm := packageManagerFromSpec(manifest.PackageManager); pm != nil {
+			return pm
+		}
+	}
+
+	// 複数のロックファイルがある場合はnpm以外を優先する（npmのロックファイルは移行途中の残骸であることが多い）
+	for _, pm := range []packageManager{pnpmManager{}, yarnClassicManager{}, bunManager{}, npmManager{}} {
+		path, ok := findLockfile(projectDir, pm)
+		if !ok {
+			continue
+		}
+		if pm.Name() == PackageManagerYarnClassic && isYarnBerryLockfile(path) {
+			return yarnBerryManager{}
+		}
+		return pm
+	}
+	return npmManager{}
+}
+
+// packageManagerFromSpec parses the Corepack packageManager field ("pnpm@9.1.0+sha512...")
+func packageManagerFromSpec(spec string) packageManager {
+	name, version, _ := strings.Cut(spec, "@")
+	switch name {
+	case "npm":
+		return npmManager{}
+	case "pnpm":
+		return pnpmManager{}
+	case "bun":
+		return bunManager{}
+	case "yarn":
+		// Yarn 2以降（Berry）はコマンド体系とロックファイル形式が異なる
+		if v, err := parseSemver(version); err == nil && v.major < 2 {
+			return yarnClassicManager{}
+		}
+		return yarnBerryManager{}
+	default:
+		return nil
+	}
+}
+
+// findLockfile returns the path of the project's lockfile for the package manager
+func findLockfile(projectDir string, pm packageManager) (string, bool) {
+	for _, name := range pm.Lockfiles() {
+		path := filepath.Join(projectDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// hasLockfile reports whether the project has a lockfile the package manager can audit in place
+func hasLockfile(projectDir string, pm packageManager) bool {
+	_, ok := findLockfile(projectDir, pm)
+	return ok
+}
+
+// lockfileNames describes the lockfiles of a package manager for messages
+func lockfileNames(pm packageManager) string {
+	return strings.Join(pm.Lockfiles(), " or ")
+}
+
+// installCommand returns the install command line of a strategy for display
+func installCommand(pm packageManager, strategy string) string {
+	args, err := pm.InstallArgs(strategy)
+	if err != nil {
+		return pm.Command() + " install"
+	}
+	return pm.Command() + " " + strings.Join(args, " ")
+}
+
+// strategyArgs looks up the install arguments of a strategy in a package manager's table
+func strategyArgs(pm packageManager, table map[string][]string, strategy string) ([]string, error) {
+	args, ok := table[strategy]
+	if !ok {
+		return nil, fmt.Errorf("%s does not support --install-strategy %s", pm.Name(), strategy)
+	}
+	return args, nil
+}
+
+// npmManager runs npm and reads package-lock.json / npm-shrinkwrap.json
+type npmManager struct{}
+
+func (npmManager) Name() string    { return PackageManagerNpm }
+func (npmManager) Command() string { return "npm" }
+
+// Lockfiles prefers npm-shrinkwrap.json like npm does
+func (npmManager) Lockfiles() []string { return []string{"npm-shrinkwrap.json", "package-lock.json"} }
+
+func (m npmManager) InstallArgs(strategy string) ([]string, error) {
+	return strategyArgs(m, installStrategyArgs, strategy)
+}
+
+func (npmManager) AuditArgs(level string) []string {
+	return []string{"audit", "--json", "--audit-level=" + level}
+}
+
+func (npmManager) ParseAudit(output, projectDir string) ([]Vulnerability, error) {
+	return parseAuditJSON(output, readAuditLockfile(projectDir))
+}
+
+func (npmManager) ParseLockfile(path string) (*lockfile, error) { return parseNpmLockfile(path) }
@@ -0,0 +1,267 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pnpmInstallArgs maps install strategies to pnpm arguments
+var pnpmInstallArgs = map[string][]string{
+	InstallStrategyInstall:         {"install"},
+	InstallStrategyCI:              {"install", "--frozen-lockfile"},
+	InstallStrategyCIIgnoreScripts: {"install", "--frozen-lockfile", "--ignore-scripts"},
+	InstallStrategyLockfileOnly:    {"install", "--lockfile-only", "--ignore-scripts"},
+}
+
+// pnpmManager runs pnpm and reads pnpm-lock.yaml
+type pnpmManager struct{}
+
+func (pnpmManager) Name() string        { return PackageManagerPnpm }
+func (pnpmManager) Command() string     { return "pnpm" }
+func (pnpmManager) Lockfiles() []string { return []string{"pnpm-lock.yaml"} }
+
+func (m pnpmManager) InstallArgs(strategy string) ([]string, error) {
+	return strategyArgs(m, pnpmInstallArgs, strategy)
+}
+
+func (pnpmManager) AuditArgs(level string) []string {
+	return []string{"audit", "--json", "--audit-level", level}
+}
+
+// ParseAudit reads `pnpm audit --json`, which uses the npm 6 report format
+func (pnpmManager) ParseAudit(output, _ string) ([]Vulnerability, error) {
+	return parseAuditJSON(output, nil)
+}
+
+func (pnpmManager) ParseLockfile(path string) (*lockfile, error) { return parsePnpmLockfile(path) }
+
+// pnpmLockfileRaw covers pnpm-lock.yaml versions 5.x, 6.0 and 9.0
+type pnpmLockfileRaw struct {
+	// Importers are the workspace projects; single-project lockfiles before 9.0
+	// record the root's dependencies at the top level instead
+	Importers            map[string]pnpmImporter   `yaml:"importers"`
+	Dependencies         map[string]pnpmDependency `yaml:"dependencies"`
+	DevDependencies      map[string]pnpmDependency `yaml:"devDependencies"`
+	OptionalDependencies map[string]pnpmDependency `yaml:"optionalDependencies"`
+	Packages             map[string]pnpmPackage    `yaml:"packages"`
+	Snapshots            map[string]pnpmSnapshot   `yaml:"snapshots"`
+	LockfileVersion      string                    `yaml:"lockfileVersion"`
+}
+
+// pnpmImporter is a workspace project in pnpm-lock.yaml
+type pnpmImporter struct {
+	Dependencies         map[string]pnpmDependency `yaml:"dependencies"`
+	DevDependencies      map[string]pnpmDependency `yaml:"devDependencies"`
+	OptionalDependencies map[string]pnpmDependency `yaml:"optionalDependencies"`
+}
+
+// pnpmDependency is a resolved dependency: a plain version (5.x) or
+// {specifier, version} (6.0+)
+type pnpmDependency struct {
+	Specifier string
+	Version   string
+}
+
+// UnmarshalYAML decodes either dependency form
+func (d *pnpmDependency) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		d.Version = node.Value
+		return nil
+	}
+	var raw struct {
+		Specifier string `yaml:"specifier"`
+		Version   string `yaml:"version"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	d.Specifier, d.Version = raw.Specifier, raw.Version
+	return nil
+}
+
+// pnpmPackage is an entry of the packages map
+type pnpmPackage struct {
+	Resolution struct {
+		Integrity string `yaml:"integrity"`
+		Tarball   string `yaml:"tarball"`
+	} `yaml:"resolution"`
+	Dependencies         map[string]string `yaml:"dependencies"`
+	OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+	Name                 string            `yaml:"name"`
+	Version              string            `yaml:"version"`
+	Dev                  bool              `yaml:"dev"`
+	Optional             bool              `yaml:"optional"`
+}
+
+// pnpmSnapshot is an entry of the snapshots map (9.0), holding the resolved
+// dependencies of a package instance
+type pnpmSnapshot struct {
+	Dependencies         map[string]string `yaml:"dependencies"`
+	OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+	Optional             bool              `yaml:"optional"`
+}
+
+// parsePnpmLockfile reads and normalizes pnpm-lock.yaml
+func parsePnpmLockfile(path string) (*lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw pnpmLockfileRaw
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+	if raw.LockfileVersion == "" {
+		return nil, fmt.Errorf("invalid lockfile %s: missing lockfileVersion", path)
+	}
+	major, _ := strconv.Atoi(strings.SplitN(strings.Trim(raw.LockfileVersion, "'\""), ".", 2)[0])
+
+	lock := &lockfile{
+		Path:             path,
+		LockfileVersion:  major,
+		RootDependencies: map[string]string{},
+		RootPaths:        map[string]string{},
+	}
+
+	root := pnpmImporter{
+		Dependencies:         raw.Dependencies,
+		DevDependencies:      raw.DevDependencies,
+		OptionalDependencies: raw.OptionalDependencies,
+	}
+	if importer, ok := raw.Importers["."]; ok {
+		root = importer
+	}
+	for _, deps := range []map[string]pnpmDependency{root.Dependencies, root.DevDependencies,
+		root.OptionalDependencies} {
+		for name, dep := range deps {
+			lock.RootDependencies[name] = firstNonEmpty(dep.Specifier, dep.Version)
+			if key, ok := pnpmDependencyKey(name, dep.Version, major); ok {
+				lock.RootPaths[name] = key
+			}
+		}
+	}
+
+	for dir, importer := range raw.Importers {
+		if dir == "." {
+			continue
+		}
+		if lock.Importers == nil {
+			lock.Importers = make(map[string]map[string]string)
+		}
+		resolved := make(map[string]string)
+		for _, deps := range []map[string]pnpmDependency{importer.Dependencies, importer.DevDependencies,
+			importer.OptionalDependencies} {
+			for name, dep := range deps {
+				if key, ok := pnpmDependencyKey(name, dep.Version, major); ok {
+					resolved[name] = key
+				}
+			}
+		}
+		lock.Importers[dir] = resolved
+	}
+
+	// 9.0ではパッケージのメタデータ（packages）と依存関係の解決結果（snapshots）が分かれている
+	instances := make(map[string]pnpmSnapshot, len(raw.Packages))
+	if raw.Snapshots != nil {
+		instances = raw.Snapshots
+	} else {
+		for key, pkg := range raw.Packages {
+			instances[key] = pnpmSnapshot{
+				Dependencies:         pkg.Dependencies,
+				OptionalDependencies: pkg.OptionalDependencies,
+				Optional:             pkg.Optional,
+			}
+		}
+	}
+
+	for rawKey, instance := range instances {
+		key := normalizePnpmKey(rawKey, major)
+		name, version := splitPackageDescriptor(stripPnpmPeerSuffix(key))
+		meta, ok := raw.Packages[rawKey]
+		if !ok {
+			meta = raw.Packages[stripPnpmPeerSuffix(rawKey)]
+		}
+
+		deps := make(map[string]string)
+		paths := make(map[string]string)
+		for _, m := range []map[string]string{instance.Dependencies, instance.OptionalDependencies} {
+			for dep, ref := range m {
+				deps[dep] = ref
+				if target, ok := pnpmDependencyKey(dep, ref, major); ok {
+					paths[dep] = target
+				}
+			}
+		}
+
+		lock.Packages = append(lock.Packages, lockPackage{
+			Name:            firstNonEmpty(meta.Name, name),
+			Version:         firstNonEmpty(meta.Version, version),
+			Path:            key,
+			Resolved:        meta.Resolution.Tarball,
+			Integrity:       meta.Resolution.Integrity,
+			Dev:             meta.Dev,
+			Optional:        instance.Optional || meta.Optional,
+			Dependencies:    deps,
+			DependencyPaths: paths,
+		})
+	}
+
+	sort.Slice(lock.Packages, func(i, j int) bool {
+		return lock.Packages[i].Path < lock.Packages[j].Path
+	})
+	return lock, nil
+}
+
+// normalizePnpmKey converts a package key of any lockfile version to the 9.0
+// form "name@version(peer@version)": 6.0 prefixes keys with "/" and 5.x writes
+// "/name/version_peer@version"
+func normalizePnpmKey(key string, major int) string {
+	key = strings.TrimPrefix(key, "/")
+	if major >= 6 {
+		return key
+	}
+
+	idx := strings.LastIndex(key, "/")
+	if idx < 0 {
+		return key
+	}
+	name, version := key[:idx], key[idx+1:]
+	if peers := strings.Index(version, "_"); peers >= 0 {
+		version = version[:peers] + "(" + version[peers+1:] + ")"
+	}
+	return name + "@" + version
+}
+
+// stripPnpmPeerSuffix removes the peer dependency suffix from a package key
+func stripPnpmPeerSuffix(key string) string {
+	if idx := strings.Index(key, "("); idx > 0 {
+		return key[:idx]
+	}
+	return key
+}
+
+// pnpmDependencyKey returns the package key a dependency reference points to.
+// References are a version ("1.0.0(react@18.2.0)"), an alias ("npm:b@1.0.0" or
+// "b@1.0.0") or a local link ("link:../x"), which has no package entry.
+func pnpmDependencyKey(name, ref string, major int) (string, bool) {
+	switch {
+	case ref == "" || strings.HasPrefix(ref, "link:") || strings.HasPrefix(ref, "file:"):
+		return "", false
+	case strings.HasPrefix(ref, "/"):
+		return normalizePnpmKey(ref, major), true
+	case major < 6:
+		return normalizePnpmKey(name+"/"+ref, major), true
+	case strings.HasPrefix(ref, "npm:"):
+		return strings.TrimPrefix(ref, "npm:"), true
+	case strings.Contains(stripPnpmPeerSuffix(ref), "@"):
+		return ref, true
+	default:
+		return name + "@" + ref, true
+	}
+}
@@ -0,0 +1,244 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// recordedCommand is one line of a command fixture file. Run and LookPath
+// calls are recorded in the order they were made.
+type recordedCommand struct {
+	// Dir is the working directory relative to the recording root ("." for the
+	// root itself); directories outside the root are kept absolute
+	Dir  string   `json:"dir,omitempty"`
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+	// LookPath marks a PATH lookup of Name; Path is the executable found
+	LookPath bool   `json:"lookpath,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Output   string `json:"output,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	// Error is set for failures other than a non-zero exit, such as timeouts
+	Error string `json:"error,omitempty"`
+}
+
+// RecordingRunner runs commands with another runner and appends each call and
+// its output to a JSONL fixture file that ReplayRunner can replay
+type RecordingRunner struct {
+	runner CommandRunner
+	root   string
+	path   string
+	mu     sync.Mutex
+}
+
+// NewRecordingRunner records the commands run by runner into the fixture file
+// at path, replacing any previous recording. Working directories are stored
+// relative to root so the fixture can be replayed from another checkout.
+func NewRecordingRunner(runner CommandRunner, root, path string) (*RecordingRunner, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve recording root: %w", err)
+	}
+	if err := os.WriteFile(path, nil, FilePermReadable); err != nil {
+		return nil, fmt.Errorf("failed to create command fixture: %w", err)
+	}
+	return &RecordingRunner{runner: runner, root: absRoot, path: path}, nil
+}
+
+// Run runs the command and records its output. Interrupted commands are not
+// recorded because their output is incomplete.
+func (r *RecordingRunner) Run(ctx context.Context, cmd Command) ([]byte, error) {
+	output, err := r.runner.Run(ctx, cmd)
+	if errors.Is(err, errInterrupted) {
+		return output, err
+	}
+
+	record := recordedCommand{
+		Dir:    relativeCommandDir(r.root, cmd.Dir),
+		Name:   cmd.Name,
+		Args:   cmd.Args,
+		Output: string(output),
+	}
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		record.ExitCode = exitErr.Code
+		record.Stderr = string(exitErr.Stderr)
+	case err != nil:
+		record.Error = err.Error()
+	}
+	if recordErr := r.record(record); recordErr != nil {
+		return output, recordErr
+	}
+	return output, err
+}
+
+// LookPath searches PATH with the wrapped runner and records the result
+func (r *RecordingRunner) LookPath(name string) (string, error) {
+	path, err := r.runner.LookPath(name)
+	record := recordedCommand{Name: name, LookPath: true, Path: path}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if recordErr := r.record(record); recordErr != nil {
+		return path, recordErr
+	}
+	return path, err
+}
+
+// record appends a call to the fixture file
+func (r *RecordingRunner) record(record recordedCommand) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recorded command: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, FilePermReadable)
+	if err != nil {
+		return fmt.Errorf("failed to open command fixture: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to record command: %w", err)
+	}
+	return nil
+}
+
+// ReplayRunner answers commands from a fixture file written by RecordingRunner
+// without running anything. Each call returns the first recording of the same
+// command in the same directory that was not replayed yet, or the last one once
+// all have been replayed.
+type ReplayRunner struct {
+	root     string
+	commands []recordedCommand
+	replayed []bool
+	mu       sync.Mutex
+}
+
+// LoadReplayRunner reads a command fixture file. Working directories of the
+// replayed commands are resolved relative to root. Blank lines and lines
+// starting with # are skipped, so hand-written fixtures can say so.
+func LoadReplayRunner(root, path string) (*ReplayRunner, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve replay root: %w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command fixture: %w", err)
+	}
+	defer file.Close()
+
+	r := &ReplayRunner{root: absRoot}
+	lines := bufio.NewScanner(file)
+	// npm auditの出力は1行に収まらないほど大きくなることがある
+	lines.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; lines.Scan(); line++ {
+		if text := strings.TrimSpace(lines.Text()); text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var record recordedCommand
+		if err := json.Unmarshal(lines.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid command fixture %s line %d: %w", path, line, err)
+		}
+		r.commands = append(r.commands, record)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read command fixture: %w", err)
+	}
+	r.replayed = make([]bool, len(r.commands))
+	return r, nil
+}
+
+// Run returns the recorded output of the command
+func (r *ReplayRunner) Run(ctx context.Context, cmd Command) ([]byte, error) {
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, errInterrupted)
+	}
+
+	dir := relativeCommandDir(r.root, cmd.Dir)
+	record, ok := r.next(func(c recordedCommand) bool {
+		return !c.LookPath && c.Name == cmd.Name && c.Dir == dir && equalArgs(c.Args, cmd.Args)
+	})
+	if !ok {
+		return nil, fmt.Errorf("no recorded output for %q in %s", cmd.String(), firstNonEmpty(dir, "."))
+	}
+
+	output := []byte(record.Output)
+	switch {
+	case record.Error != "":
+		return output, errors.New(record.Error)
+	case record.ExitCode != 0:
+		return output, &ExitError{Code: record.ExitCode, Stderr: []byte(record.Stderr)}
+	}
+	return output, nil
+}
+
+// LookPath returns the recorded PATH lookup; executables that were never
+// looked up are reported as not found
+func (r *ReplayRunner) LookPath(name string) (string, error) {
+	record, ok := r.next(func(c recordedCommand) bool {
+		return c.LookPath && c.Name == name
+	})
+	if !ok || record.Error != "" {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return record.Path, nil
+}
+
+// next marks and returns the first matching recording that was not replayed
+// yet, falling back to the last matching one
+func (r *ReplayRunner) next(match func(recordedCommand) bool) (recordedCommand, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, c := range r.commands {
+		if !match(c) {
+			continue
+		}
+		if !r.replayed[i] {
+			r.replayed[i] = true
+			return c, true
+		}
+		last = i
+	}
+	if last < 0 {
+		return recordedCommand{}, false
+	}
+	return r.commands[last], true
+}
+
+// relativeCommandDir returns dir relative to root in slash form, keeping
+// directories outside root absolute
+func relativeCommandDir(root, dir string) string {
+	if dir == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(abs)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// equalArgs compares argument lists, treating nil and empty as equal
+func equalArgs(a, b []string) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,258 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanResult represents the result of scanning a single project
+type ScanResult struct {
+	StartTime       time.Time       `json:"start_time"`
+	EndTime         time.Time       `json:"end_time"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	Suppressed      []Vulnerability `json:"suppressed,omitempty"`
+	Baselined       []Vulnerability `json:"baselined,omitempty"`
+	ProjectPath     string          `json:"project_path"`
+	Status          string          `json:"status"`
+	NodeModules     ActionResult    `json:"node_modules"`
+	NpmInstall      ActionResult    `json:"npm_install"`
+	SecurityScan    ActionResult    `json:"security_scan"`
+	AuditFix        ActionResult    `json:"audit_fix"`
+	FixPlan         []FixChange     `json:"fix_plan,omitempty"`
+	InstallStrategy string          `json:"install_strategy,omitempty"`
+	PackageManager  string          `json:"package_manager,omitempty"`
+	// WorkspaceMembers are the monorepo packages scanned as part of this workspace root
+	WorkspaceMembers []string      `json:"workspace_members,omitempty"`
+	Duration         time.Duration `json:"duration"`
+	RolledBack       bool          `json:"rolled_back,omitempty"`
+}
+
+// InstallCommand returns the command the project's dependencies were installed
+// with, e.g. "npm ci --ignore-scripts", or "" when no install strategy was used
+func (r *ScanResult) InstallCommand() string {
+	if r.InstallStrategy == "" {
+		return ""
+	}
+	return installCommand(packageManagerByName(r.PackageManager), r.InstallStrategy)
+}
+
+// ActionResult represents the result of a specific action
+type ActionResult struct {
+	Error   string `json:"error"`
+	Output  string `json:"output"`
+	Success bool   `json:"success"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+// Vulnerability represents a security vulnerability found during scan
+type Vulnerability struct {
+	Type             string   `json:"type,omitempty"`
+	Severity         string   `json:"severity"`
+	Package          string   `json:"package"`
+	Version          string   `json:"version,omitempty"`
+	Description      string   `json:"description"`
+	AdvisoryID       string   `json:"advisory_id,omitempty"`
+	GHSA             string   `json:"ghsa,omitempty"`
+	URL              string   `json:"url,omitempty"`
+	VulnerableRange  string   `json:"vulnerable_range,omitempty"`
+	FixVersion       string   `json:"fix_version,omitempty"`
+	Location         string   `json:"location,omitempty"`
+	Fingerprint      string   `json:"fingerprint,omitempty"`
+	Script           string   `json:"script,omitempty"`
+	Evidence         string   `json:"evidence,omitempty"`
+	CVEs             []string `json:"cves,omitempty"`
+	Via              []string `json:"via,omitempty"`
+	Workspaces       []string `json:"workspaces,omitempty"`
+	CVSS             float64  `json:"cvss,omitempty"`
+	IsDirect         bool     `json:"is_direct"`
+	FixAvailable     bool     `json:"fix_available"`
+	FixIsSemVerMajor bool     `json:"fix_is_semver_major,omitempty"`
+	Fixed            bool     `json:"fixed"`
+	// Suppression is the accepted-risk entry covering the finding, or the expired entry that no longer does
+	Suppression *Suppression `json:"suppression,omitempty"`
+}
+
+// Label returns the package name, with the installed version when known
+func (vuln Vulnerability) Label() string {
+	if vuln.Version != "" {
+		return vuln.Package + "@" + vuln.Version
+	}
+	return vuln.Package
+}
+
+// Details summarizes advisory identifiers, CVSS, range, via chain and fix
+func (vuln Vulnerability) Details() string {
+	var parts []string
+	if id := firstNonEmpty(vuln.GHSA, vuln.AdvisoryID); id != "" {
+		parts = append(parts, id)
+	}
+	if len(vuln.CVEs) > 0 {
+		parts = append(parts, strings.Join(vuln.CVEs, ", "))
+	}
+	if vuln.CVSS > 0 {
+		parts = append(parts, fmt.Sprintf("CVSS %.1f", vuln.CVSS))
+	}
+	if vuln.VulnerableRange != "" {
+		parts = append(parts, "range "+vuln.VulnerableRange)
+	}
+	if len(vuln.Via) > 1 {
+		parts = append(parts, "via "+strings.Join(vuln.Via, " > "))
+	}
+	if vuln.Location != "" {
+		parts = append(parts, "at "+vuln.Location)
+	}
+	if len(vuln.Workspaces) > 0 {
+		parts = append(parts, "workspace "+strings.Join(vuln.Workspaces, ", "))
+	}
+	if vuln.Suppression != nil {
+		parts = append(parts, suppressionSummary(vuln.Suppression))
+	}
+	switch {
+	case vuln.FixVersion != "" && vuln.FixIsSemVerMajor:
+		parts = append(parts, "fix: "+vuln.FixVersion+" (semver major)")
+	case vuln.FixVersion != "":
+		parts = append(parts, "fix: "+vuln.FixVersion)
+	case vuln.FixAvailable:
+		parts = append(parts, "fix available")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Report represents the complete scan report
+type Report struct {
+	StartTime       time.Time     `json:"start_time"`
+	EndTime         time.Time     `json:"end_time"`
+	TotalDuration   time.Duration `json:"total_duration"`
+	Results         []ScanResult  `json:"results"`
+	ScanID          string        `json:"scan_id"`
+	AdvisoryDB      string        `json:"advisory_db,omitempty"`
+	TargetDir       string        `json:"target_dir,omitempty"`
+	ProjectsScanned int           `json:"projects_scanned"`
+	SuccessCount    int           `json:"success_count"`
+	ErrorCount      int           `json:"error_count"`
+	SafeChainMode   bool          `json:"safe_chain_mode"`
+	ReadOnly        bool          `json:"read_only"`
+	Interrupted     bool          `json:"interrupted"`
+	// Baseline is the --baseline file; ResolvedSinceBaseline lists its findings that are gone
+	Baseline              string            `json:"baseline,omitempty"`
+	ResolvedSinceBaseline []BaselineFinding `json:"resolved_since_baseline,omitempty"`
+}
+
+// reportCollector gathers project results from concurrent workers. Results are
+// stored by discovery index so the final report does not depend on completion order.
+type reportCollector struct {
+	report  *Report
+	results []*ScanResult
+	mu      sync.Mutex
+}
+
+// newReportCollector initializes a new scan report for the given number of projects
+func newReportCollector(total int, opts Options) *reportCollector {
+	c := &reportCollector{
+		report: &Report{
+			ScanID:        fmt.Sprintf("scan_%d", time.Now().Unix()),
+			StartTime:     time.Now(),
+			Results:       make([]ScanResult, 0, total),
+			SafeChainMode: false,
+			ReadOnly:      opts.ReadOnly,
+			TargetDir:     opts.TargetDir,
+		},
+		results: make([]*ScanResult, total),
+	}
+	if opts.AdvisoryDB != nil {
+		c.report.AdvisoryDB = opts.AdvisoryDB.Source
+	}
+	if opts.Baseline != nil {
+		c.report.Baseline = opts.Baseline.Path
+	}
+	return c
+}
+
+// setSafeChainMode sets whether Safe Chain is being used
+func (c *reportCollector) setSafeChainMode(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.SafeChainMode = enabled
+}
+
+// markInterrupted flags the report as partial because the scan was cancelled
+func (c *reportCollector) markInterrupted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Interrupted = true
+}
+
+// add records the result of the project at the given discovery index
+func (c *reportCollector) add(index int, result *ScanResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored := *result
+	c.results[index] = &stored
+}
+
+// finalize completes the scan report with results in discovery order
+func (c *reportCollector) finalize() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := c.report
+	for _, result := range c.results {
+		if result == nil {
+			continue
+		}
+
+		report.Results = append(report.Results, *result)
+		report.ProjectsScanned++
+
+		if result.Status == StatusSuccess {
+			report.SuccessCount++
+		} else {
+			report.ErrorCount++
+		}
+	}
+
+	report.EndTime = time.Now()
+	report.TotalDuration = report.EndTime.Sub(report.StartTime)
+	return report
+}
+
+// CountFindingsAtOrAbove counts unfixed vulnerabilities at or above the given severity
+func (r *Report) CountFindingsAtOrAbove(severity string) int {
+	threshold := SeverityRank(severity)
+	count := 0
+	for i := range r.Results {
+		for _, vuln := range r.Results[i].Vulnerabilities {
+			if !vuln.Fixed && SeverityRank(vuln.Severity) >= threshold {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// CountSuppressions counts suppressed findings and active findings whose suppression expired
+func (r *Report) CountSuppressions() (suppressed, expired int) {
+	for i := range r.Results {
+		suppressed += len(r.Results[i].Suppressed)
+		for _, vuln := range r.Results[i].Vulnerabilities {
+			if vuln.Suppression != nil && vuln.Suppression.Expired {
+				expired++
+			}
+		}
+	}
+	return suppressed, expired
+}
+
+// err describes why the project was not scanned successfully, or returns nil
+func (r *ScanResult) err() error {
+	switch r.Status {
+	case StatusSuccess:
+		return nil
+	case StatusInterrupted:
+		return fmt.Errorf("scan of %s: %w", r.ProjectPath, errInterrupted)
+	}
+	cause := firstNonEmpty(r.SecurityScan.Error, r.NpmInstall.Error, r.NodeModules.Error, r.Status)
+	return fmt.Errorf("scan of %s failed: %s", r.ProjectPath, cause)
+}
@@ -0,0 +1,213 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Default Safe Chain package and command, configurable as safe_chain.package and safe_chain.command
+const (
+	DefaultSafeChainPackage = "safe-chain-test"
+	DefaultSafeChainCommand = "safe-chain"
+)
+
+// IsSafeChainSetupComplete checks if the Safe Chain command is installed and
+// wraps npm in the user's shells. Notes about an incomplete setup go to log.
+func IsSafeChainSetupComplete(ctx context.Context, runner CommandRunner, command string, log io.Writer) bool {
+	// safe-chainコマンドが利用可能かどうかチェック
+	if err := checkCommand(runner, command); err != nil {
+		return false
+	}
+
+	// Safe Chainのセットアップを詳細チェック
+	// 複数のシェル環境で確認
+	shells := []string{"zsh", "bash", "sh"}
+
+	for _, shell := range shells {
+		// shellのfunction/aliasがセットアップされているかチェック
+		output, err := runner.Run(ctx, Command{Name: shell, Args: []string{"-c", "type npm 2>/dev/null"},
+			Combined: true, Timeout: ShellProbeTimeout})
+		if err != nil {
+			continue
+		}
+
+		outputStr := string(output)
+		// aliasまたはshell functionとして設定されているかチェック
+		if strings.Contains(outputStr, "aliased to") ||
+			strings.Contains(outputStr, "shell function") ||
+			strings.Contains(outputStr, "function") {
+			return true
+		}
+	}
+
+	// Safe Chainコマンドが存在すれば、セットアップ済みとして扱う
+	// （実際の環境ではsetupが完了していない可能性があるが、利用可能として継続）
+	logInfo(log, "  ℹ️  Safe Chain is installed but setup may not be complete in current shell\n")
+	return true
+}
+
+// runSecurityScan executes security scan in the given project directory
+func runSecurityScan(ctx context.Context, out io.Writer, projectDir string, pm packageManager, result *ScanResult,
+	opts Options) error {
+	logInfo(out, "  🔍 Running security scan in %s...\n", projectDir)
+
+	finishAudit := opts.startStep(projectDir, StepAudit)
+	if err := checkCommand(opts.CommandRunner(), opts.safeChainCommand()); err != nil {
+		logWarning(out, "  ⚠️  Safe Chain not found, running demo scan for %s\n", projectDir)
+		err := runDemoScan(out, projectDir, result, opts)
+		finishAudit(result.SecurityScan)
+		return err
+	}
+
+	auditOutput, auditErr := executeAudit(ctx, opts.CommandRunner(), out, projectDir, pm, opts.auditLevel(),
+		opts.AuditTimeout)
+	processAuditResults(result, pm, projectDir, auditOutput, auditErr)
+	finishAudit(result.SecurityScan)
+
+	// npm audit fixは--fix指定時のみ実行する（npm以外には同等の修正コマンドがない）
+	isNpm := pm.Name() == PackageManagerNpm
+	if opts.Fix && result.SecurityScan.Success && isNpm {
+		finishFix := opts.startStep(projectDir, StepFix)
+		runAuditFix(ctx, out, projectDir, result, opts)
+		finishFix(result.AuditFix)
+	} else {
+		result.AuditFix.Skipped = true
+		switch {
+		case len(result.Vulnerabilities) == 0:
+		case !isNpm && opts.Fix:
+			logWarning(out, "  ⚠️  --fix only supports npm projects; %s uses %s\n", projectDir, pm.Name())
+		case isNpm:
+			logInfo(out, "  💡 Run with --fix to review and apply npm audit fix for %s\n", projectDir)
+		}
+	}
+
+	logSuccess(out, "  ✅ Security scan completed in %s\n", projectDir)
+	displayScanResults(out, result, projectDir)
+
+	return nil
+}
+
+// runAuditOnly runs the package manager's audit in auditDir without applying any fixes.
+// projectDir is the original project path used in console output.
+func runAuditOnly(ctx context.Context, out io.Writer, auditDir, projectDir string, pm packageManager,
+	result *ScanResult, opts Options) error {
+	logInfo(out, "  🔍 Running read-only security scan in %s...\n", projectDir)
+
+	finishAudit := opts.startStep(projectDir, StepAudit)
+	auditOutput, auditErr := executeAudit(ctx, opts.CommandRunner(), out, auditDir, pm, opts.auditLevel(),
+		opts.AuditTimeout)
+	processAuditResults(result, pm, auditDir, auditOutput, auditErr)
+	finishAudit(result.SecurityScan)
+	if !result.SecurityScan.Success {
+		return errors.New(result.SecurityScan.Error)
+	}
+
+	displayScanResults(out, result, projectDir)
+	return nil
+}
+
+// executeAudit executes the package manager's JSON audit command
+func executeAudit(ctx context.Context, runner CommandRunner, out io.Writer, projectDir string, pm packageManager,
+	level string, timeout time.Duration) (string, error) {
+	args := pm.AuditArgs(level)
+	logInfo(out, "  🔍 Running %s %s (wrapped by Safe Chain) in %s...\n",
+		pm.Command(), strings.Join(args, " "), projectDir)
+	// JSONを壊さないようにstdoutのみをキャプチャ（stderrはExitErrorに残る）
+	auditOutput, auditErr := runner.Run(ctx, Command{Dir: projectDir, Name: pm.Command(), Args: args, Timeout: timeout})
+	return string(auditOutput), auditErr
+}
+
+// executeNpmAuditFix executes npm audit fix command. With ignoreScripts the
+// packages it installs do not run their lifecycle scripts.
+func executeNpmAuditFix(ctx context.Context, runner CommandRunner, out io.Writer, projectDir string,
+	timeout time.Duration, ignoreScripts bool) (string, error) {
+	args := []string{"audit", "fix"}
+	if ignoreScripts {
+		args = append(args, "--ignore-scripts")
+	}
+	logInfo(out, "  🔧 Running npm %s (wrapped by Safe Chain) in %s...\n", strings.Join(args, " "), projectDir)
+	fixOutput, fixErr := runner.Run(ctx, Command{Dir: projectDir, Name: "npm", Args: args, Combined: true,
+		Timeout: timeout})
+	return string(fixOutput), fixErr
+}
+
+// processAuditResults processes the audit results of the package manager
+func processAuditResults(result *ScanResult, pm packageManager, auditDir, auditOutput string, auditErr error) {
+	result.SecurityScan.Output = auditOutput
+
+	// audit --jsonは脆弱性発見時に非ゼロで終了するため、終了コードではなくJSONで判定する
+	vulnerabilities, err := pm.ParseAudit(auditOutput, auditDir)
+	if err != nil {
+		result.SecurityScan.Success = false
+		result.SecurityScan.Error = describeAuditError(err, auditErr)
+		return
+	}
+
+	result.Vulnerabilities = vulnerabilities
+	result.SecurityScan.Success = true
+}
+
+// describeAuditError combines a parse failure with npm's own error output
+func describeAuditError(parseErr, auditErr error) string {
+	var exitErr *ExitError
+	if errors.As(auditErr, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Sprintf("%v (%v)\nOutput: %s", parseErr, auditErr, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if auditErr != nil {
+		return fmt.Sprintf("%v (%v)", parseErr, auditErr)
+	}
+	return parseErr.Error()
+}
+
+// displayScanResults displays scan results summary
+func displayScanResults(out io.Writer, result *ScanResult, projectDir string) {
+	logInfo(out, "  📊 Security scan results for %s:\n", projectDir)
+	if len(result.Vulnerabilities) > 0 {
+		logWarning(out, "  🚨 Found %d vulnerabilities\n", len(result.Vulnerabilities))
+	} else {
+		logSuccess(out, "  🛡️  No vulnerabilities detected\n")
+	}
+}
+
+// runDemoScan runs a demo scan when Safe Chain is not available
+func runDemoScan(out io.Writer, projectDir string, result *ScanResult, opts Options) error {
+	logInfo(out, "  📊 Demo scan results for %s:\n", projectDir)
+	logSuccess(out, "  ✅ Demo scan completed - no vulnerabilities detected in %s\n", projectDir)
+	logWarning(out, "  💡 Install Safe Chain for real vulnerability scanning\n")
+	logWarning(out, "      1. Run: npm install -g %s\n", opts.safeChainPackage())
+	logWarning(out, "      2. Run: %s setup\n", opts.safeChainCommand())
+	logWarning(out, "      3. Restart your terminal\n")
+
+	// Demo結果をレポートに記録
+	result.AuditFix.Skipped = true
+	result.SecurityScan.Output = "Demo scan - no vulnerabilities detected"
+	result.Vulnerabilities = []Vulnerability{} // No vulnerabilities in demo mode
+
+	// 依存関係を監査していないため、対話的な試用以外では成功として扱わない
+	if !opts.DemoScan {
+		result.SecurityScan.Success = false
+		return fmt.Errorf("%s not found; dependencies were not audited", opts.safeChainCommand())
+	}
+	result.SecurityScan.Success = true
+	return nil
+}
+
+// removeDuplicateVulnerabilities removes duplicate vulnerabilities
+func removeDuplicateVulnerabilities(vulnerabilities []Vulnerability) []Vulnerability {
+	uniqueVulns := []Vulnerability{}
+	seen := make(map[string]bool)
+
+	for _, v := range vulnerabilities {
+		key := strings.Join([]string{v.Severity, v.Package, v.Version, v.AdvisoryID, v.Description}, "|")
+		if !seen[key] {
+			uniqueVulns = append(uniqueVulns, v)
+			seen[key] = true
+		}
+	}
+
+	return uniqueVulns
+}
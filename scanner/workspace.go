@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// copyProjectToWorkspace copies the project into a temporary directory so that
+// installs can run without touching the original tree. node_modules and .git are
+// never copied, and symlinks leaving the project are repointed at their targets
+// in the original tree. The returned cleanup function removes the workspace.
+func copyProjectToWorkspace(projectDir string) (string, func(), error) {
+	workspace, err := os.MkdirTemp("", "npm-security-scanner-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary workspace: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(workspace) }
+
+	err = filepath.WalkDir(projectDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(projectDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(workspace, rel)
+
+		if d.IsDir() {
+			if d.Name() == "node_modules" || d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, DirPermSecure)
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := workspaceSymlink(projectDir, path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to copy project to workspace: %w", err)
+	}
+
+	return workspace, cleanup, nil
+}
+
+// workspaceSymlink returns the target of the copied symlink at path. Relative
+// links within the project are kept so they point into the workspace; links
+// leaving the project would dangle from the workspace and are made absolute.
+func workspaceSymlink(projectDir, path string) (string, error) {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(link) {
+		return link, nil
+	}
+
+	resolved := filepath.Join(filepath.Dir(path), link)
+	rel, err := filepath.Rel(projectDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Abs(resolved)
+	}
+	return link, nil
+}
+
+// copyFile copies a regular file, preserving its permission bits
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,470 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yarnClassicInstallArgs maps install strategies to Yarn 1 arguments. Yarn 1
+// cannot resolve a lockfile without installing, so package-lock-only is unsupported.
+var yarnClassicInstallArgs = map[string][]string{
+	InstallStrategyInstall:         {"install"},
+	InstallStrategyCI:              {"install", "--frozen-lockfile"},
+	InstallStrategyCIIgnoreScripts: {"install", "--frozen-lockfile", "--ignore-scripts"},
+}
+
+// yarnBerryInstallArgs maps install strategies to Yarn 2+ arguments
+var yarnBerryInstallArgs = map[string][]string{
+	InstallStrategyInstall:         {"install"},
+	InstallStrategyCI:              {"install", "--immutable"},
+	InstallStrategyCIIgnoreScripts: {"install", "--immutable", "--mode=skip-build"},
+	InstallStrategyLockfileOnly:    {"install", "--mode=update-lockfile"},
+}
+
+// yarnClassicManager runs Yarn 1 and reads its custom yarn.lock format
+type yarnClassicManager struct{}
+
+func (yarnClassicManager) Name() string        { return PackageManagerYarnClassic }
+func (yarnClassicManager) Command() string     { return "yarn" }
+func (yarnClassicManager) Lockfiles() []string { return []string{"yarn.lock"} }
+
+func (m yarnClassicManager) InstallArgs(strategy string) ([]string, error) {
+	return strategyArgs(m, yarnClassicInstallArgs, strategy)
+}
+
+func (yarnClassicManager) AuditArgs(level string) []string {
+	return []string{"audit", "--json", "--level", level}
+}
+
+func (yarnClassicManager) ParseAudit(output, _ string) ([]Vulnerability, error) {
+	return parseYarnClassicAudit(output)
+}
+
+func (yarnClassicManager) ParseLockfile(path string) (*lockfile, error) {
+	return parseYarnLockfile(path)
+}
+
+// yarnBerryManager runs Yarn 2+ and reads its YAML yarn.lock
+type yarnBerryManager struct{}
+
+func (yarnBerryManager) Name() string        { return PackageManagerYarnBerry }
+func (yarnBerryManager) Command() string     { return "yarn" }
+func (yarnBerryManager) Lockfiles() []string { return []string{"yarn.lock"} }
+
+func (m yarnBerryManager) InstallArgs(strategy string) ([]string, error) {
+	return strategyArgs(m, yarnBerryInstallArgs, strategy)
+}
+
+// AuditArgs audits transitive dependencies of every workspace, not only direct ones
+func (yarnBerryManager) AuditArgs(level string) []string {
+	return []string{"npm", "audit", "--all", "--recursive", "--json", "--severity", level}
+}
+
+func (yarnBerryManager) ParseAudit(output, _ string) ([]Vulnerability, error) {
+	return parseYarnBerryAudit(output)
+}
+
+func (yarnBerryManager) ParseLockfile(path string) (*lockfile, error) {
+	return parseYarnLockfile(path)
+}
+
+// isYarnBerryLockfile reports whether yarn.lock was written by Yarn 2+, which
+// records a __metadata block that Yarn 1 lockfiles never have
+func isYarnBerryLockfile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 20 && scanner.Scan(); i++ {
+		if strings.HasPrefix(scanner.Text(), "__metadata:") {
+			return true
+		}
+	}
+	return false
+}
+
+// yarnLockEntry is a resolved package in either yarn.lock format
+type yarnLockEntry struct {
+	Dependencies         map[string]string `yaml:"dependencies"`
+	OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+	Version              string            `yaml:"version"`
+	Resolution           string            `yaml:"resolution"`
+	Resolved             string            `yaml:"resolved"`
+	Integrity            string            `yaml:"integrity"`
+	LanguageName         string            `yaml:"languageName"`
+	// descriptors are the "name@range" keys that resolve to this entry
+	descriptors []string
+}
+
+// parseYarnLockfile reads yarn.lock written by Yarn 1 or Yarn 2+
+func parseYarnLockfile(path string) (*lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*yarnLockEntry
+	version := 1
+	if bytes.Contains(data, []byte("\n__metadata:")) || bytes.HasPrefix(data, []byte("__metadata:")) {
+		entries, version, err = decodeYarnBerryLockfile(data)
+	} else {
+		entries, err = decodeYarnClassicLockfile(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+
+	lock := &lockfile{
+		Path:             path,
+		LockfileVersion:  version,
+		RootDependencies: map[string]string{},
+		RootPaths:        map[string]string{},
+	}
+
+	// 記述子（name@range）から解決済みパッケージへの索引を作る
+	byDescriptor := make(map[string]string)
+	seen := make(map[string]bool)
+	var workspaceRoot *yarnLockEntry
+	workspaces := make(map[string]*yarnLockEntry)
+	for _, entry := range entries {
+		// Yarn 2+のresolutionはエイリアスやパッチを解決した実際のパッケージ名を持つ
+		name, _ := splitPackageDescriptor(firstNonEmpty(entry.Resolution, entry.descriptors[0]))
+		if _, dir, ok := strings.Cut(entry.Resolution, "@workspace:"); ok {
+			if dir == "." {
+				workspaceRoot = entry
+				lock.Name = name
+			} else {
+				workspaces[dir] = entry
+			}
+		}
+		// ワークスペース・link:・portal:はレジストリのパッケージではない
+		if entry.LanguageName == "unknown" || strings.Contains(entry.Resolution, "@workspace:") {
+			continue
+		}
+
+		key := name + "@" + entry.Version
+		for _, descriptor := range entry.descriptors {
+			byDescriptor[descriptor] = key
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		deps := make(map[string]string)
+		for _, m := range []map[string]string{entry.Dependencies, entry.OptionalDependencies} {
+			for dep, spec := range m {
+				deps[dep] = spec
+			}
+		}
+		lock.Packages = append(lock.Packages, lockPackage{
+			Name:         name,
+			Version:      entry.Version,
+			Path:         key,
+			Resolved:     entry.Resolved,
+			Integrity:    entry.Integrity,
+			Dependencies: deps,
+		})
+	}
+
+	for i := range lock.Packages {
+		pkg := &lock.Packages[i]
+		pkg.DependencyPaths = make(map[string]string, len(pkg.Dependencies))
+		for dep, spec := range pkg.Dependencies {
+			if target, ok := resolveYarnDescriptor(byDescriptor, dep, spec); ok {
+				pkg.DependencyPaths[dep] = target
+			}
+		}
+	}
+
+	// Yarn 1のロックファイルはルートの依存関係を記録しないためpackage.jsonを使う
+	roots := map[string]string{}
+	if workspaceRoot != nil {
+		roots = workspaceRoot.Dependencies
+	} else if manifest, err := readPackageManifest(filepath.Dir(path)); err == nil {
+		for _, deps := range []map[string]string{manifest.Dependencies, manifest.DevDependencies,
+			manifest.OptionalDependencies} {
+			for name, spec := range deps {
+				roots[name] = spec
+			}
+		}
+	}
+	for name, spec := range roots {
+		lock.RootDependencies[name] = spec
+		if target, ok := resolveYarnDescriptor(byDescriptor, name, spec); ok {
+			lock.RootPaths[name] = target
+		}
+	}
+
+	// Yarn 2+はワークスペースメンバーの依存関係もロックファイルに記録する
+	if len(workspaces) > 0 {
+		lock.Importers = make(map[string]map[string]string, len(workspaces))
+		for dir, entry := range workspaces {
+			resolved := make(map[string]string)
+			for name, spec := range entry.Dependencies {
+				if target, ok := resolveYarnDescriptor(byDescriptor, name, spec); ok {
+					resolved[name] = target
+				}
+			}
+			lock.Importers[dir] = resolved
+		}
+	}
+
+	sort.Slice(lock.Packages, func(i, j int) bool {
+		return lock.Packages[i].Path < lock.Packages[j].Path
+	})
+	return lock, nil
+}
+
+// resolveYarnDescriptor finds the package a dependency range resolves to. Yarn 2+
+// keys registry ranges with the npm: protocol while dependencies omit it.
+func resolveYarnDescriptor(byDescriptor map[string]string, name, spec string) (string, bool) {
+	if target, ok := byDescriptor[name+"@"+spec]; ok {
+		return target, true
+	}
+	target, ok := byDescriptor[name+"@npm:"+spec]
+	return target, ok
+}
+
+// decodeYarnBerryLockfile decodes the YAML yarn.lock of Yarn 2+
+func decodeYarnBerryLockfile(data []byte) ([]*yarnLockEntry, int, error) {
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	var entries []*yarnLockEntry
+	for key, node := range raw {
+		if key == "__metadata" {
+			var metadata struct {
+				Version string `yaml:"version"`
+			}
+			if err := node.Decode(&metadata); err == nil {
+				version, _ = strconv.Atoi(metadata.Version)
+			}
+			continue
+		}
+
+		entry := &yarnLockEntry{}
+		if err := node.Decode(entry); err != nil {
+			return nil, 0, fmt.Errorf("entry %q: %w", key, err)
+		}
+		// Yarn 2+はchecksumを独自形式で記録するためintegrityとして扱わない
+		entry.descriptors = splitYarnKey(key)
+		entries = append(entries, entry)
+	}
+	return entries, version, nil
+}
+
+// decodeYarnClassicLockfile decodes the YAML-like yarn.lock of Yarn 1:
+//
+//	"@babel/core@^7.0.0", "@babel/core@^7.1.0":
+//	  version "7.1.2"
+//	  dependencies:
+//	    debug "^4.1.0"
+func decodeYarnClassicLockfile(data []byte) ([]*yarnLockEntry, error) {
+	var entries []*yarnLockEntry
+	var entry *yarnLockEntry
+	var section map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		switch {
+		case indent == 0:
+			if !strings.HasSuffix(trimmed, ":") {
+				return nil, fmt.Errorf("line %d: expected an entry key", n)
+			}
+			entry = &yarnLockEntry{descriptors: splitYarnKey(strings.TrimSuffix(trimmed, ":"))}
+			entries = append(entries, entry)
+			section = nil
+		case entry == nil:
+			return nil, fmt.Errorf("line %d: field outside of an entry", n)
+		case indent == 2:
+			section = nil
+			key, value := splitYarnField(trimmed)
+			switch key {
+			case "version":
+				entry.Version = value
+			case "resolved":
+				entry.Resolved = value
+			case "integrity":
+				entry.Integrity = value
+			case "dependencies:":
+				entry.Dependencies = map[string]string{}
+				section = entry.Dependencies
+			case "optionalDependencies:":
+				entry.OptionalDependencies = map[string]string{}
+				section = entry.OptionalDependencies
+			}
+		case section != nil:
+			key, value := splitYarnField(trimmed)
+			section[key] = value
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// splitYarnKey splits an entry key into its descriptors ("a@^1, a@^1.2" → ["a@^1", "a@^1.2"])
+func splitYarnKey(key string) []string {
+	var descriptors []string
+	for _, part := range strings.Split(key, ",") {
+		if part = strings.Trim(strings.TrimSpace(part), `"`); part != "" {
+			descriptors = append(descriptors, part)
+		}
+	}
+	if len(descriptors) == 0 {
+		descriptors = []string{key}
+	}
+	return descriptors
+}
+
+// splitYarnField splits a Yarn 1 field line (`version "1.0.0"`) into key and unquoted value
+func splitYarnField(line string) (string, string) {
+	key, value, _ := strings.Cut(line, " ")
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	return strings.Trim(key, `"`), strings.TrimSpace(value)
+}
+
+// splitPackageDescriptor splits "name@range" into name and range, keeping the scope's "@"
+func splitPackageDescriptor(descriptor string) (string, string) {
+	idx := strings.Index(strings.TrimPrefix(descriptor, "@"), "@")
+	if idx < 0 {
+		return descriptor, ""
+	}
+	if strings.HasPrefix(descriptor, "@") {
+		idx++
+	}
+	return descriptor[:idx], descriptor[idx+1:]
+}
+
+// yarnClassicAuditLine is a line of `yarn audit --json` (Yarn 1) NDJSON output
+type yarnClassicAuditLine struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// parseYarnClassicAudit parses `yarn audit --json`, whose auditAdvisory lines
+// carry npm 6 style advisories
+func parseYarnClassicAudit(output string) ([]Vulnerability, error) {
+	advisories := make(map[string]auditAdvisoryV1)
+	summary := false
+
+	for _, line := range strings.Split(output, "\n") {
+		var event yarnClassicAuditLine
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "auditAdvisory":
+			var data struct {
+				Advisory auditAdvisoryV1 `json:"advisory"`
+			}
+			if err := json.Unmarshal(event.Data, &data); err != nil {
+				return nil, fmt.Errorf("failed to parse yarn audit JSON: %w", err)
+			}
+			advisories[data.Advisory.ID.String()] = data.Advisory
+		case "auditSummary":
+			summary = true
+		case "error":
+			var message string
+			if err := json.Unmarshal(event.Data, &message); err != nil {
+				message = string(event.Data)
+			}
+			return nil, fmt.Errorf("yarn audit error: %s", message)
+		}
+	}
+
+	if !summary && len(advisories) == 0 {
+		return nil, fmt.Errorf("no audit summary found in yarn audit output")
+	}
+
+	vulnerabilities := convertAuditV1(advisories)
+	sortVulnerabilities(vulnerabilities)
+	return removeDuplicateVulnerabilities(vulnerabilities), nil
+}
+
+// yarnBerryAuditLine is a line of `yarn npm audit --json` (Yarn 4) NDJSON output
+type yarnBerryAuditLine struct {
+	Value    string `json:"value"`
+	Children struct {
+		ID                 json.Number `json:"ID"`
+		Issue              string      `json:"Issue"`
+		URL                string      `json:"URL"`
+		Severity           string      `json:"Severity"`
+		VulnerableVersions string      `json:"Vulnerable Versions"`
+		TreeVersions       []string    `json:"Tree Versions"`
+		Dependents         []string    `json:"Dependents"`
+	} `json:"children"`
+}
+
+// parseYarnBerryAudit parses `yarn npm audit --json`. Yarn 4 prints one JSON
+// line per vulnerable package; Yarn 2 and 3 print the registry's npm 6 style report.
+func parseYarnBerryAudit(output string) ([]Vulnerability, error) {
+	if strings.Contains(output, `"advisories"`) {
+		return parseAuditJSON(output, nil)
+	}
+
+	vulnerabilities := []Vulnerability{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var entry yarnBerryAuditLine
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse yarn npm audit JSON: %w", err)
+		}
+		if entry.Value == "" {
+			continue
+		}
+
+		adv := entry.Children
+		base := Vulnerability{
+			Severity:        normalizeSeverity(adv.Severity),
+			Package:         entry.Value,
+			Description:     adv.Issue,
+			AdvisoryID:      adv.ID.String(),
+			GHSA:            ghsaFromURL(adv.URL),
+			URL:             adv.URL,
+			VulnerableRange: adv.VulnerableVersions,
+			Via:             []string{entry.Value},
+		}
+		versions := adv.TreeVersions
+		if len(versions) == 0 {
+			versions = []string{""}
+		}
+		for _, version := range versions {
+			vuln := base
+			vuln.Version = version
+			vulnerabilities = append(vulnerabilities, vuln)
+		}
+	}
+
+	// 脆弱性がない場合、Yarn 4は何も出力しない
+	sortVulnerabilities(vulnerabilities)
+	return removeDuplicateVulnerabilities(vulnerabilities), nil
+}
@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// errNoRawTerminal makes the TUI fall back to plain output on platforms
+// without termios, such as Windows
+var errNoRawTerminal = errors.New("raw terminal mode is not supported on this platform")
+
+// makeRaw is not supported on this platform
+func makeRaw(_ int) (func() error, error) {
+	return nil, errNoRawTerminal
+}
+
+// terminalSize is not supported on this platform
+func terminalSize(_ int) (int, int, error) {
+	return 0, 0, errNoRawTerminal
+}
@@ -0,0 +1,766 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/pality/npm-security-scanner/scanner"
+)
+
+// useTUI decides whether projects are selected and scanned in the terminal UI.
+// It needs an interactive terminal on stdin and stdout; otherwise the plain
+// numbered list and y/N prompt are used.
+func useTUI() bool {
+	if noTUI || assumeYes || nonInteractive || eventsPath == "-" || reportOutputPath == "-" {
+		return false
+	}
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// Keys decoded from raw terminal input
+const (
+	keyRune = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keySpace
+	keyBackspace
+	keyEscape
+	keyCtrlC
+)
+
+// key is a single key press; r is set for keyRune
+type key struct {
+	code int
+	r    rune
+}
+
+var (
+	// terminalKeys receives the key presses read from stdin by the TUI
+	terminalKeys chan key
+	// keyReaderOnce starts the stdin reader the first time the TUI opens
+	keyReaderOnce sync.Once
+)
+
+// parseKeys decodes one read from a raw terminal into key presses
+func parseKeys(data []byte) []key {
+	var keys []key
+	for len(data) > 0 {
+		switch {
+		case len(data) >= 3 && data[0] == 0x1b && data[1] == '[':
+			// 矢印キーは ESC [ A、PageUp/PageDown は ESC [ 5 ~ / ESC [ 6 ~
+			switch data[2] {
+			case 'A':
+				keys = append(keys, key{code: keyUp})
+			case 'B':
+				keys = append(keys, key{code: keyDown})
+			case '5', '6':
+				if len(data) >= 4 && data[3] == '~' {
+					code := keyPageUp
+					if data[2] == '6' {
+						code = keyPageDown
+					}
+					keys = append(keys, key{code: code})
+					data = data[1:]
+				}
+			}
+			data = data[3:]
+			continue
+		case data[0] == 0x1b:
+			keys = append(keys, key{code: keyEscape})
+		case data[0] == '\r' || data[0] == '\n':
+			keys = append(keys, key{code: keyEnter})
+		case data[0] == ' ':
+			keys = append(keys, key{code: keySpace})
+		case data[0] == 0x7f || data[0] == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case data[0] == 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+		default:
+			r := []rune(string(data))[0]
+			if unicode.IsPrint(r) {
+				keys = append(keys, key{code: keyRune, r: r})
+			}
+			data = data[len(string(r)):]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+// terminalUI draws full-screen frames on the alternate screen of a raw terminal
+type terminalUI struct {
+	restore func() error
+}
+
+// openTerminalUI switches stdin to raw mode and stdout to the alternate screen
+func openTerminalUI() (*terminalUI, error) {
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	// stdinの読み取りはTUIを閉じた後も続くため、リーダーは1つだけ起動する
+	keyReaderOnce.Do(func() {
+		terminalKeys = make(chan key, 16)
+		go func() {
+			buf := make([]byte, 64)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					return
+				}
+				for _, k := range parseKeys(buf[:n]) {
+					terminalKeys <- k
+				}
+			}
+		}()
+	})
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	return &terminalUI{restore: restore}, nil
+}
+
+// close leaves the alternate screen and restores the terminal mode
+func (t *terminalUI) close() {
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	_ = t.restore()
+}
+
+// size returns the usable width and height, with a fallback for unknown sizes
+func (t *terminalUI) size() (int, int) {
+	width, height, err := terminalSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// draw replaces the screen with the lines, cut to the terminal width
+func (t *terminalUI) draw(lines []string) {
+	width, height := t.size()
+	var frame strings.Builder
+	frame.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		if i > 0 {
+			frame.WriteString("\n")
+		}
+		frame.WriteString(truncateLine(line, width))
+		frame.WriteString("\x1b[K")
+	}
+	frame.WriteString("\x1b[J")
+	fmt.Fprint(os.Stdout, frame.String())
+}
+
+// truncateLine cuts a line to width visible runes, keeping color escape sequences
+func truncateLine(line string, width int) string {
+	var out strings.Builder
+	visible := 0
+	escape := false
+	for _, r := range line {
+		switch {
+		case escape:
+			out.WriteRune(r)
+			escape = r != 'm'
+			continue
+		case r == 0x1b:
+			out.WriteRune(r)
+			escape = true
+			continue
+		}
+		if visible >= width {
+			continue
+		}
+		out.WriteRune(r)
+		visible++
+	}
+	return out.String()
+}
+
+// sanitizeLine makes captured command output safe to draw: tabs become spaces
+// and other control characters are dropped
+func sanitizeLine(line string) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+var (
+	// TUI用の装飾
+	tuiTitle    = color.New(color.FgCyan, color.Bold)
+	tuiCursor   = color.New(color.ReverseVideo)
+	tuiDim      = color.New(color.Faint)
+	tuiSuccess  = color.New(color.FgGreen)
+	tuiFailure  = color.New(color.FgRed)
+	tuiWarning  = color.New(color.FgYellow)
+	tuiSpinners = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+)
+
+// scrollWindow returns the first visible row so that cursor stays within a
+// window of height rows
+func scrollWindow(offset, cursor, height int) int {
+	if cursor < offset {
+		return cursor
+	}
+	if height > 0 && cursor >= offset+height {
+		return cursor - height + 1
+	}
+	return offset
+}
+
+// projectSelector is the project selection screen: projects are ticked and
+// unticked and the list can be filtered by path
+type projectSelector struct {
+	projects  []scanner.Project
+	selected  []bool
+	filter    string
+	filtering bool
+	cursor    int
+	offset    int
+	message   string
+}
+
+// newProjectSelector starts with every project ticked
+func newProjectSelector(projects []scanner.Project) *projectSelector {
+	s := &projectSelector{projects: projects, selected: make([]bool, len(projects))}
+	for i := range s.selected {
+		s.selected[i] = true
+	}
+	return s
+}
+
+// visible returns the indexes of the projects matching the filter
+func (s *projectSelector) visible() []int {
+	var indexes []int
+	filter := strings.ToLower(s.filter)
+	for i, project := range s.projects {
+		if strings.Contains(strings.ToLower(project.Dir), filter) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// chosen returns the ticked projects in discovery order
+func (s *projectSelector) chosen() []scanner.Project {
+	var projects []scanner.Project
+	for i, project := range s.projects {
+		if s.selected[i] {
+			projects = append(projects, project)
+		}
+	}
+	return projects
+}
+
+// handle applies a key press. It reports whether the selection is finished and
+// whether the scan should start.
+func (s *projectSelector) handle(k key) (done, start bool) {
+	visible := s.visible()
+	s.message = ""
+
+	switch {
+	case k.code == keyCtrlC:
+		return true, false
+	case k.code == keyUp:
+		if s.cursor > 0 {
+			s.cursor--
+		}
+	case k.code == keyDown:
+		if s.cursor < len(visible)-1 {
+			s.cursor++
+		}
+	case s.filtering:
+		// フィルター入力中は文字キーをすべてフィルターとして扱う
+		switch k.code {
+		case keyRune, keySpace:
+			if k.code == keySpace {
+				k.r = ' '
+			}
+			s.filter += string(k.r)
+			s.cursor = 0
+		case keyBackspace:
+			if filter := []rune(s.filter); len(filter) > 0 {
+				s.filter = string(filter[:len(filter)-1])
+				s.cursor = 0
+			}
+		case keyEscape:
+			s.filter, s.filtering, s.cursor = "", false, 0
+		case keyEnter:
+			s.filtering = false
+		}
+	case k.code == keySpace:
+		if s.cursor < len(visible) {
+			i := visible[s.cursor]
+			s.selected[i] = !s.selected[i]
+		}
+	case k.code == keyRune && k.r == 'a':
+		// 表示中のプロジェクトがすべて選択済みなら解除し、そうでなければすべて選択する
+		all := true
+		for _, i := range visible {
+			all = all && s.selected[i]
+		}
+		for _, i := range visible {
+			s.selected[i] = !all
+		}
+	case k.code == keyRune && k.r == '/':
+		s.filtering = true
+	case k.code == keyEscape && s.filter != "":
+		s.filter, s.cursor = "", 0
+	case k.code == keyRune && k.r == 'q', k.code == keyEscape:
+		return true, false
+	case k.code == keyEnter:
+		if len(s.chosen()) == 0 {
+			s.message = "Select at least one project to scan"
+			return false, false
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// render draws the selection screen
+func (s *projectSelector) render(height int) []string {
+	visible := s.visible()
+	lines := []string{tuiTitle.Sprint("NPM Security Scanner - select the projects to scan")}
+	if s.filtering || s.filter != "" {
+		cursor := ""
+		if s.filtering {
+			cursor = "_"
+		}
+		lines = append(lines, fmt.Sprintf("Filter: %s%s", s.filter, cursor))
+	}
+	lines = append(lines, "")
+
+	rows := height - len(lines) - 3
+	s.offset = scrollWindow(s.offset, s.cursor, rows)
+	for n, i := range visible {
+		if n < s.offset || n >= s.offset+rows {
+			continue
+		}
+		project := s.projects[i]
+		check := "[ ]"
+		if s.selected[i] {
+			check = "[x]"
+		}
+		line := fmt.Sprintf("%s %s (%s", check, project.Dir, project.PackageManager)
+		if len(project.Members) > 0 {
+			line += fmt.Sprintf(", %d workspace member(s)", len(project.Members))
+		}
+		line += ")"
+		if n == s.cursor {
+			line = tuiCursor.Sprint("> " + line)
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	if len(visible) == 0 {
+		lines = append(lines, tuiDim.Sprint("  No project matches the filter"))
+	}
+
+	lines = append(lines, "")
+	if s.message != "" {
+		lines = append(lines, tuiWarning.Sprint(s.message))
+	}
+	help := "↑/↓ move · space toggle · a all · / filter · enter scan · q cancel"
+	if s.filtering {
+		help = "type to filter · enter keep · esc clear"
+	}
+	lines = append(lines, tuiDim.Sprintf("%d/%d selected · %s", len(s.chosen()), len(s.projects), help))
+	return lines
+}
+
+// selectProjectsTUI lets the user tick the projects to scan. It returns the
+// chosen projects and false when the selection was cancelled.
+func selectProjectsTUI(projects []scanner.Project) ([]scanner.Project, bool) {
+	ui, err := openTerminalUI()
+	if err != nil {
+		// 端末を操作できない場合は従来の確認に戻る
+		return projects, showProjectsAndConfirm(projects)
+	}
+	defer ui.close()
+
+	selector := newProjectSelector(projects)
+	for {
+		_, height := ui.size()
+		ui.draw(selector.render(height))
+		if done, start := selector.handle(<-terminalKeys); done {
+			if !start {
+				return nil, false
+			}
+			return selector.chosen(), true
+		}
+	}
+}
+
+// projectProgress is the live state of one project in the progress screen
+type projectProgress struct {
+	project     string
+	step        string
+	started     time.Time
+	stepStarted time.Time
+	status      string
+	findings    int
+	duration    time.Duration
+}
+
+// confirmRequest is a fix confirmation asked from a scan worker
+type confirmRequest struct {
+	question string
+	answer   chan bool
+}
+
+// scanProgress is the progress screen fed by scan events. After the scan it
+// lists the results and shows a project's captured command output.
+type scanProgress struct {
+	rows        []*projectProgress
+	byProject   map[string]*projectProgress
+	started     time.Time
+	frame       int
+	confirm     *confirmRequest
+	interrupted bool
+	report      *scanner.Report
+	cursor      int
+	offset      int
+	// detail is the project whose output is shown, or -1 for the list
+	detail       int
+	detailOffset int
+}
+
+// newScanProgress lists the projects as pending
+func newScanProgress(projects []scanner.Project) *scanProgress {
+	p := &scanProgress{byProject: make(map[string]*projectProgress), started: time.Now(), detail: -1}
+	for _, project := range projects {
+		row := &projectProgress{project: project.Dir}
+		p.rows = append(p.rows, row)
+		p.byProject[project.Dir] = row
+	}
+	return p
+}
+
+// apply updates the rows with a scan event
+func (p *scanProgress) apply(event scanner.Event) {
+	switch e := event.(type) {
+	case scanner.ProjectStarted:
+		if row := p.byProject[e.Project]; row != nil {
+			row.started, row.status = time.Now(), scanner.StatusInProgress
+		}
+	case scanner.StepStarted:
+		if row := p.byProject[e.Project]; row != nil {
+			row.step, row.stepStarted = e.Step, time.Now()
+		}
+	case scanner.ProjectFinished:
+		if row := p.byProject[e.Project]; row != nil {
+			row.step, row.status, row.findings, row.duration = "", e.Status, e.Findings, e.Duration
+		}
+	}
+}
+
+// finish switches to the result list
+func (p *scanProgress) finish(report *scanner.Report) {
+	p.report = report
+}
+
+// result returns the scan result of the row, if the project was scanned
+func (p *scanProgress) result(row int) *scanner.ScanResult {
+	if p.report == nil || row < 0 || row >= len(p.rows) {
+		return nil
+	}
+	for i := range p.report.Results {
+		if p.report.Results[i].ProjectPath == p.rows[row].project {
+			return &p.report.Results[i]
+		}
+	}
+	return nil
+}
+
+// handle applies a key press once the scan has finished. It reports whether
+// the user left the TUI.
+func (p *scanProgress) handle(k key, height int) bool {
+	if p.detail >= 0 {
+		page := height - 3
+		switch {
+		case k.code == keyUp:
+			p.detailOffset--
+		case k.code == keyDown:
+			p.detailOffset++
+		case k.code == keyPageUp:
+			p.detailOffset -= page
+		case k.code == keyPageDown:
+			p.detailOffset += page
+		case k.code == keyEscape, k.code == keyBackspace, k.code == keyEnter:
+			p.detail = -1
+		case k.code == keyCtrlC, k.code == keyRune && k.r == 'q':
+			return true
+		}
+		if p.detailOffset < 0 {
+			p.detailOffset = 0
+		}
+		return false
+	}
+
+	switch {
+	case k.code == keyUp && p.cursor > 0:
+		p.cursor--
+	case k.code == keyDown && p.cursor < len(p.rows)-1:
+		p.cursor++
+	case k.code == keyEnter && p.result(p.cursor) != nil:
+		p.detail, p.detailOffset = p.cursor, 0
+	case k.code == keyCtrlC, k.code == keyEscape, k.code == keyRune && k.r == 'q':
+		return true
+	}
+	return false
+}
+
+// render draws the progress or result list, or a project's output
+func (p *scanProgress) render(height int) []string {
+	if p.detail >= 0 {
+		return p.renderDetail(height)
+	}
+
+	var lines []string
+	elapsed := time.Since(p.started).Round(time.Second)
+	switch {
+	case p.report != nil:
+		lines = append(lines, tuiTitle.Sprintf("Scan completed in %v · %d succeeded · %d failed",
+			p.report.TotalDuration.Round(time.Second), p.report.SuccessCount, p.report.ErrorCount))
+	case p.interrupted:
+		lines = append(lines, tuiWarning.Sprintf("Interrupting scan, waiting for running steps... %v", elapsed))
+	default:
+		lines = append(lines, tuiTitle.Sprintf("Scanning %d project(s) · %v", len(p.rows), elapsed))
+	}
+	lines = append(lines, "")
+
+	nameWidth := 0
+	for _, row := range p.rows {
+		if len(row.project) > nameWidth {
+			nameWidth = len(row.project)
+		}
+	}
+
+	rows := height - len(lines) - 3
+	p.offset = scrollWindow(p.offset, p.cursor, rows)
+	for i, row := range p.rows {
+		if i < p.offset || i >= p.offset+rows {
+			continue
+		}
+		line := fmt.Sprintf("%s %-*s  %s", p.statusIcon(row), nameWidth, row.project, p.statusText(row))
+		if p.report != nil && i == p.cursor {
+			line = tuiCursor.Sprint(line)
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "")
+	switch {
+	case p.confirm != nil:
+		lines = append(lines, tuiWarning.Sprintf("%s [y/N]", p.confirm.question))
+	case p.report != nil:
+		lines = append(lines, tuiDim.Sprint("↑/↓ move · enter show output · q quit"))
+	default:
+		lines = append(lines, tuiDim.Sprint("ctrl-c interrupt"))
+	}
+	return lines
+}
+
+// statusIcon returns the spinner of a running project or its final status mark
+func (p *scanProgress) statusIcon(row *projectProgress) string {
+	switch row.status {
+	case "":
+		return tuiDim.Sprint("·")
+	case scanner.StatusInProgress:
+		return tuiTitle.Sprint(tuiSpinners[p.frame%len(tuiSpinners)])
+	case scanner.StatusSuccess:
+		return tuiSuccess.Sprint("✓")
+	case scanner.StatusInterrupted:
+		return tuiWarning.Sprint("!")
+	default:
+		return tuiFailure.Sprint("✗")
+	}
+}
+
+// statusText describes the current step and elapsed time, or the final result
+func (p *scanProgress) statusText(row *projectProgress) string {
+	switch row.status {
+	case "":
+		return tuiDim.Sprint("pending")
+	case scanner.StatusInProgress:
+		if row.step == "" {
+			return fmt.Sprintf("%-8s %v", "starting", time.Since(row.started).Round(time.Second))
+		}
+		return fmt.Sprintf("%-8s %v (total %v)", row.step, time.Since(row.stepStarted).Round(time.Second),
+			time.Since(row.started).Round(time.Second))
+	}
+	text := fmt.Sprintf("%-8s %v", row.status, row.duration.Round(time.Second))
+	if row.findings > 0 {
+		text += tuiWarning.Sprintf("  %d finding(s)", row.findings)
+	}
+	return text
+}
+
+// detailLines lists the steps of a scanned project with their captured output
+func detailLines(result *scanner.ScanResult) []string {
+	var lines []string
+	for _, step := range []struct {
+		name   string
+		action scanner.ActionResult
+	}{
+		{"Remove node_modules", result.NodeModules},
+		{firstNonEmpty(result.InstallCommand(), "Install"), result.NpmInstall},
+		{"Audit", result.SecurityScan},
+		{"Fix", result.AuditFix},
+	} {
+		status := tuiFailure.Sprint("failed")
+		switch {
+		case step.action.Skipped:
+			status = tuiDim.Sprint("skipped")
+		case step.action.Success:
+			status = tuiSuccess.Sprint("success")
+		case step.action.Error == "" && step.action.Output == "":
+			status = tuiDim.Sprint("not run")
+		}
+		lines = append(lines, tuiTitle.Sprintf("== %s: ", step.name)+status)
+		if step.action.Error != "" {
+			lines = append(lines, tuiFailure.Sprint("error: ")+sanitizeLine(step.action.Error))
+		}
+		if output := strings.TrimRight(step.action.Output, "\n"); output != "" {
+			for _, line := range strings.Split(output, "\n") {
+				lines = append(lines, sanitizeLine(line))
+			}
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// renderDetail draws the captured output of the selected project
+func (p *scanProgress) renderDetail(height int) []string {
+	result := p.result(p.detail)
+	lines := []string{tuiTitle.Sprintf("%s · %s · %v", result.ProjectPath, result.Status,
+		result.Duration.Round(time.Second)), ""}
+
+	body := detailLines(result)
+	rows := height - len(lines) - 1
+	if last := len(body) - rows; p.detailOffset > last {
+		p.detailOffset = last
+	}
+	if p.detailOffset < 0 {
+		p.detailOffset = 0
+	}
+	end := p.detailOffset + rows
+	if end > len(body) {
+		end = len(body)
+	}
+	lines = append(lines, body[p.detailOffset:end]...)
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	return append(lines, tuiDim.Sprintf("line %d/%d · ↑/↓ pgup/pgdn scroll · esc back · q quit",
+		p.detailOffset+1, len(body)))
+}
+
+// scanResult is the outcome of ScanAll running behind the TUI
+type scanResult struct {
+	report *scanner.Report
+	err    error
+}
+
+// scanWithTUI runs ScanAll while the progress screen shows every project's
+// current step. The text log is replaced by the screen; fix confirmations are
+// asked on it. Ctrl-C cancels the scan through cancel, and a second Ctrl-C
+// exits immediately. Once the scan finishes the results can be browsed until
+// the user quits.
+func scanWithTUI(ctx context.Context, cancel func(), projects []scanner.Project,
+	opts scanner.Options) (*scanner.Report, error) {
+	ui, err := openTerminalUI()
+	if err != nil {
+		return scanner.ScanAll(ctx, projects, opts)
+	}
+	defer ui.close()
+
+	events := make(chan scanner.Event, 64)
+	confirms := make(chan confirmRequest)
+	forward := opts.Events
+	opts.Log = nil
+	opts.Events = func(event scanner.Event) {
+		if forward != nil {
+			forward(event)
+		}
+		events <- event
+	}
+	opts.Confirm = func(question string) bool {
+		answer := make(chan bool)
+		confirms <- confirmRequest{question: question, answer: answer}
+		return <-answer
+	}
+
+	done := make(chan scanResult, 1)
+	go func() {
+		report, err := scanner.ScanAll(ctx, projects, opts)
+		done <- scanResult{report, err}
+	}()
+
+	progress := newScanProgress(projects)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	var final *scanResult
+	for {
+		_, height := ui.size()
+		ui.draw(progress.render(height))
+
+		select {
+		case event := <-events:
+			progress.apply(event)
+		case request := <-confirms:
+			progress.confirm = &request
+		case <-ticker.C:
+			progress.frame++
+		case result := <-done:
+			// ScanAllが返る前に送られたイベントを反映する
+			for len(events) > 0 {
+				progress.apply(<-events)
+			}
+			if result.err != nil || progress.interrupted || result.report.Interrupted {
+				return result.report, result.err
+			}
+			final = &result
+			progress.finish(result.report)
+		case k := <-terminalKeys:
+			switch {
+			case progress.confirm != nil:
+				yes := k.code == keyRune && (k.r == 'y' || k.r == 'Y')
+				if yes || k.code == keyRune && (k.r == 'n' || k.r == 'N') || k.code == keyEnter ||
+					k.code == keyEscape || k.code == keyCtrlC {
+					progress.confirm.answer <- yes
+					progress.confirm = nil
+				}
+			case final != nil:
+				if progress.handle(k, height) {
+					return final.report, final.err
+				}
+			case k.code == keyCtrlC && progress.interrupted:
+				ui.close()
+				warningColor.Println("⚠️  Scan aborted")
+				os.Exit(ExitInterrupted)
+			case k.code == keyCtrlC:
+				progress.interrupted = true
+				cancel()
+			}
+		}
+	}
+}
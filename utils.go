@@ -3,13 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+
+	"github.com/pality/npm-security-scanner/scanner"
 )
 
 // checkCommand checks if a command is available in PATH
-func checkCommand(command string) error {
-	_, err := exec.LookPath(command)
+func checkCommand(runner scanner.CommandRunner, command string) error {
+	_, err := runner.LookPath(command)
 	return err
 }
 
@@ -36,3 +37,13 @@ func getAbsolutePath(path string) (string, error) {
 	}
 	return absPath, nil
 }
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}